@@ -0,0 +1,128 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+)
+
+// Absorb moves the queued work of other into the pool and stops other, so that pools of separate controllers can be
+// consolidated without losing updates.  It waits for targets other is processing to finish, then queues each of its
+// tasks in the pool; where both pools have a task for a target, the controllers' progress is merged, keeping the
+// pool's own progress for controllers with progress in both.  Held targets stay held, and controllers registered with
+// other are registered with the pool.  other must not be used afterwards, except that pushes to it, for example by
+// controllers created against it, are forwarded to the pool.  Absorb may lose a push made to other just as it
+// finishes, so controllers pushing to other should be paused while it runs.
+func (wp *WorkerPool) Absorb(other *WorkerPool) {
+	if other == wp {
+		return
+	}
+	for _, shard := range other.shards {
+		wp.Absorb(shard)
+	}
+	// pushes while other stops are queued by other, and are taken with the rest of its queue
+	_ = other.Shutdown(context.Background(), false)
+	other.lock.Lock()
+	other.absorbedBy = wp
+	controllers := make([]*Controller, 0, len(other.controllers))
+	for c := range other.controllers {
+		controllers = append(controllers, c)
+	}
+	other.lock.Unlock()
+	for _, c := range controllers {
+		wp.register(c)
+	}
+	entries, held := other.q.takeAll()
+	for _, target := range held {
+		wp.Hold(target)
+	}
+	for _, entry := range entries {
+		owner := wp
+		if wp.shards != nil {
+			owner = wp.shard(entry.cacheResource)
+		}
+		owner.q.absorb(entry)
+		owner.maybeAddWorker()
+	}
+}
+
+// forwardTo returns the pool which absorbed this one, if any.
+func (wp *WorkerPool) forwardTo() *WorkerPool {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	return wp.absorbedBy
+}
+
+// takeAll removes every task from the queue, returning them in queue order along with the held targets.
+func (wq *WorkQueue) takeAll() ([]cacheEntry, []Resource) {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	entries := make([]cacheEntry, 0, len(wq.tasks))
+	for _, key := range wq.tasks {
+		if item, ok := wq.cache[key]; ok {
+			// tokens for pushes to this queue do not apply to the queue the task moves to
+			wq.endStay(&item)
+			entries = append(entries, item)
+		}
+	}
+	held := make([]Resource, 0, len(wq.held))
+	for key := range wq.held {
+		held = append(held, Resource{
+			GroupVersionResource: key.GroupVersionResource,
+			Namespace:            key.Namespace,
+			Name:                 key.Name,
+			ClusterScoped:        key.ClusterScoped,
+			Cluster:              key.Cluster,
+		})
+	}
+	wq.tasks = nil
+	wq.cache = make(map[lockResource]cacheEntry)
+	wq.held = nil
+	wq.pendingPerController = nil
+	return entries, held
+}
+
+// absorb queues a task taken from another queue.  If the target is already queued, progress from controllers without
+// progress in the queued task is added to it, and the task is otherwise left as it is.
+func (wq *WorkQueue) absorb(entry cacheEntry) {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	key := convert(entry.cacheResource)
+	item, inqueue := wq.cache[key]
+	if !inqueue {
+		// ids are given by each queue, so tokens for the other pool's pushes do not apply here
+		entry.id = 0
+		wq.cache[key] = entry
+		for c := range entry.perControllerStatus {
+			wq.addPending(c)
+		}
+		wq.addTask(key)
+		return
+	}
+	for c, progress := range entry.perControllerStatus {
+		if wq.byIdentity && wq.sameIdentity(item.perControllerStatus, c) != nil {
+			continue
+		}
+		if _, ok := item.perControllerStatus[c]; !ok {
+			item.perControllerStatus[c] = progress
+			wq.addPending(c)
+		}
+	}
+	if entry.firstPushed.Before(item.firstPushed) {
+		item.firstPushed = entry.firstPushed
+	}
+	wq.cache[key] = item
+	wq.releaseProgressMap(entry.perControllerStatus)
+}
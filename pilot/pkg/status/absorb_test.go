@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolAbsorb(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	newPool := func() *WorkerPool {
+		return NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+			written <- cfg.Name
+			return true, nil
+		}, func(r Resource) *config.Config {
+			return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+		}, 1)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp, other := newPool(), newPool()
+	wp.Run(ctx)
+	other.Run(ctx)
+	newController := func(name string) *Controller {
+		return &Controller{Name: name, fn: func(status interface{}, context interface{}) GenerationProvider {
+			return &IstioGenerationProvider{}
+		}}
+	}
+	c1, c2 := newController("c1"), newController("c2")
+	shared, mine, theirs := outcomeTarget("shared"), outcomeTarget("mine"), outcomeTarget("theirs")
+	for _, target := range []Resource{shared, mine} {
+		wp.Hold(target)
+		wp.Push(target, c1, "mine")
+	}
+	for _, target := range []Resource{shared, theirs} {
+		other.Hold(target)
+		other.Push(target, c1, "theirs")
+		other.Push(target, c2, "theirs")
+	}
+
+	wp.Absorb(other)
+	g.Expect(other.Stats().Queued).To(Equal(0))
+	g.Expect(wp.Stats().Queued).To(Equal(3))
+	// the pool's own progress is kept where both pools have progress from a controller
+	g.Expect(wp.PendingProgress(shared)).To(Equal(map[string]interface{}{"c1": "mine", "c2": "theirs"}))
+	g.Expect(wp.PendingProgress(mine)).To(Equal(map[string]interface{}{"c1": "mine"}))
+	g.Expect(wp.PendingProgress(theirs)).To(Equal(map[string]interface{}{"c1": "theirs", "c2": "theirs"}))
+
+	// pushes to the absorbed pool are forwarded
+	other.Push(theirs, c1, "forwarded")
+	g.Expect(wp.PendingProgress(theirs)).To(Equal(map[string]interface{}{"c1": "forwarded", "c2": "theirs"}))
+
+	// held targets of the absorbed pool stay held
+	wp.Release(shared)
+	wp.Release(mine)
+	g.Eventually(written).Should(Receive(Equal("shared")))
+	g.Eventually(written).Should(Receive(Equal("mine")))
+	g.Consistently(written).ShouldNot(Receive())
+	wp.Release(theirs)
+	g.Eventually(written).Should(Receive(Equal("theirs")))
+}
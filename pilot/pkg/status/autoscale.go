@@ -0,0 +1,99 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// how often the autoscaler adjusts the worker limit
+	autoscaleInterval = 10 * time.Second
+	// the number of consecutive underused intervals after which the autoscaler lowers the worker limit
+	autoscaleShrinkAfter = 3
+)
+
+// WithAutoscaling scales the number of workers with demand rather than fixing it at maxWorkers, which is ignored.
+// Every ten seconds from Run, the worker limit is doubled, up to maxWorkers, if the oldest queued target has waited
+// longer than targetLatency, and lowered by one, down to minWorkers, once fewer than half the workers allowed have
+// been busy for three intervals running.  Workers above a lowered limit exit once they finish their target.  In a
+// sharded pool each shard is scaled separately, between the same bounds.
+func WithAutoscaling(targetLatency time.Duration, minWorkers, maxWorkers uint) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		if minWorkers == 0 {
+			minWorkers = 1
+		}
+		if maxWorkers < minWorkers {
+			maxWorkers = minWorkers
+		}
+		wp.targetLatency = targetLatency
+		wp.minWorkers = minWorkers
+		wp.workerCap = maxWorkers
+	}
+}
+
+// autoscale adjusts the worker limit every autoscaleInterval until ctx is done.
+func (wp *WorkerPool) autoscale(ctx context.Context) {
+	underused := 0
+	for {
+		t := wp.clock.NewTimer(autoscaleInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C():
+		}
+		underused = wp.scaleWorkers(underused)
+	}
+}
+
+// scaleWorkers adjusts the worker limit to the current demand, given the number of consecutive intervals for which the
+// workers have been underused, and returns the new number.
+func (wp *WorkerPool) scaleWorkers(underused int) int {
+	var waited time.Duration
+	if oldest := wp.q.oldestPending(); !oldest.IsZero() {
+		waited = wp.clock.Since(oldest)
+	}
+	wp.lock.Lock()
+	previous := wp.maxWorkers
+	busy := uint(len(wp.currentlyWorking))
+	switch {
+	case waited > wp.targetLatency:
+		underused = 0
+		wp.maxWorkers *= 2
+		if wp.maxWorkers > wp.workerCap {
+			wp.maxWorkers = wp.workerCap
+		}
+	case busy*2 < wp.maxWorkers:
+		underused++
+		if underused >= autoscaleShrinkAfter && wp.maxWorkers > wp.minWorkers {
+			underused = 0
+			wp.maxWorkers--
+		}
+	default:
+		underused = 0
+	}
+	limit := wp.maxWorkers
+	wp.utilization.setLimit(limit)
+	wp.lock.Unlock()
+	if limit != previous {
+		scope.Debugf("scaled status workers from %d to %d, oldest queued target waited %v", previous, limit, waited)
+	}
+	for i := previous; i < limit; i++ {
+		wp.maybeAddWorker()
+	}
+	return underused
+}
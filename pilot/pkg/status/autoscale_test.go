@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolAutoscaling(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	release := make(chan struct{})
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		<-release
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 100, WithAutoscaling(time.Second, 1, 4), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	limit := func() uint {
+		wp.lock.Lock()
+		defer wp.lock.Unlock()
+		return wp.maxWorkers
+	}
+	tick := func() {
+		g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+		fakeClock.Step(autoscaleInterval)
+	}
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	for i := 0; i < 10; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), c, nil)
+	}
+	// maxWorkers is ignored, the pool starts with the minimum
+	g.Consistently(func() uint { return wp.Stats().Workers }, 100*time.Millisecond).Should(Equal(uint(1)))
+
+	// the backlog has waited longer than the target, so the limit doubles up to the cap
+	tick()
+	g.Eventually(func() uint { return wp.Stats().Workers }).Should(Equal(uint(2)))
+	tick()
+	g.Eventually(func() uint { return wp.Stats().Workers }).Should(Equal(uint(4)))
+	tick()
+	g.Consistently(limit, 100*time.Millisecond).Should(Equal(uint(4)))
+
+	// once the backlog is gone the limit falls back, one worker every few intervals, to the minimum
+	close(release)
+	g.Eventually(func() int { return wp.Stats().Queued }).Should(Equal(0))
+	for i := 0; i < autoscaleShrinkAfter-1; i++ {
+		tick()
+	}
+	g.Consistently(limit, 100*time.Millisecond).Should(Equal(uint(4)))
+	tick()
+	g.Eventually(limit).Should(Equal(uint(3)))
+	for i := 0; i < 3*autoscaleShrinkAfter; i++ {
+		tick()
+	}
+	g.Eventually(limit).Should(Equal(uint(1)))
+}
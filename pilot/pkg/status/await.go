@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTargetDeleted is returned by AwaitProcessed when the awaited target is deleted before it has been processed.
+var ErrTargetDeleted = errors.New("target was deleted before it was processed")
+
+// awaitState tracks the callers awaiting a target, and how often it has been deleted while they waited.
+type awaitState struct {
+	waiters   int
+	deletions uint64
+}
+
+// AwaitProcessed waits until target has no status update queued or being processed, for callers which need the status
+// written before they carry on.  If the target is deleted by Delete or DeleteNamespace before then, whatever the
+// DeleteMode, it returns ErrTargetDeleted as soon as the deletion happens rather than waiting for ctx; if ctx is done
+// first, it returns ctx.Err().  A target requeued to be retried is still awaited.
+func (wp *WorkerPool) AwaitProcessed(ctx context.Context, target Resource) error {
+	if next := wp.forwardTo(); next != nil {
+		return next.AwaitProcessed(ctx, target)
+	}
+	if wp.shards != nil {
+		return wp.shard(target).AwaitProcessed(ctx, target)
+	}
+	key := convert(target)
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	if wp.awaiting == nil {
+		wp.awaiting = make(map[lockResource]*awaitState)
+	}
+	state, ok := wp.awaiting[key]
+	if !ok {
+		state = &awaitState{}
+		wp.awaiting[key] = state
+	}
+	state.waiters++
+	defer func() {
+		if state.waiters--; state.waiters == 0 {
+			delete(wp.awaiting, key)
+		}
+	}()
+	deletions := state.deletions
+	err := waitCond(ctx, wp.cond, func() bool {
+		if state.deletions != deletions {
+			return true
+		}
+		_, working := wp.currentlyWorking[key]
+		return !working && !wp.q.queued(key)
+	})
+	if state.deletions != deletions {
+		return ErrTargetDeleted
+	}
+	return err
+}
+
+// notifyDeleted wakes the callers awaiting the target with key, which has been deleted, with ErrTargetDeleted.  The
+// caller must hold wp.lock.
+func (wp *WorkerPool) notifyDeleted(key lockResource) {
+	if state, ok := wp.awaiting[key]; ok {
+		state.deletions++
+		wp.cond.Broadcast()
+	}
+}
+
+// queued reports whether the target with key is queued.
+func (wq *WorkQueue) queued(key lockResource) bool {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	_, ok := wq.cache[key]
+	return ok
+}
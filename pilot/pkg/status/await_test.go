@@ -0,0 +1,82 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolAwaitProcessed(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	await := func(ctx context.Context, target Resource) chan error {
+		result := make(chan error, 1)
+		go func() {
+			result <- wp.AwaitProcessed(ctx, target)
+		}()
+		return result
+	}
+
+	wp.Hold(outcomeTarget("a"))
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	result := await(ctx, outcomeTarget("a"))
+	g.Consistently(result, 100*time.Millisecond).ShouldNot(Receive())
+	wp.Release(outcomeTarget("a"))
+	g.Eventually(result).Should(Receive(BeNil()))
+	g.Expect(written).To(Receive(Equal("a")))
+
+	// a waiter for a deleted target returns promptly rather than at its deadline
+	wp.Hold(outcomeTarget("b"))
+	wp.Push(outcomeTarget("b"), ctl, nil)
+	waitCtx, waitCancel := context.WithTimeout(ctx, time.Hour)
+	defer waitCancel()
+	result = await(waitCtx, outcomeTarget("b"))
+	g.Consistently(result, 100*time.Millisecond).ShouldNot(Receive())
+	wp.Delete(outcomeTarget("b"))
+	g.Eventually(result).Should(Receive(Equal(ErrTargetDeleted)))
+
+	wp.Hold(outcomeTarget("c"))
+	wp.Push(outcomeTarget("c"), ctl, nil)
+	result = await(waitCtx, outcomeTarget("c"))
+	g.Consistently(result, 100*time.Millisecond).ShouldNot(Receive())
+	wp.DeleteNamespace(outcomeTarget("c").Namespace)
+	g.Eventually(result).Should(Receive(Equal(ErrTargetDeleted)))
+
+	// otherwise the waiter gives up when its context is done
+	wp.Hold(outcomeTarget("d"))
+	wp.Push(outcomeTarget("d"), ctl, nil)
+	short, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer shortCancel()
+	g.Expect(wp.AwaitProcessed(short, outcomeTarget("d"))).To(Equal(context.DeadlineExceeded))
+	g.Expect(written).NotTo(Receive())
+}
@@ -0,0 +1,42 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"time"
+)
+
+// defaultBreakerRecheck is how often parked workers consult the circuit breaker by default.
+const defaultBreakerRecheck = time.Second
+
+// WithCircuitBreaker has workers consult canProcess, an external health signal such as whether the API server is
+// known to be unhealthy, before claiming each target.  While it returns false, workers park rather than popping
+// targets and attempting writes, leaving the queue intact, and resume once it returns true.  Parked workers consult it
+// again every recheck, one second if zero, and whenever a push wakes them.  canProcess is called without holding any
+// lock of the pool, and should be cheap.
+func WithCircuitBreaker(canProcess func() bool, recheck time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		if recheck <= 0 {
+			recheck = defaultBreakerRecheck
+		}
+		wp.canProcess = canProcess
+		wp.breakerRecheck = recheck
+	}
+}
+
+// breakerClosed reports whether the circuit breaker, if any, allows workers to claim targets.
+func (wp *WorkerPool) breakerClosed() bool {
+	return wp.canProcess == nil || wp.canProcess()
+}
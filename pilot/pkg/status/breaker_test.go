@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolCircuitBreaker(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	var healthy int32
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2, WithCircuitBreaker(func() bool {
+		return atomic.LoadInt32(&healthy) == 1
+	}, 5*time.Second), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	// workers park while the breaker is open, leaving the queue intact
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	wp.Push(outcomeTarget("b"), ctl, nil)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(wp.Stats().Queued).To(Equal(2))
+
+	// and resume once it closes and they consult it again
+	atomic.StoreInt32(&healthy, 1)
+	fakeClock.Step(5 * time.Second)
+	var got []string
+	for i := 0; i < 2; i++ {
+		var name string
+		g.Eventually(written).Should(Receive(&name))
+		got = append(got, name)
+	}
+	g.Expect(got).To(ConsistOf("a", "b"))
+
+	atomic.StoreInt32(&healthy, 0)
+	wp.Push(outcomeTarget("c"), ctl, nil)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	// a push wakes parked workers to consult the breaker
+	atomic.StoreInt32(&healthy, 1)
+	wp.Push(outcomeTarget("d"), ctl, nil)
+	got = nil
+	for i := 0; i < 2; i++ {
+		var name string
+		g.Eventually(written).Should(Receive(&name))
+		got = append(got, name)
+	}
+	g.Expect(got).To(ConsistOf("c", "d"))
+}
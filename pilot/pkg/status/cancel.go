@@ -0,0 +1,156 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+)
+
+// PushToken identifies the progress a controller queued for a target with PushCancelable, so that it can be retracted
+// before it is processed.  The zero PushToken retracts nothing.
+type PushToken struct {
+	pool *WorkerPool
+	key  lockResource
+	ctl  *Controller
+	id   uint64
+}
+
+// PushCancelable is Push, returning a token which can retract the progress queued.  This allows a controller to push
+// speculative status and withdraw it, without deleting the target and so dropping the progress of other controllers.
+func (wp *WorkerPool) PushCancelable(target Resource, controller *Controller, context interface{}) PushToken {
+	return wp.push(target, controller, context, nil)
+}
+
+// Cancel retracts the progress the controller queued for the target, if the target is still queued since the push.
+// Progress the controller pushed for the target since, before it was processed, is retracted too, as it replaced the
+// progress of the push.  Once the target has been processed, or deleted, Cancel does nothing.  It reports whether
+// progress was retracted.
+func (t PushToken) Cancel() bool {
+	if t.pool == nil {
+		return false
+	}
+	retracted, _ := t.pool.q.retract(t.key, t.ctl, t.id, false)
+	return retracted
+}
+
+// PushContext is Push, retracting the progress queued if ctx is done before the target is processed, for a caller
+// which may abandon the request the progress is for.  If no progress from any controller then remains queued for the
+// target, its task is dropped.  The pool watches ctx only until the target leaves the queue, by being popped or
+// deleted, or the pool closes; progress still queued when the pool closes is no longer retracted.
+func (wp *WorkerPool) PushContext(ctx context.Context, target Resource, controller *Controller, progress interface{}) {
+	token := wp.push(target, controller, progress, nil)
+	if token.pool == nil || ctx.Done() == nil {
+		// the context can never be done
+		return
+	}
+	stayEnded := token.pool.q.watchStay(token.key, token.id)
+	if stayEnded == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			token.pool.retractForContext(token, target)
+		case <-stayEnded:
+		}
+	}()
+}
+
+// watchStay returns a channel closed when the stay id of the target with key in the queue ends, or nil if it has
+// already ended or the pool has closed.
+func (wq *WorkQueue) watchStay(key lockResource, id uint64) <-chan struct{} {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	item, inqueue := wq.cache[key]
+	if !inqueue || item.id != id || wq.staysEnded {
+		return nil
+	}
+	if item.stayEnded == nil {
+		item.stayEnded = make(chan struct{})
+		wq.cache[key] = item
+	}
+	return item.stayEnded
+}
+
+// endStay ends the watching of the stay of item, which is leaving the queue.  The caller must hold wq.lock.
+func (wq *WorkQueue) endStay(item *cacheEntry) {
+	if item.stayEnded != nil {
+		close(item.stayEnded)
+		item.stayEnded = nil
+	}
+}
+
+// endStays ends the watching of every queued stay, as the pool is closing.
+func (wq *WorkQueue) endStays() {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	wq.staysEnded = true
+	for key, item := range wq.cache {
+		if item.stayEnded != nil {
+			wq.endStay(&item)
+			wq.cache[key] = item
+		}
+	}
+}
+
+// retractForContext retracts the progress queued with token, whose context is done, dropping the task for target if
+// no progress remains.
+func (wp *WorkerPool) retractForContext(token PushToken, target Resource) {
+	retracted, dropped := wp.q.retract(token.key, token.ctl, token.id, true)
+	if !retracted {
+		return
+	}
+	scope.Debugf("retracted status update for %s from controller %q, whose context is done", target,
+		token.ctl.Identity())
+	if !dropped {
+		return
+	}
+	wp.dropped(target, OutcomeDeleted)
+	wp.outcomes.record(target, OutcomeDeleted, nil)
+	wp.lock.Lock()
+	// wake Shutdown if it is draining the queue
+	wp.cond.Broadcast()
+	wp.lock.Unlock()
+}
+
+// retract removes the progress of ctl from the target with key, if it is still queued with id, reporting whether it
+// did.  If drop is set and the target has no progress left, nor earlier generations or a final status to write, its
+// task is removed, and dropped is reported.
+func (wq *WorkQueue) retract(key lockResource, ctl *Controller, id uint64, drop bool) (retracted, dropped bool) {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	item, inqueue := wq.cache[key]
+	if !inqueue || item.id != id {
+		return false, false
+	}
+	if _, ok := item.perControllerStatus[ctl]; !ok {
+		return false, false
+	}
+	delete(item.perControllerStatus, ctl)
+	wq.removePendingFor(ctl)
+	if !drop || len(item.perControllerStatus) > 0 || len(item.older) > 0 || item.deleted {
+		return true, false
+	}
+	delete(wq.cache, key)
+	wq.endStay(&item)
+	wq.releaseProgressMap(item.perControllerStatus)
+	for i, k := range wq.tasks {
+		if k == key {
+			wq.tasks = append(wq.tasks[:i], wq.tasks[i+1:]...)
+			break
+		}
+	}
+	return true, true
+}
@@ -0,0 +1,180 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolPushCancelable(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan []string, 2)
+	wp := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+		var reasons []string
+		for _, c := range status.(*IstioGenerationProvider).Conditions {
+			reasons = append(reasons, c.Reason)
+		}
+		written <- reasons
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	contributes := func(name string) *Controller {
+		return &Controller{Name: name, fn: func(status interface{}, context interface{}) GenerationProvider {
+			s, _ := status.(*IstioGenerationProvider)
+			if s == nil || s.IstioStatus == nil {
+				s = &IstioGenerationProvider{&v1alpha1.IstioStatus{}}
+			}
+			s.Conditions = append(s.Conditions, &v1alpha1.IstioCondition{Reason: name})
+			return s
+		}}
+	}
+	speculative, other := contributes("speculative"), contributes("other")
+	target := outcomeTarget("a")
+
+	wp.Hold(target)
+	token := wp.PushCancelable(target, speculative, nil)
+	wp.Push(target, other, nil)
+	g.Expect(token.Cancel()).To(BeTrue())
+	g.Expect(token.Cancel()).To(BeFalse())
+	wp.Release(target)
+	g.Eventually(written).Should(Receive(Equal([]string{"other"})))
+
+	// once processed, the token does not retract a later push
+	token = wp.PushCancelable(target, speculative, nil)
+	g.Eventually(written).Should(Receive(Equal([]string{"speculative"})))
+	wp.Hold(target)
+	wp.Push(target, speculative, nil)
+	g.Expect(token.Cancel()).To(BeFalse())
+	g.Expect(PushToken{}.Cancel()).To(BeFalse())
+	wp.Release(target)
+	g.Eventually(written).Should(Receive(Equal([]string{"speculative"})))
+}
+
+func TestWorkerPoolPushContext(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan []string, 2)
+	wp := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+		var reasons []string
+		for _, c := range status.(*IstioGenerationProvider).Conditions {
+			reasons = append(reasons, c.Reason)
+		}
+		written <- reasons
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	contributes := func(name string) *Controller {
+		return &Controller{Name: name, fn: func(status interface{}, context interface{}) GenerationProvider {
+			s, _ := status.(*IstioGenerationProvider)
+			if s == nil || s.IstioStatus == nil {
+				s = &IstioGenerationProvider{&v1alpha1.IstioStatus{}}
+			}
+			s.Conditions = append(s.Conditions, &v1alpha1.IstioCondition{Reason: name})
+			return s
+		}}
+	}
+	interactive, other := contributes("interactive"), contributes("other")
+
+	// the abandoned request's contribution is retracted, leaving the other controller's
+	a := outcomeTarget("a")
+	wp.Hold(a)
+	requestCtx, abandon := context.WithCancel(context.Background())
+	wp.PushContext(requestCtx, a, interactive, nil)
+	wp.Push(a, other, nil)
+	abandon()
+	g.Eventually(func() map[string]interface{} { return wp.PendingProgress(a) }).Should(HaveLen(1))
+	g.Expect(wp.PendingProgress(a)).To(HaveKey("other"))
+	wp.Release(a)
+	g.Eventually(written).Should(Receive(Equal([]string{"other"})))
+
+	// with no contribution left, the task is dropped
+	b := outcomeTarget("b")
+	wp.Hold(b)
+	requestCtx, abandon = context.WithCancel(context.Background())
+	wp.PushContext(requestCtx, b, interactive, nil)
+	abandon()
+	g.Eventually(func() OutcomeType {
+		outcome, _ := wp.LastOutcome(b)
+		return outcome.Type
+	}).Should(Equal(OutcomeDeleted))
+	g.Expect(wp.PendingProgress(b)).To(BeNil())
+	wp.Release(b)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+}
+
+// contextWatchers returns the number of goroutines started by PushContext which are running.
+func contextWatchers() int {
+	buf := make([]byte, 1<<20)
+	n := runtime.Stack(buf, true)
+	return strings.Count(string(buf[:n]), "(*WorkerPool).PushContext.func1(")
+}
+
+func TestWorkerPoolPushContextGoroutines(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 100)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	// the context is no longer watched once the target has been processed, although it is never done
+	requestCtx, abandon := context.WithCancel(context.Background())
+	defer abandon()
+	held := outcomeTarget("held")
+	wp.Hold(held)
+	wp.PushContext(requestCtx, held, c, nil)
+	g.Expect(contextWatchers()).To(Equal(1))
+	for i := 0; i < 50; i++ {
+		wp.PushContext(requestCtx, outcomeTarget(strconv.Itoa(i)), c, nil)
+	}
+	for i := 0; i < 50; i++ {
+		g.Eventually(written).Should(Receive())
+	}
+	g.Eventually(contextWatchers).Should(Equal(1))
+
+	// nor once the pool has closed
+	cancel()
+	g.Eventually(contextWatchers).Should(Equal(0))
+
+	// a context which can never be done is not watched at all
+	wp = NewWorkerPool(nil, nil, 0)
+	wp.PushContext(context.Background(), held, c, nil)
+	g.Expect(contextWatchers()).To(Equal(0))
+}
@@ -0,0 +1,40 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"istio.io/istio/pkg/config"
+)
+
+// ClusterWriteFunc persists the computed status for a config in the named cluster.  Like a WriteFunc, it reports
+// whether a change was actually persisted.
+type ClusterWriteFunc func(cluster string, cfg *config.Config, status interface{}) (changed bool, err error)
+
+// WithClusterWrites writes status for targets whose Resource has a Cluster with write, which is passed the cluster, so
+// that a single pool can manage status for several clusters.  The get function passed to NewWorkerPool already
+// receives the target, and so its cluster.  Targets without a Cluster are written with the pool's WriteFunc, so a
+// single-cluster pool behaves as it did without the option.  WithDryRun still takes precedence over write.
+func WithClusterWrites(write ClusterWriteFunc) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.clusterWrite = write
+	}
+}
+
+// clusterWriter adapts the pool's ClusterWriteFunc to a WriteFunc writing to cluster.
+func (wp *WorkerPool) clusterWriter(cluster string) WriteFunc {
+	return func(cfg *config.Config, status interface{}) (bool, error) {
+		return wp.clusterWrite(cluster, cfg, status)
+	}
+}
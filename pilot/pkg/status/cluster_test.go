@@ -0,0 +1,83 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func clusterTarget(cluster, name string) Resource {
+	r := outcomeTarget(name)
+	r.Cluster = cluster
+	return r
+}
+
+func TestWorkerPoolClusters(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	// same-named targets in different clusters are queued separately
+	wp := NewWorkerPool(nil, nil, 0)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	wp.Push(clusterTarget("", "a"), ctl, nil)
+	wp.Push(clusterTarget("east", "a"), ctl, nil)
+	wp.Push(clusterTarget("west", "a"), ctl, nil)
+	wp.Push(clusterTarget("west", "a"), ctl, nil)
+	g.Expect(wp.Stats().Queued).To(Equal(3))
+	wp.Delete(clusterTarget("east", "a"))
+	g.Expect(wp.Stats().Queued).To(Equal(2))
+	g.Expect(wp.PendingProgress(clusterTarget("east", "a"))).To(BeNil())
+	g.Expect(wp.PendingProgress(clusterTarget("west", "a"))).NotTo(BeNil())
+
+	// reads and cluster writes see the cluster of each target, and targets without one use the plain write
+	var mu sync.Mutex
+	reads := map[string]int{}
+	written := make(chan string, 10)
+	wp = NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- "(default)/" + cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		mu.Lock()
+		reads[r.Cluster]++
+		mu.Unlock()
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2, WithClusterWrites(func(cluster string, cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cluster + "/" + cfg.Name
+		return true, nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	wp.Push(clusterTarget("", "a"), ctl, nil)
+	wp.Push(clusterTarget("east", "a"), ctl, nil)
+	wp.Push(clusterTarget("west", "a"), ctl, nil)
+	got := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		var w string
+		g.Eventually(written).Should(Receive(&w))
+		got[w] = true
+	}
+	g.Expect(got).To(Equal(map[string]bool{"(default)/a": true, "east/a": true, "west/a": true}))
+	mu.Lock()
+	defer mu.Unlock()
+	g.Expect(reads).To(Equal(map[string]int{"": 1, "east": 1, "west": 1}))
+}
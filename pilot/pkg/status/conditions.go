@@ -0,0 +1,101 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"github.com/gogo/protobuf/proto"
+
+	"istio.io/api/meta/v1alpha1"
+)
+
+// ConditionConflictPolicy decides which condition is kept when several controllers set an IstioStatus condition of the
+// same type for a single write.
+type ConditionConflictPolicy int
+
+const (
+	// LatestConditionWins keeps the condition from the controller applied last, i.e. the one with the highest Priority.
+	LatestConditionWins ConditionConflictPolicy = iota
+	// PriorityConditionWins keeps the condition from the controller applied first, i.e. the one with the lowest
+	// Priority, so that controllers refining a status cannot override the conditions of the controller it is based on.
+	PriorityConditionWins
+)
+
+// WithIstioConditionMerge merges the IstioStatus conditions set by each controller by condition type, rather than
+// letting each controller's UpdateFunc replace the conditions set by the controllers applied before it.  Conditions of
+// types set by only one controller always survive, so a controller can no longer remove a condition set by another.
+func WithIstioConditionMerge(policy ConditionConflictPolicy) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.mergeConditions = true
+		wp.conditionPolicy = policy
+	}
+}
+
+// istioConditions returns a copy of the conditions of an IstioStatus, so that they survive modification of the status
+// by the next controller.
+func istioConditions(x GenerationProvider) []*v1alpha1.IstioCondition {
+	p, ok := x.(*IstioGenerationProvider)
+	if !ok || p.IstioStatus == nil {
+		return nil
+	}
+	out := make([]*v1alpha1.IstioCondition, 0, len(p.Conditions))
+	for _, c := range p.Conditions {
+		out = append(out, c.DeepCopy())
+	}
+	return out
+}
+
+// mergeIstioConditions merges the conditions present before a controller was applied into x, the status it returned.
+func mergeIstioConditions(previous []*v1alpha1.IstioCondition, x GenerationProvider, policy ConditionConflictPolicy) {
+	p, ok := x.(*IstioGenerationProvider)
+	if !ok || p.IstioStatus == nil || len(previous) == 0 {
+		return
+	}
+	index := make(map[string]int, len(p.Conditions))
+	for i, c := range p.Conditions {
+		index[c.Type] = i
+	}
+	for _, c := range previous {
+		i, found := index[c.Type]
+		if !found {
+			p.Conditions = append(p.Conditions, c)
+			continue
+		}
+		if policy == PriorityConditionWins {
+			p.Conditions[i] = c
+		}
+	}
+}
+
+// storedIstioStatus returns a copy of the status of a config if it is an IstioStatus, so that it survives modification
+// by the controllers.
+func storedIstioStatus(status interface{}) *v1alpha1.IstioStatus {
+	s, ok := status.(*v1alpha1.IstioStatus)
+	if !ok || s == nil {
+		return nil
+	}
+	return s.DeepCopy()
+}
+
+// generationOnly reports whether the only difference between the stored status and x, the status computed for it, is
+// the observed generation.
+func generationOnly(stored *v1alpha1.IstioStatus, x GenerationProvider) bool {
+	p, ok := x.(*IstioGenerationProvider)
+	if !ok || p.IstioStatus == nil || stored == nil || p.ObservedGeneration == stored.ObservedGeneration {
+		return false
+	}
+	computed := p.IstioStatus.DeepCopy()
+	computed.ObservedGeneration = stored.ObservedGeneration
+	return proto.Equal(stored, computed)
+}
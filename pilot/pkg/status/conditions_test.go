@@ -0,0 +1,97 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func TestIstioConditionMerge(t *testing.T) {
+	mgr := NewManager(nil)
+	// both controllers build their conditions from scratch, discarding the status they are given
+	base := mgr.CreateIstioStatusController(func(status *v1alpha1.IstioStatus, context interface{}) *v1alpha1.IstioStatus {
+		return &v1alpha1.IstioStatus{Conditions: []*v1alpha1.IstioCondition{
+			{Type: "Accepted", Status: "True"},
+			{Type: "Ready", Status: "True"},
+		}}
+	})
+	base.Priority = 0
+	refine := mgr.CreateIstioStatusController(func(status *v1alpha1.IstioStatus, context interface{}) *v1alpha1.IstioStatus {
+		return &v1alpha1.IstioStatus{Conditions: []*v1alpha1.IstioCondition{
+			{Type: "Ready", Status: "False"},
+			{Type: "Programmed", Status: "True"},
+		}}
+	})
+	refine.Priority = 1
+
+	cases := []struct {
+		name string
+		opts []WorkerPoolOption
+		want map[string]string
+	}{
+		{
+			name: "no merge",
+			want: map[string]string{"Ready": "False", "Programmed": "True"},
+		},
+		{
+			name: "latest wins",
+			opts: []WorkerPoolOption{WithIstioConditionMerge(LatestConditionWins)},
+			want: map[string]string{"Accepted": "True", "Ready": "False", "Programmed": "True"},
+		},
+		{
+			name: "priority wins",
+			opts: []WorkerPoolOption{WithIstioConditionMerge(PriorityConditionWins)},
+			want: map[string]string{"Accepted": "True", "Ready": "True", "Programmed": "True"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			target := Resource{
+				GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+				Namespace:            "r1",
+				Name:                 "r1",
+				Generation:           "1",
+			}
+			written := make(chan interface{}, 1)
+			workers := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+				written <- status
+				return true, nil
+			}, func(Resource) *config.Config {
+				return &config.Config{Meta: config.Meta{Generation: 1}, Status: &v1alpha1.IstioStatus{}}
+			}, 1, tt.opts...)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			workers.Run(ctx)
+			workers.q.Push(target, refine, nil)
+			workers.q.Push(target, base, nil)
+			workers.maybeAddWorker()
+
+			status := (<-written).(*IstioGenerationProvider)
+			got := map[string]string{}
+			for _, c := range status.Conditions {
+				got[c.Type] = c.Status
+			}
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"time"
+)
+
+// PoolConfig is the effective configuration of a pool, as set by NewWorkerPool, its options and later calls to
+// SetMaxWorkers.  Zero values mean the corresponding option is disabled or left at its default.
+type PoolConfig struct {
+	// MaxWorkers is the limit on concurrent workers across the pool; with autoscaling, it is the upper bound.
+	MaxWorkers uint
+	// Autoscaling reports whether the worker limit is scaled between MinWorkers and MaxWorkers to keep queued targets
+	// from waiting longer than TargetLatency.
+	Autoscaling   bool
+	MinWorkers    uint
+	TargetLatency time.Duration
+	// Shards is the number of sub-pools targets are routed to, or zero if the pool is not sharded.
+	Shards         uint
+	SingleThreaded bool
+	QueueOrder     QueueOrder
+	// Debounce, MaxDebounceWait and MaxQueueLatency are the limits set by WithDebounce and WithMaxQueueLatency.
+	Debounce        time.Duration
+	MaxDebounceWait time.Duration
+	MaxQueueLatency time.Duration
+	// InitialSettle is the delay before the first processing of each target set by WithInitialSettle.
+	InitialSettle time.Duration
+	// MinWriteInterval is the minimum time between writes of each target set by WithMinWriteInterval.
+	MinWriteInterval time.Duration
+	// MaxPendingPerController, MaxGenerations and MaxConcurrentReads are zero if unlimited.
+	MaxPendingPerController int
+	MaxGenerations          int
+	MaxConcurrentReads      int
+	ControllerIdentity      bool
+	MaxRefetches            uint
+	LateGenerationCheck     bool
+	InFlightTimeout         time.Duration
+	DeleteMode              DeleteMode
+	EmptyProgress           EmptyProgressPolicy
+	// MergeConditions reports whether IstioStatus conditions are merged by type according to ConditionPolicy.
+	MergeConditions bool
+	ConditionPolicy ConditionConflictPolicy
+	DryRun          bool
+	OwnerGrouping   bool
+	GroupedWrites   bool
+	Validation      bool
+	CircuitBreaker  bool
+	// ExclusiveProcessingCheck reports whether the pool verifies that no target is processed twice at once.
+	ExclusiveProcessingCheck bool
+	// TickBatch and TickInterval are set by WithTickedProcessing; a zero TickBatch processes continuously.
+	TickBatch    int
+	TickInterval time.Duration
+	// ClusterWrites reports whether status for targets with a cluster is written with a ClusterWriteFunc.
+	ClusterWrites bool
+}
+
+// Config returns a copy of the effective configuration of the pool, complementing Stats with how the pool is set up.
+func (wp *WorkerPool) Config() PoolConfig {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	c := PoolConfig{
+		MaxWorkers:               wp.maxWorkers,
+		Autoscaling:              wp.workerCap > 0,
+		Shards:                   wp.shardCount,
+		SingleThreaded:           wp.singleThreaded,
+		QueueOrder:               wp.q.order,
+		Debounce:                 wp.q.debounce,
+		MaxDebounceWait:          wp.q.maxDebounceWait,
+		MaxQueueLatency:          wp.q.maxQueueLatency,
+		MinWriteInterval:         wp.q.minWriteInterval,
+		InitialSettle:            wp.q.initialSettle,
+		MaxPendingPerController:  wp.q.maxPendingPerController,
+		MaxGenerations:           wp.q.maxGenerations,
+		MaxConcurrentReads:       cap(wp.reads),
+		ControllerIdentity:       wp.q.byIdentity,
+		MaxRefetches:             wp.maxRefetches,
+		LateGenerationCheck:      wp.lateGenerationCheck,
+		InFlightTimeout:          wp.inFlightTimeout,
+		DeleteMode:               wp.deleteMode,
+		EmptyProgress:            wp.emptyProgress,
+		MergeConditions:          wp.mergeConditions,
+		ConditionPolicy:          wp.conditionPolicy,
+		DryRun:                   wp.dryRun != nil,
+		OwnerGrouping:            wp.ownerKey != nil,
+		GroupedWrites:            wp.writeGrouped != nil,
+		Validation:               wp.validateBeforeWrite != nil,
+		CircuitBreaker:           wp.canProcess != nil,
+		ExclusiveProcessingCheck: wp.exclusivity != nil,
+		TickBatch:                wp.tickBatch,
+		TickInterval:             wp.tickInterval,
+		ClusterWrites:            wp.clusterWrite != nil,
+	}
+	if c.Autoscaling {
+		c.MaxWorkers = wp.workerCap
+		c.MinWorkers = wp.minWorkers
+		c.TargetLatency = wp.targetLatency
+	}
+	return c
+}
+
+// SetMaxWorkers changes the limit on concurrent workers, starting workers for queued targets if it is raised.  Workers
+// above a lowered limit exit once they finish their target.  With autoscaling, it changes the upper bound, which is
+// kept at least the lower one; in a sharded pool, the limit is divided between shards as by NewWorkerPool.  It does
+// nothing to a single-threaded pool.
+func (wp *WorkerPool) SetMaxWorkers(n uint) {
+	wp.lock.Lock()
+	previous := wp.maxWorkers
+	wp.setMaxWorkers(n)
+	limit := wp.maxWorkers
+	wp.utilization.setLimit(limit)
+	// waiting workers above a lowered limit recheck it and exit
+	wp.idle.Broadcast()
+	wp.lock.Unlock()
+	if wp.shards != nil {
+		perShard := (n + wp.shardCount - 1) / wp.shardCount
+		for _, shard := range wp.shards {
+			shard.SetMaxWorkers(perShard)
+		}
+		return
+	}
+	for i := previous; i < limit; i++ {
+		wp.maybeAddWorker()
+	}
+}
+
+// setMaxWorkers sets the worker limit, or its upper bound with autoscaling, to n.  The caller must hold wp.lock.
+func (wp *WorkerPool) setMaxWorkers(n uint) {
+	switch {
+	case wp.singleThreaded:
+	case wp.workerCap > 0:
+		if n < wp.minWorkers {
+			n = wp.minWorkers
+		}
+		wp.workerCap = n
+		if wp.maxWorkers > n {
+			wp.maxWorkers = n
+		}
+	default:
+		wp.maxWorkers = n
+	}
+}
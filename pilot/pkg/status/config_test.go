@@ -0,0 +1,59 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWorkerPoolConfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 4,
+		WithDebounce(time.Second, 5*time.Second),
+		WithMaxConcurrentReads(3),
+		WithDeleteMode(DeleteProcessFinal, func(Resource) GenerationProvider { return nil }),
+		WithQueueOrder(SortedOrder))
+	g.Expect(wp.Config()).To(Equal(PoolConfig{
+		MaxWorkers:         4,
+		QueueOrder:         SortedOrder,
+		Debounce:           time.Second,
+		MaxDebounceWait:    5 * time.Second,
+		MaxConcurrentReads: 3,
+		DeleteMode:         DeleteProcessFinal,
+	}))
+	wp.SetMaxWorkers(8)
+	g.Expect(wp.Config().MaxWorkers).To(Equal(uint(8)))
+
+	// with autoscaling, the upper bound is changed, and kept at least the lower one
+	wp = NewWorkerPool(nil, nil, 4, WithAutoscaling(time.Second, 2, 6))
+	c := wp.Config()
+	g.Expect(c.Autoscaling).To(BeTrue())
+	g.Expect(c.MinWorkers).To(Equal(uint(2)))
+	g.Expect(c.MaxWorkers).To(Equal(uint(6)))
+	wp.SetMaxWorkers(1)
+	g.Expect(wp.Config().MaxWorkers).To(Equal(uint(2)))
+
+	// a sharded pool divides the new limit between its shards
+	wp = NewWorkerPool(nil, nil, 4, WithShards(2))
+	g.Expect(wp.Config().Shards).To(Equal(uint(2)))
+	wp.SetMaxWorkers(6)
+	g.Expect(wp.Config().MaxWorkers).To(Equal(uint(6)))
+	for _, shard := range wp.shards {
+		g.Expect(shard.Config().MaxWorkers).To(Equal(uint(3)))
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ControllerInfo describes a controller known to a WorkerPool.
+type ControllerInfo struct {
+	// Identity is the Name of the controller, or its address if it has no name.
+	Identity    string
+	Priority    int
+	Required    bool
+	Handles     []schema.GroupVersionResource
+	NilProgress NilProgressPolicy
+	Coalescing  CoalescingPolicy
+}
+
+// RegisterController makes the pool aware of c before it enqueues any updates, so that it is listed by Controllers
+// and, if it is required, writes by other controllers are deferred until it contributes.  Controllers created by the
+// Manager are registered when they are created, and other controllers when they first enqueue an update.
+func (wp *WorkerPool) RegisterController(c *Controller) {
+	wp.register(c)
+}
+
+// Controllers returns the controllers known to the pool, in the order their updates are applied.
+func (wp *WorkerPool) Controllers() []ControllerInfo {
+	wp.lock.Lock()
+	known := make(map[*Controller]interface{}, len(wp.controllers))
+	for c := range wp.controllers {
+		known[c] = nil
+	}
+	wp.lock.Unlock()
+	controllers := sortedControllers(known)
+	out := make([]ControllerInfo, 0, len(controllers))
+	for _, c := range controllers {
+		out = append(out, ControllerInfo{
+			Identity:    c.Identity(),
+			Priority:    c.Priority,
+			Required:    c.Required,
+			Handles:     append([]schema.GroupVersionResource(nil), c.Handles...),
+			NilProgress: c.NilProgress,
+			Coalescing:  c.Coalescing,
+		})
+	}
+	return out
+}
@@ -0,0 +1,40 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWorkerPoolControllers(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0)
+	g.Expect(wp.Controllers()).To(BeEmpty())
+	gateways := schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1alpha3", Resource: "gateways"}
+	ready := &Controller{Name: "ready", Priority: 1, Required: true, Handles: []schema.GroupVersionResource{gateways}}
+	analysis := &Controller{Name: "analysis", Priority: 2, NilProgress: NilProgressSkip}
+
+	wp.RegisterController(ready)
+	// controllers which are not registered are learnt of when they first push
+	wp.Push(outcomeTarget("a"), analysis, "progress")
+	wp.Push(outcomeTarget("b"), analysis, "progress")
+	g.Expect(wp.Controllers()).To(Equal([]ControllerInfo{
+		{Identity: "ready", Priority: 1, Required: true, Handles: []schema.GroupVersionResource{gateways}},
+		{Identity: "analysis", Priority: 2, NilProgress: NilProgressSkip},
+	}))
+}
@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+const defaultDedupWindow = 5 * time.Minute
+
+// WithDedupWindow sets the rolling window over which DedupRatio is computed.  The default is five minutes.
+func WithDedupWindow(window time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.dedup.window = window
+	}
+}
+
+// DedupRatio returns the fraction of pushes over the dedup window which were merged with an already queued task, and
+// the number of pushes it is computed from.  A sudden drop usually means a controller is generating unique churn.
+func (wp *WorkerPool) DedupRatio() (ratio float64, pushes int) {
+	merged, pushes := wp.dedup.counts()
+	for _, shard := range wp.shards {
+		m, p := shard.dedup.counts()
+		merged += m
+		pushes += p
+	}
+	if pushes == 0 {
+		return 0, 0
+	}
+	return float64(merged) / float64(pushes), pushes
+}
+
+// dedupTracker maintains rolling counts of merged and total pushes over the window.
+type dedupTracker struct {
+	window time.Duration
+	clock  clock.PassiveClock
+	lock   sync.Mutex
+	merged rollingCounter
+	pushes rollingCounter
+}
+
+func newDedupTracker() *dedupTracker {
+	return &dedupTracker{
+		window: defaultDedupWindow,
+		clock:  clock.RealClock{},
+	}
+}
+
+func (d *dedupTracker) record(merged bool) {
+	now := d.clock.Now()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.pushes.add(now, d.window, 1)
+	if merged {
+		d.merged.add(now, d.window, 1)
+	}
+}
+
+func (d *dedupTracker) counts() (merged, pushes int) {
+	now := d.clock.Now()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return int(d.merged.total(now, d.window)), int(d.pushes.total(now, d.window))
+}
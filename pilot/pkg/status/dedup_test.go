@@ -0,0 +1,70 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestDedupRatio(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	wp := NewWorkerPool(nil, nil, 0, WithDedupWindow(time.Minute), func(wp *WorkerPool) {
+		wp.clock = fakeClock
+	})
+	ratio, pushes := wp.DedupRatio()
+	g.Expect(ratio).To(BeZero())
+	g.Expect(pushes).To(BeZero())
+
+	// one new target, merged three times
+	for i := 0; i < 4; i++ {
+		wp.Push(outcomeTarget("a"), &Controller{}, nil)
+	}
+	ratio, pushes = wp.DedupRatio()
+	g.Expect(ratio).To(Equal(0.75))
+	g.Expect(pushes).To(Equal(4))
+
+	// unique churn half a window later drags the ratio down
+	fakeClock.Step(30 * time.Second)
+	for i := 0; i < 4; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), &Controller{}, nil)
+	}
+	ratio, pushes = wp.DedupRatio()
+	g.Expect(ratio).To(Equal(3.0 / 8))
+	g.Expect(pushes).To(Equal(8))
+
+	// once the first pushes leave the window, only the churn is counted
+	fakeClock.Step(40 * time.Second)
+	ratio, pushes = wp.DedupRatio()
+	g.Expect(ratio).To(BeZero())
+	g.Expect(pushes).To(Equal(4))
+}
+
+func TestShardedDedupRatio(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0, WithShards(4))
+	for i := 0; i < 4; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), &Controller{}, nil)
+		wp.Push(outcomeTarget(strconv.Itoa(i)), &Controller{}, nil)
+	}
+	ratio, pushes := wp.DedupRatio()
+	g.Expect(ratio).To(Equal(0.5))
+	g.Expect(pushes).To(Equal(8))
+}
@@ -0,0 +1,176 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"istio.io/istio/pkg/config"
+)
+
+// DeleteMode decides what happens to a target which is deleted while it is queued.
+type DeleteMode int
+
+const (
+	// DeleteDrop removes the task for the target, so that nothing more is written for it.
+	DeleteDrop DeleteMode = iota
+	// DeleteProcessFinal keeps the task for the target, and when it is processed writes the status returned by the
+	// finalizer rather than applying the controllers.  DeleteNamespace always drops tasks regardless of the mode.
+	DeleteProcessFinal
+)
+
+// FinalizerFunc returns the terminal status to write for a target deleted while it was queued.
+type FinalizerFunc func(target Resource) GenerationProvider
+
+// WithDeleteMode sets what happens to a target deleted while it is queued.  finalizer must be set for
+// DeleteProcessFinal.
+func WithDeleteMode(mode DeleteMode, finalizer FinalizerFunc) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.deleteMode = mode
+		wp.finalizer = finalizer
+	}
+}
+
+// processFinal writes the finalizer status for a target which was deleted while it was queued.  If the config is
+// already gone from the store, the status is written to a config identifying the target.
+func (wp *WorkerPool) processFinal(target Resource, _ map[*Controller]interface{}) error {
+	start := wp.clock.Now()
+	write := wp.writer(target)
+	cfg, err := wp.read(target)
+	if err != nil {
+		wp.logger(target).Warnf("failed to get %s, retrying in %v: %v", target, wp.readRetryDelay, err)
+		wp.outcomes.record(target, OutcomeFailed, err)
+		wp.sendResult(target, OutcomeFailed, err, start)
+		wp.q.requeue(target, nil, wp.readRetryDelay)
+		wp.q.markDeleted(target)
+		wp.reportError(target, err, true)
+		return err
+	}
+	if cfg == nil {
+		cfg = &config.Config{Meta: ResourceToModelConfig(target)}
+	}
+	// a status staged before the target was deleted must not be written after its final status
+	wp.dropStaged(target)
+	changed, err := write(cfg, wp.finalizer(target))
+	throttled := wp.pauseIfThrottled(target, err)
+	if throttled {
+		wp.q.requeue(target, nil, 0)
+		wp.q.markDeleted(target)
+	} else {
+		// the final status is written in place of the status the pushes contributed to
+		wp.dropped(target, OutcomeDeleted)
+	}
+	recordWrite(wp.dryRun != nil, changed, err)
+	if err == nil {
+		wp.throughput.record()
+	}
+	outcome := writeOutcome(changed, err)
+	wp.outcomes.record(target, outcome, err)
+	wp.sendResult(target, outcome, err, start)
+	wp.reportError(target, err, throttled)
+	return err
+}
+
+// DeleteBatch deletes each of targets as Delete does, for example when a label selection is removed, taking the locks
+// once for the whole batch rather than once per target.  It returns the number of targets which were queued, and so
+// were removed or, with DeleteProcessFinal, marked to have a final status written.  Targets already being processed
+// are not interrupted.
+func (wp *WorkerPool) DeleteBatch(targets []Resource) int {
+	if wp.shards != nil {
+		perShard := make(map[*WorkerPool][]Resource)
+		for _, target := range targets {
+			shard := wp.shard(target)
+			perShard[shard] = append(perShard[shard], target)
+		}
+		deleted := 0
+		for shard, batch := range perShard {
+			deleted += shard.DeleteBatch(batch)
+		}
+		return deleted
+	}
+	found, marked := wp.q.deleteBatch(targets, wp.deleteMode == DeleteProcessFinal)
+	wp.lock.Lock()
+	for _, target := range targets {
+		key := convert(target)
+		delete(wp.refetches, key)
+		delete(wp.requiredWaits, key)
+		wp.forgetDependencies(key)
+		wp.notifyDeleted(key)
+	}
+	// wake Shutdown if it is draining the queue, and workers waiting for targets ordered after the deleted targets
+	wp.cond.Broadcast()
+	wp.idle.Broadcast()
+	wp.lock.Unlock()
+	for _, target := range targets {
+		if _, ok := marked[convert(target)]; !ok {
+			wp.dropped(target, OutcomeDeleted)
+		}
+		wp.outcomes.record(target, OutcomeDeleted, nil)
+		if wp.failureEvents != nil {
+			wp.failureEvents.forget(target)
+		}
+	}
+	wp.checkWatermarks()
+	return found
+}
+
+// deleteBatch removes the queued tasks for targets, or if markFinal is set marks them to have a final status written,
+// returning the number of targets which were queued and the keys of those marked.
+func (wq *WorkQueue) deleteBatch(targets []Resource, markFinal bool) (int, map[lockResource]struct{}) {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	found := 0
+	marked := make(map[lockResource]struct{})
+	removed := make(map[lockResource]struct{})
+	for _, target := range targets {
+		key := convert(target)
+		delete(wq.seen, key)
+		delete(wq.after, key)
+		if _, ok := marked[key]; ok {
+			continue
+		}
+		item, inqueue := wq.cache[key]
+		if markFinal && inqueue {
+			item.deleted = true
+			wq.cache[key] = item
+			marked[key] = struct{}{}
+			found++
+			continue
+		}
+		delete(wq.nextWrite, key)
+		if !inqueue {
+			continue
+		}
+		delete(wq.cache, key)
+		wq.removePending(item)
+		wq.endStay(&item)
+		wq.releaseProgressMap(item.perControllerStatus)
+		removed[key] = struct{}{}
+		found++
+	}
+	if len(removed) == 0 {
+		return found, marked
+	}
+	tasks := wq.tasks[:0]
+	for _, key := range wq.tasks {
+		if _, ok := removed[key]; !ok {
+			tasks = append(tasks, key)
+		}
+	}
+	// clear the tail so that removed keys are not retained by the backing array
+	for i := len(tasks); i < len(wq.tasks); i++ {
+		wq.tasks[i] = lockResource{}
+	}
+	wq.tasks = tasks
+	return found, marked
+}
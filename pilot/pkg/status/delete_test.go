@@ -0,0 +1,135 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func newDeleteTestPool(written chan string, opts ...WorkerPoolOption) *WorkerPool {
+	return NewWorkerPool(func(cfg *config.Config, status interface{}) (bool, error) {
+		s := status.(*IstioGenerationProvider)
+		reason := ""
+		if len(s.Conditions) > 0 {
+			reason = s.Conditions[0].Reason
+		}
+		written <- cfg.Name + "/" + reason
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return nil
+	}, 0, opts...)
+}
+
+func runDeleteTestPool(wp *WorkerPool) {
+	wp.lock.Lock()
+	wp.maxWorkers = 1
+	wp.lock.Unlock()
+	wp.maybeAddWorker()
+}
+
+func TestDeleteDrop(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	wp := newDeleteTestPool(written, WithProgressMapPooling())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+
+	wp.Push(outcomeTarget("a"), &Controller{}, "progress")
+	progress := wp.q.cache[convert(outcomeTarget("a"))].perControllerStatus
+	wp.Delete(outcomeTarget("a"))
+	g.Expect(wp.q.Length()).To(Equal(0))
+	// the progress map of the deleted task is released, which clears it
+	g.Expect(progress).To(BeEmpty())
+	runDeleteTestPool(wp)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+}
+
+func TestDeleteProcessFinal(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	wp := newDeleteTestPool(written, WithDeleteMode(DeleteProcessFinal, func(target Resource) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{Conditions: []*v1alpha1.IstioCondition{{Reason: "Deleted"}}}}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		panic("controllers are not applied to deleted targets")
+	}}
+
+	wp.Push(outcomeTarget("a"), c, nil)
+	wp.Delete(outcomeTarget("a"))
+	// targets which are not queued have nothing to finalize
+	wp.Delete(outcomeTarget("b"))
+	g.Expect(wp.q.Length()).To(Equal(1))
+	runDeleteTestPool(wp)
+	g.Eventually(written).Should(Receive(Equal("a/Deleted")))
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	outcome, _ := wp.LastOutcome(outcomeTarget("a"))
+	g.Expect(outcome.Type).To(Equal(OutcomeWritten))
+}
+
+func TestDeleteBatch(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	wp := newDeleteTestPool(written)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		wp.Push(outcomeTarget(name), &Controller{}, nil)
+	}
+	// absent and repeated targets are not counted
+	g.Expect(wp.DeleteBatch([]Resource{outcomeTarget("a"), outcomeTarget("c"), outcomeTarget("x"), outcomeTarget("a")})).
+		To(Equal(2))
+	g.Expect(wp.Stats().Queued).To(Equal(2))
+	g.Expect(wp.PendingProgress(outcomeTarget("a"))).To(BeNil())
+	g.Expect(wp.PendingProgress(outcomeTarget("b"))).NotTo(BeNil())
+	g.Expect(DiffSnapshots(Snapshot{}, wp.Snapshot())).To(ConsistOf(
+		"+tasks r1/r1//r1/b", "+tasks r1/r1//r1/d", "+cache r1/r1//r1/b", "+cache r1/r1//r1/d"))
+	outcome, _ := wp.LastOutcome(outcomeTarget("c"))
+	g.Expect(outcome.Type).To(Equal(OutcomeDeleted))
+	g.Expect(wp.DeleteBatch(nil)).To(Equal(0))
+
+	// with DeleteProcessFinal, queued targets are kept to have their final status written
+	wp = newDeleteTestPool(written, WithDeleteMode(DeleteProcessFinal, func(target Resource) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{Conditions: []*v1alpha1.IstioCondition{{Reason: "Deleted"}}}}
+	}))
+	wp.Run(ctx)
+	wp.Push(outcomeTarget("a"), &Controller{}, nil)
+	g.Expect(wp.DeleteBatch([]Resource{outcomeTarget("a"), outcomeTarget("b")})).To(Equal(1))
+	g.Expect(wp.q.Length()).To(Equal(1))
+	runDeleteTestPool(wp)
+	g.Eventually(written).Should(Receive(Equal("a/Deleted")))
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+
+	// sharded pools delete from each shard
+	wp = NewWorkerPool(nil, nil, 0, WithShards(4))
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		wp.Push(outcomeTarget(name), &Controller{}, nil)
+	}
+	g.Expect(wp.DeleteBatch([]Resource{outcomeTarget("a"), outcomeTarget("b"), outcomeTarget("c"), outcomeTarget("x")})).
+		To(Equal(3))
+	g.Expect(wp.Stats().Queued).To(Equal(3))
+}
@@ -0,0 +1,128 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"time"
+)
+
+const (
+	defaultDependencyRetryDelay = time.Second
+	defaultDependencyMaxWait    = 5 * time.Minute
+)
+
+// DependencyFunc reports whether a resource the status of target depends on is ready, for example whether the
+// Gateway a VirtualService is bound to has been programmed.  It is evaluated each time target is processed, and may
+// consult any state known to the caller.
+type DependencyFunc func(target Resource) bool
+
+// dependencyWait records when the write for a target was first deferred for a dependency, and how long to wait
+// before checking again.
+type dependencyWait struct {
+	since time.Time
+	delay time.Duration
+}
+
+// WithDependencyBackoff sets how long to wait before first checking the dependencies of a deferred target again, the
+// wait doubling each time they are still not ready, and how long a write may be deferred, measured from the first
+// time it was deferred.  Once maxWait has elapsed status is written regardless.
+func WithDependencyBackoff(retryDelay, maxWait time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.dependencyRetryDelay = retryDelay
+		wp.dependencyMaxWait = maxWait
+	}
+}
+
+// DependOn defers writing the status of target, for any generation, until ready reports true.  A target may have
+// several dependencies, which must all be ready.  Dependencies are removed when the target is deleted, or with
+// ClearDependencies.
+func (wp *WorkerPool) DependOn(target Resource, ready DependencyFunc) {
+	if wp.shards != nil {
+		wp.shard(target).DependOn(target, ready)
+		return
+	}
+	key := convert(target)
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	wp.dependencies[key] = append(wp.dependencies[key], ready)
+}
+
+// ClearDependencies removes the dependencies of target, so that its status is written without waiting for them.
+func (wp *WorkerPool) ClearDependencies(target Resource) {
+	if wp.shards != nil {
+		wp.shard(target).ClearDependencies(target)
+		return
+	}
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	wp.forgetDependencies(convert(target))
+}
+
+// forgetDependencies removes the dependencies of the target with key.  The caller must hold wp.lock.
+func (wp *WorkerPool) forgetDependencies(key lockResource) {
+	delete(wp.dependencies, key)
+	delete(wp.dependencyWaits, key)
+}
+
+// unready returns the number of dependencies of target which are not ready.
+func (wp *WorkerPool) unready(target Resource) int {
+	wp.lock.Lock()
+	dependencies := wp.dependencies[convert(target)]
+	wp.lock.Unlock()
+	unready := 0
+	// evaluated without holding the lock, as they consult state outside the pool
+	for _, ready := range dependencies {
+		if !ready(target) {
+			unready++
+		}
+	}
+	return unready
+}
+
+// deferForDependencies requeues target, with backoff, if any of its dependencies are not ready, until the maximum
+// dependency wait has elapsed.  It reports whether the write was deferred.
+func (wp *WorkerPool) deferForDependencies(target Resource, perControllerWork map[*Controller]interface{},
+	retained bool) bool {
+	unready := wp.unready(target)
+	key := convert(target)
+	wp.lock.Lock()
+	if unready == 0 {
+		delete(wp.dependencyWaits, key)
+		wp.lock.Unlock()
+		return false
+	}
+	now := wp.clock.Now()
+	wait, waiting := wp.dependencyWaits[key]
+	if !waiting {
+		wait = dependencyWait{since: now, delay: wp.dependencyRetryDelay}
+	}
+	if now.Sub(wait.since) >= wp.dependencyMaxWait {
+		delete(wp.dependencyWaits, key)
+		wp.lock.Unlock()
+		wp.logger(target).Warnf("writing status for %s with %d dependencies not ready after %v", target, unready, wp.dependencyMaxWait)
+		return false
+	}
+	delay := wait.delay
+	wait.delay *= 2
+	if wait.delay > wp.dependencyMaxWait {
+		wait.delay = wp.dependencyMaxWait
+	}
+	wp.dependencyWaits[key] = wait
+	wp.lock.Unlock()
+	wp.logger(target).Debugf("deferring status write for %s until %d dependencies are ready, checking again in %v", target, unready,
+		delay)
+	wp.retry(target, perControllerWork, retained, delay)
+	return true
+}
@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolDependencies(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	written := make(chan string, 2)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithDependencyBackoff(time.Second, time.Minute), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	var programmed, checks int32
+	target := outcomeTarget("vs")
+	wp.DependOn(target, func(r Resource) bool {
+		atomic.AddInt32(&checks, 1)
+		return atomic.LoadInt32(&programmed) == 1
+	})
+
+	wp.Push(target, ctl, nil)
+	g.Eventually(func() int32 { return atomic.LoadInt32(&checks) }).Should(Equal(int32(1)))
+	outcome, _ := wp.LastOutcome(target)
+	g.Expect(outcome.Type).To(Equal(OutcomeDeferred))
+	g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+	fakeClock.Step(time.Second)
+	g.Eventually(func() int32 { return atomic.LoadInt32(&checks) }).Should(Equal(int32(2)))
+	// the wait doubles while the dependency is not ready
+	g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+	fakeClock.Step(time.Second)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(atomic.LoadInt32(&checks)).To(Equal(int32(2)))
+
+	atomic.StoreInt32(&programmed, 1)
+	fakeClock.Step(time.Second)
+	g.Eventually(written).Should(Receive(Equal("vs")))
+	g.Expect(atomic.LoadInt32(&checks)).To(Equal(int32(3)))
+}
+
+func TestWorkerPoolDependencyMaxWait(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	written := make(chan string, 2)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithDependencyBackoff(time.Minute, time.Minute), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	target := outcomeTarget("vs")
+	wp.DependOn(target, func(Resource) bool { return false })
+
+	wp.Push(target, ctl, nil)
+	g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	// status is written once the dependency has been waited on for the maximum
+	fakeClock.Step(time.Minute)
+	g.Eventually(written).Should(Receive(Equal("vs")))
+}
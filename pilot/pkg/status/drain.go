@@ -0,0 +1,43 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+// DrainFinalizerFunc transforms the status computed for target, after every controller has run, into the status to
+// write when the pool is draining.
+type DrainFinalizerFunc func(target Resource, status GenerationProvider) GenerationProvider
+
+// WithDrainFinalizer passes the status of each target written while Shutdown drains the queue through finalize, so
+// that an outgoing leader can mark the status it leaves behind, for example as final for its observed generation, for
+// the incoming leader to reconcile from.  Targets not processed before the drain deadline are not written at all.
+func WithDrainFinalizer(finalize DrainFinalizerFunc) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.drainFinalizer = finalize
+	}
+}
+
+// finalizeForDrain returns x, the status computed for target, transformed by the drain finalizer if the pool is
+// draining.
+func (wp *WorkerPool) finalizeForDrain(target Resource, x GenerationProvider) GenerationProvider {
+	if wp.drainFinalizer == nil {
+		return x
+	}
+	wp.lock.Lock()
+	draining := wp.draining
+	wp.lock.Unlock()
+	if !draining {
+		return x
+	}
+	return wp.drainFinalizer(target, x)
+}
@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolDrainFinalizer(t *testing.T) {
+	g := NewGomegaWithT(t)
+	release := make(chan struct{})
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, status interface{}) (bool, error) {
+		if cfg.Name == "busy" {
+			<-release
+		}
+		reason := ""
+		if conditions := status.(*IstioGenerationProvider).Conditions; len(conditions) > 0 {
+			reason = conditions[0].Reason
+		}
+		written <- cfg.Name + "/" + reason
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithDrainFinalizer(func(target Resource, status GenerationProvider) GenerationProvider {
+		x := status.(*IstioGenerationProvider)
+		x.Conditions = append(x.Conditions, &v1alpha1.IstioCondition{Type: "Reconciled", Reason: "Handoff"})
+		return x
+	}))
+	wp.Run(context.Background())
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{}}
+	}}
+
+	wp.Push(outcomeTarget("early"), c, nil)
+	g.Eventually(written).Should(Receive(Equal("early/")))
+	wp.Push(outcomeTarget("busy"), c, nil)
+	g.Eventually(func() int { return wp.Stats().InFlight }).Should(Equal(1))
+	wp.Push(outcomeTarget("queued"), c, nil)
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- wp.Shutdown(context.Background(), true)
+	}()
+	g.Eventually(func() bool {
+		wp.lock.Lock()
+		defer wp.lock.Unlock()
+		return wp.draining
+	}).Should(BeTrue())
+	close(release)
+	// the status of the busy target was computed before the drain began
+	g.Eventually(written).Should(Receive(Equal("busy/")))
+	g.Eventually(written).Should(Receive(Equal("queued/Handoff")))
+	g.Eventually(shutdown).Should(Receive(BeNil()))
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+}
@@ -0,0 +1,175 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// QueueDump is a snapshot of the targets a WorkerPool is holding, in a form which can be archived as JSON.
+type QueueDump struct {
+	// Pending are the queued targets, ordered by key.
+	Pending []DumpedTarget `json:"pending"`
+	// InFlight are the targets being processed, ordered by key.
+	InFlight []DumpedTarget `json:"inFlight"`
+	// Operations are the recent operations kept by WithOperationTrace, oldest first.
+	Operations []DumpedOperation `json:"operations,omitempty"`
+}
+
+// DumpedTarget is a target in a QueueDump.
+type DumpedTarget struct {
+	Group         string `json:"group"`
+	Version       string `json:"version"`
+	Resource      string `json:"resource"`
+	Namespace     string `json:"namespace,omitempty"`
+	Name          string `json:"name"`
+	ClusterScoped bool   `json:"clusterScoped,omitempty"`
+	Cluster       string `json:"cluster,omitempty"`
+	Generation    string `json:"generation"`
+	// Since is when a pending target was first pushed, or when an in-flight target started processing.
+	Since time.Time `json:"since"`
+	// Deleted is set for a pending target deleted while queued, whose final status is still to be written.
+	Deleted bool `json:"deleted,omitempty"`
+	// RetainedGenerations are the earlier generations queued ahead of Generation by WithOrderedGenerations.
+	RetainedGenerations []string `json:"retainedGenerations,omitempty"`
+	// Controllers is the progress each controller has queued for a pending target, ordered by controller.
+	Controllers []DumpedProgress `json:"controllers,omitempty"`
+}
+
+// DumpedProgress is the progress a controller has queued for a target.  Progress is its string form if it is a
+// fmt.Stringer or a basic value, and otherwise only its type, as arbitrary progress values may not serialize.
+type DumpedProgress struct {
+	Controller string `json:"controller"`
+	Progress   string `json:"progress"`
+}
+
+// DumpedOperation is an Operation in a QueueDump.
+type DumpedOperation struct {
+	Operation  string    `json:"operation"`
+	Time       time.Time `json:"time"`
+	Group      string    `json:"group"`
+	Version    string    `json:"version"`
+	Resource   string    `json:"resource"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	Generation string    `json:"generation"`
+	Detail     string    `json:"detail,omitempty"`
+}
+
+// DumpJSON writes a QueueDump of the pool to w as JSON, for analysis after an incident.  The snapshot of each shard
+// is consistent, but a sharded pool's shards are not snapshotted at the same instant.
+func (wp *WorkerPool) DumpJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(wp.dump())
+}
+
+func (wp *WorkerPool) dump() QueueDump {
+	var out QueueDump
+	for _, shard := range wp.shards {
+		d := shard.dump()
+		out.Pending = append(out.Pending, d.Pending...)
+		out.InFlight = append(out.InFlight, d.InFlight...)
+	}
+	wp.lock.Lock()
+	wp.q.lock.Lock()
+	for _, item := range wp.q.cache {
+		d := dumpTarget(item.cacheResource, item.firstPushed)
+		d.Deleted = item.deleted
+		for _, older := range item.older {
+			d.RetainedGenerations = append(d.RetainedGenerations, older.cacheResource.Generation)
+		}
+		for c, progress := range item.perControllerStatus {
+			d.Controllers = append(d.Controllers, DumpedProgress{Controller: c.Identity(), Progress: dumpProgress(progress)})
+		}
+		sort.Slice(d.Controllers, func(i, j int) bool {
+			return d.Controllers[i].Controller < d.Controllers[j].Controller
+		})
+		out.Pending = append(out.Pending, d)
+	}
+	wp.q.lock.Unlock()
+	for _, w := range wp.working {
+		out.InFlight = append(out.InFlight, dumpTarget(w.target, w.since))
+	}
+	wp.lock.Unlock()
+	sortDumped(out.Pending)
+	sortDumped(out.InFlight)
+	for _, op := range wp.RecentOperations() {
+		out.Operations = append(out.Operations, DumpedOperation{
+			Operation:  op.Type.String(),
+			Time:       op.Time,
+			Group:      op.Target.Group,
+			Version:    op.Target.Version,
+			Resource:   op.Target.Resource,
+			Namespace:  op.Target.Namespace,
+			Name:       op.Target.Name,
+			Generation: op.Target.Generation,
+			Detail:     op.Detail,
+		})
+	}
+	return out
+}
+
+func dumpTarget(target Resource, since time.Time) DumpedTarget {
+	return DumpedTarget{
+		Group:         target.Group,
+		Version:       target.Version,
+		Resource:      target.Resource,
+		Namespace:     target.Namespace,
+		Name:          target.Name,
+		ClusterScoped: target.ClusterScoped,
+		Cluster:       target.Cluster,
+		Generation:    target.Generation,
+		Since:         since,
+	}
+}
+
+// dumpProgress returns a representation of progress which is safe to serialize.
+func dumpProgress(progress interface{}) string {
+	if progress == nil {
+		return ""
+	}
+	if s, ok := progress.(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch reflect.TypeOf(progress).Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(progress)
+	}
+	return fmt.Sprintf("%T", progress)
+}
+
+func sortDumped(targets []DumpedTarget) {
+	key := func(d DumpedTarget) lockResource {
+		return convert(Resource{
+			GroupVersionResource: schema.GroupVersionResource{Group: d.Group, Version: d.Version, Resource: d.Resource},
+			Namespace:            d.Namespace,
+			Name:                 d.Name,
+			ClusterScoped:        d.ClusterScoped,
+			Cluster:              d.Cluster,
+		})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return key(targets[i]).less(key(targets[j]))
+	})
+}
@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolDumpJSON(t *testing.T) {
+	g := NewGomegaWithT(t)
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clocktesting.NewFakeClock(now)
+	release := make(chan struct{})
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		<-release
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithOrderedGenerations(2), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	defer close(release)
+	target := func(name, generation string) Resource {
+		return Resource{
+			GroupVersionResource: schema.GroupVersionResource{Group: "g", Version: "v", Resource: "r"},
+			Namespace:            "ns",
+			Name:                 name,
+			Generation:           generation,
+		}
+	}
+	apply := func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}
+	a := &Controller{Name: "a", fn: apply}
+	b := &Controller{Name: "b", fn: apply}
+
+	wp.Push(target("busy", "1"), a, 1)
+	g.Eventually(wp.InFlight).Should(HaveLen(1))
+	wp.Hold(target("held", "1"))
+	wp.Push(target("held", "1"), a, 1)
+	wp.Push(target("held", "2"), a, 2)
+	wp.Push(target("held", "2"), b, struct{ unexported int }{})
+
+	var buf bytes.Buffer
+	g.Expect(wp.DumpJSON(&buf)).To(Succeed())
+	var dump QueueDump
+	g.Expect(json.Unmarshal(buf.Bytes(), &dump)).To(Succeed())
+	g.Expect(dump).To(Equal(QueueDump{
+		Pending: []DumpedTarget{{
+			Group:               "g",
+			Version:             "v",
+			Resource:            "r",
+			Namespace:           "ns",
+			Name:                "held",
+			Generation:          "2",
+			Since:               now,
+			RetainedGenerations: []string{"1"},
+			Controllers: []DumpedProgress{
+				{Controller: "a", Progress: "2"},
+				{Controller: "b", Progress: "struct { unexported int }"},
+			},
+		}},
+		InFlight: []DumpedTarget{{
+			Group:      "g",
+			Version:    "v",
+			Resource:   "r",
+			Namespace:  "ns",
+			Name:       "busy",
+			Generation: "1",
+			Since:      now,
+		}},
+	}))
+}
@@ -0,0 +1,43 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+// EmptyProgressPolicy decides what happens to a target with no progress from any controller when it is processed,
+// because every contribution was retracted while it was queued.
+type EmptyProgressPolicy int
+
+const (
+	// EmptyProgressSkip does not write status for the target, recording OutcomeEmpty.
+	EmptyProgressSkip EmptyProgressPolicy = iota
+	// EmptyProgressWrite writes the stored status with the observed generation refreshed.
+	EmptyProgressWrite
+)
+
+// WithEmptyProgress sets what happens to a target with no progress from any controller when it is processed.  By
+// default its status is not written.
+func WithEmptyProgress(policy EmptyProgressPolicy) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.emptyProgress = policy
+	}
+}
+
+// skipEmpty reports whether target should not be written as it has no progress from any controller.
+func (wp *WorkerPool) skipEmpty(target Resource, perControllerWork map[*Controller]interface{}) bool {
+	if len(perControllerWork) > 0 || wp.emptyProgress == EmptyProgressWrite {
+		return false
+	}
+	wp.logger(target).Debugf("not writing status for %s, which has no progress from any controller", target)
+	return true
+}
@@ -0,0 +1,69 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolEmptyProgress(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		policy  EmptyProgressPolicy
+		outcome OutcomeType
+	}{
+		{name: "skip", policy: EmptyProgressSkip, outcome: OutcomeEmpty},
+		{name: "write", policy: EmptyProgressWrite, outcome: OutcomeWritten},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			written := make(chan int64, 1)
+			wp := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+				written <- status.(*IstioGenerationProvider).ObservedGeneration
+				return true, nil
+			}, func(r Resource) *config.Config {
+				return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}, Status: &v1alpha1.IstioStatus{}}
+			}, 1, WithEmptyProgress(tt.policy))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			wp.Run(ctx)
+			ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+				return &IstioGenerationProvider{}
+			}}
+			target := outcomeTarget("a")
+
+			// every contribution is retracted before the target is processed
+			wp.Hold(target)
+			g.Expect(wp.PushCancelable(target, ctl, nil).Cancel()).To(BeTrue())
+			wp.Release(target)
+			g.Eventually(func() OutcomeType {
+				outcome, _ := wp.LastOutcome(target)
+				return outcome.Type
+			}).Should(Equal(tt.outcome))
+			if tt.policy == EmptyProgressWrite {
+				g.Expect(written).To(Receive(Equal(int64(1))))
+			} else {
+				g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+			}
+		})
+	}
+}
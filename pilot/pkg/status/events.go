@@ -0,0 +1,126 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+)
+
+// StatusWriteFailedReason is the reason set on events emitted for targets whose status repeatedly fails to write.
+const StatusWriteFailedReason = "StatusWriteFailed"
+
+// WithFailureEvents emits a Warning event against a target once its status write has failed threshold times in a
+// row.  Further events for the same target are emitted at most once per interval until a write succeeds.
+func WithFailureEvents(recorder record.EventRecorder, threshold int, interval time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.failureEvents = &failureEventRecorder{
+			recorder:  recorder,
+			threshold: threshold,
+			interval:  interval,
+			clock:     clock.RealClock{},
+			failures:  make(map[lockResource]int),
+			lastEvent: make(map[lockResource]time.Time),
+		}
+	}
+}
+
+// failureEventRecorder tracks consecutive write failures per target, and throttles the events emitted for them.
+type failureEventRecorder struct {
+	recorder  record.EventRecorder
+	threshold int
+	interval  time.Duration
+	clock     clock.PassiveClock
+	failures  map[lockResource]int
+	lastEvent map[lockResource]time.Time
+	lock      sync.Mutex
+}
+
+// record registers the outcome of a single write for target, emitting an event if the failure threshold is reached.
+func (f *failureEventRecorder) record(target Resource, err error) {
+	key := convert(target)
+	if err == nil {
+		f.lock.Lock()
+		delete(f.failures, key)
+		delete(f.lastEvent, key)
+		f.lock.Unlock()
+		return
+	}
+	f.lock.Lock()
+	f.failures[key]++
+	count := f.failures[key]
+	now := f.clock.Now()
+	last, emitted := f.lastEvent[key]
+	if count < f.threshold || (emitted && now.Sub(last) < f.interval) {
+		f.lock.Unlock()
+		return
+	}
+	f.lastEvent[key] = now
+	f.lock.Unlock()
+	f.recorder.Event(objectReference(target), corev1.EventTypeWarning, StatusWriteFailedReason,
+		fmt.Sprintf("failed to write status %d consecutive times: %v", count, err))
+}
+
+// forget drops any failure tracking for target, e.g. because it has been deleted.
+func (f *failureEventRecorder) forget(target Resource) {
+	key := convert(target)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.failures, key)
+	delete(f.lastEvent, key)
+}
+
+// objectReference builds a reference to the object identified by target, suitable for attaching events to.
+func objectReference(target Resource) *corev1.ObjectReference {
+	gvk := GVRtoGVK(target.GroupVersionResource)
+	return &corev1.ObjectReference{
+		APIVersion: target.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  target.Namespace,
+		Name:       target.Name,
+	}
+}
+
+// ErrorReport selects which processing failures are passed to the function set by WithOnError.
+type ErrorReport int
+
+const (
+	// ReportEveryFailure reports every failed attempt, including failures to retrieve a config, which are retried.
+	ReportEveryFailure ErrorReport = iota
+	// ReportFinalFailure only reports failures which will not be retried, such as write errors.
+	ReportFinalFailure
+)
+
+// WithOnError sets a function invoked whenever processing a target fails, as the simplest way to see processing
+// errors without a results channel, events or metrics.  It is invoked by the worker without holding the pool's lock.
+func WithOnError(onError func(target Resource, err error), report ErrorReport) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.onError = onError
+		wp.errorReport = report
+	}
+}
+
+// reportError passes a processing failure to onError, if it is set and report selects it.
+func (wp *WorkerPool) reportError(target Resource, err error, retried bool) {
+	if err == nil || wp.onError == nil || (retried && wp.errorReport == ReportFinalFailure) {
+		return
+	}
+	wp.onError(target, err)
+}
@@ -0,0 +1,139 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+func TestFailureEventRecorder(t *testing.T) {
+	g := NewGomegaWithT(t)
+	target := Resource{
+		GroupVersionResource: collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionResource(),
+		Namespace:            "default",
+		Name:                 "vs",
+		Generation:           "1",
+	}
+	recorder := record.NewFakeRecorder(10)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	wp := NewWorkerPool(nil, nil, 1, WithFailureEvents(recorder, 2, time.Minute))
+	f := wp.failureEvents
+	f.clock = fakeClock
+	writeErr := errors.New("conflict")
+
+	f.record(target, writeErr)
+	g.Expect(recorder.Events).To(HaveLen(0))
+	f.record(target, writeErr)
+	g.Expect(recorder.Events).To(HaveLen(1))
+	g.Expect(<-recorder.Events).To(Equal("Warning StatusWriteFailed failed to write status 2 consecutive times: conflict"))
+
+	// throttled until the interval has elapsed
+	f.record(target, writeErr)
+	g.Expect(recorder.Events).To(HaveLen(0))
+	fakeClock.Step(time.Minute)
+	f.record(target, writeErr)
+	g.Expect(recorder.Events).To(HaveLen(1))
+	<-recorder.Events
+
+	// a success resets the consecutive failure count
+	f.record(target, nil)
+	f.record(target, writeErr)
+	g.Expect(recorder.Events).To(HaveLen(0))
+}
+
+func TestWorkerPoolEmitsFailureEvents(t *testing.T) {
+	g := NewGomegaWithT(t)
+	target := Resource{
+		GroupVersionResource: collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionResource(),
+		Namespace:            "default",
+		Name:                 "vs",
+		Generation:           "1",
+	}
+	recorder := record.NewFakeRecorder(10)
+	writes := make(chan struct{}, 10)
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		writes <- struct{}{}
+		return false, errors.New("conflict")
+	}, func(Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Generation: 1}}
+	}, 1, WithFailureEvents(recorder, 3, time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	for i := 0; i < 3; i++ {
+		wp.Push(target, c, nil)
+		<-writes
+	}
+	g.Eventually(recorder.Events).Should(Receive(ContainSubstring(StatusWriteFailedReason)))
+}
+
+func TestWorkerPoolOnError(t *testing.T) {
+	type failure struct {
+		target string
+		err    string
+	}
+	for _, report := range []ErrorReport{ReportEveryFailure, ReportFinalFailure} {
+		g := NewGomegaWithT(t)
+		failures := make(chan failure, 3)
+		written := make(chan string, 3)
+		panicked := false
+		wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+			written <- cfg.Name
+			if cfg.Name == "bad" {
+				return false, errors.New("conflict")
+			}
+			return true, nil
+		}, func(r Resource) *config.Config {
+			if r.Name == "flaky" && !panicked {
+				panicked = true
+				panic("store is being swapped")
+			}
+			return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+		}, 1, WithReadRetryDelay(0), WithOnError(func(target Resource, err error) {
+			failures <- failure{target.Name, err.Error()}
+		}, report))
+		ctx, cancel := context.WithCancel(context.Background())
+		wp.Run(ctx)
+		ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+			return &IstioGenerationProvider{}
+		}}
+		for _, name := range []string{"good", "bad", "flaky"} {
+			wp.Push(outcomeTarget(name), ctl, nil)
+			g.Eventually(written).Should(Receive(Equal(name)))
+		}
+		if report == ReportEveryFailure {
+			g.Eventually(failures).Should(Receive(Equal(failure{"bad", "conflict"})))
+			g.Eventually(failures).Should(Receive(Equal(failure{"flaky", "get panicked: store is being swapped"})))
+		} else {
+			// the failure to get the config was retried, so only the write failure is final
+			g.Eventually(failures).Should(Receive(Equal(failure{"bad", "conflict"})))
+		}
+		g.Consistently(failures, 100*time.Millisecond).ShouldNot(Receive())
+		cancel()
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"sync"
+)
+
+// WithExclusiveProcessingCheck verifies at runtime that no target is ever processed by two routines at once, the
+// invariant the pool maintains through currentlyWorking, to catch regressions in its locking during development and
+// in CI.  The check is independent of the pool's locks: each target is marked as it starts being processed, by a
+// worker, ProcessWithController, EndSync or a transaction commit, and unmarked as it ends.  A target found already
+// marked is logged as an error and passed to onViolation, or, if onViolation is nil, the pool panics.  Releasing a
+// stuck worker with WithInFlightTimeout deliberately lets a target be processed again, and is reported too if the
+// stuck worker is still running.  It adds a map operation per target processed.
+func WithExclusiveProcessingCheck(onViolation func(target Resource)) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.exclusivity = &exclusivityCheck{onViolation: onViolation}
+	}
+}
+
+// exclusivityCheck tracks the targets being processed, to detect any processed concurrently.
+type exclusivityCheck struct {
+	active      sync.Map
+	onViolation func(target Resource)
+}
+
+// begin marks target as being processed, reporting a violation if it already is.
+func (e *exclusivityCheck) begin(target Resource) {
+	if e == nil {
+		return
+	}
+	if _, loaded := e.active.LoadOrStore(convert(target), struct{}{}); !loaded {
+		return
+	}
+	scope.Errorf("%s is being processed concurrently, the pool has processed a target twice at once", target)
+	if e.onViolation == nil {
+		panic(fmt.Sprintf("status: %s processed concurrently", target))
+	}
+	e.onViolation(target)
+}
+
+// end unmarks target as being processed.
+func (e *exclusivityCheck) end(target Resource) {
+	if e == nil {
+		return
+	}
+	e.active.Delete(convert(target))
+}
@@ -0,0 +1,66 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolExclusiveProcessingCheck(t *testing.T) {
+	g := NewGomegaWithT(t)
+	violations := make(chan string, 10)
+	block := make(chan struct{})
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		if cfg.Name == "blocked" {
+			<-block
+		}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 5, WithExclusiveProcessingCheck(func(target Resource) {
+		violations <- target.Name
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	// the pool keeps the invariant under concurrent pushes of the same targets
+	for i := 0; i < 200; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i%3)), ctl, strconv.Itoa(i))
+	}
+	g.Eventually(func() int { return wp.Stats().Queued + wp.Stats().InFlight }).Should(BeZero())
+	g.Expect(violations).NotTo(Receive())
+
+	// breaking the invariant, as a bug in the locking would, trips the check
+	wp.Push(outcomeTarget("blocked"), ctl, nil)
+	g.Eventually(func() int { return wp.Stats().InFlight }).Should(Equal(1))
+	wp.lock.Lock()
+	delete(wp.currentlyWorking, convert(outcomeTarget("blocked")))
+	wp.lock.Unlock()
+	wp.Push(outcomeTarget("blocked"), ctl, nil)
+	g.Eventually(violations).Should(Receive(Equal("blocked")))
+	close(block)
+	g.Consistently(violations, 100*time.Millisecond).ShouldNot(Receive())
+}
@@ -0,0 +1,122 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"reflect"
+
+	"istio.io/istio/pkg/config"
+)
+
+// GroupedWriteFunc persists the same computed status for several configs at once, for example with a single
+// label-selector based patch.  It reports whether a change was persisted, and an error which applies to every config.
+type GroupedWriteFunc func(cfgs []*config.Config, status interface{}) (changed bool, err error)
+
+// StatusEqualFunc reports whether two computed statuses, as they would be passed to a WriteFunc, are identical.  It
+// must be an equivalence relation: reflexive, symmetric and transitive.
+type StatusEqualFunc func(a, b interface{}) bool
+
+// WithGroupedWrites writes statuses staged by BeginSync in groups when EndSync is called: the staged statuses for
+// which equal reports true are written with a single call to writeGrouped, with the status of the target staged first
+// in the group.  Staged statuses identical to no other are written individually, as they are by default.  If equal is
+// nil, statuses are compared with reflect.DeepEqual after unwrapping, which may not group statuses which are equal but
+// carry different internal state.  Grouped writes are not used with WithDryRun, a Marshaler or WithClusterWrites,
+// whose writes are inherently per config.
+func WithGroupedWrites(writeGrouped GroupedWriteFunc, equal StatusEqualFunc) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.writeGrouped = writeGrouped
+		if equal == nil {
+			equal = unwrappedDeepEqual
+		}
+		wp.statusEqual = equal
+	}
+}
+
+func unwrappedDeepEqual(a, b interface{}) bool {
+	if x, ok := a.(GenerationProvider); ok && x != nil {
+		a = x.Unwrap()
+	}
+	if x, ok := b.(GenerationProvider); ok && x != nil {
+		b = x.Unwrap()
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// groupIdentical partitions staged into groups of identical statuses, ordered by their first member, preserving the
+// order of members within each group.
+func groupIdentical(staged []stagedWrite, equal StatusEqualFunc) [][]stagedWrite {
+	var groups [][]stagedWrite
+	for _, s := range staged {
+		found := false
+		for i, group := range groups {
+			if equal(group[0].x, s.x) {
+				groups[i] = append(group, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []stagedWrite{s})
+		}
+	}
+	return groups
+}
+
+// writeStaged writes the statuses staged during a sync, in groups of identical statuses if grouped writes are set.
+func (wp *WorkerPool) writeStaged(staged []stagedWrite) {
+	for _, s := range staged {
+		wp.exclusivity.begin(s.target)
+	}
+	if wp.writeGrouped == nil || wp.dryRun != nil || wp.marshaler != nil || wp.clusterWrite != nil {
+		for _, s := range staged {
+			throttled, _ := wp.writeStatus(wp.writer(s.target), s.target, s.cfg, s.stored, s.x, s.controllers,
+				wp.clock.Now(), true)
+			wp.finishStaged(s, throttled)
+		}
+		return
+	}
+	for _, group := range groupIdentical(staged, wp.statusEqual) {
+		start := wp.clock.Now()
+		if len(group) == 1 {
+			s := group[0]
+			throttled, _ := wp.writeStatus(wp.writer(s.target), s.target, s.cfg, s.stored, s.x, s.controllers, start, true)
+			wp.finishStaged(s, throttled)
+			continue
+		}
+		cfgs := make([]*config.Config, 0, len(group))
+		for _, s := range group {
+			cfgs = append(cfgs, s.cfg)
+		}
+		changed, err := wp.writeGrouped(cfgs, group[0].x)
+		for _, s := range group {
+			throttled := wp.recordWrite(s.target, s.stored, group[0].x, s.controllers, changed, err, start, true)
+			wp.finishStaged(s, throttled)
+		}
+	}
+}
+
+// finishStaged releases the claim EndSync took on the target of s once its staged status has been written, first
+// requeueing the target if the write was throttled.
+func (wp *WorkerPool) finishStaged(s stagedWrite, throttled bool) {
+	if throttled {
+		wp.retry(s.target, s.progress, s.retained, 0)
+	}
+	wp.exclusivity.end(s.target)
+	wp.finish(s.target, s.claim)
+	if throttled {
+		// EndSync runs outside the workers, which may all have exited
+		wp.maybeAddWorker()
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolGroupedWrites(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	var grouped [][]string
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithGroupedWrites(func(cfgs []*config.Config, status interface{}) (bool, error) {
+		var names []string
+		for _, cfg := range cfgs {
+			names = append(names, cfg.Name+"/"+status.(*IstioGenerationProvider).Conditions[0].Reason)
+		}
+		grouped = append(grouped, names)
+		return true, nil
+	}, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{
+			Conditions: []*v1alpha1.IstioCondition{{Type: "Reconciled", Reason: context.(string)}},
+		}}
+	}}
+
+	wp.BeginSync()
+	for name, reason := range map[string]string{"a": "Accepted", "b": "Invalid", "c": "Accepted", "d": "Accepted"} {
+		wp.Push(outcomeTarget(name), ctl, reason)
+	}
+	g.Eventually(func() []OutcomeType {
+		var outcomes []OutcomeType
+		for _, name := range []string{"a", "b", "c", "d"} {
+			outcome, _ := wp.LastOutcome(outcomeTarget(name))
+			outcomes = append(outcomes, outcome.Type)
+		}
+		return outcomes
+	}).Should(Equal([]OutcomeType{OutcomeStaged, OutcomeStaged, OutcomeStaged, OutcomeStaged}))
+	wp.EndSync()
+
+	// identical statuses are written with one call, the others individually
+	g.Expect(grouped).To(HaveLen(1))
+	g.Expect(grouped[0]).To(ConsistOf("a/Accepted", "c/Accepted", "d/Accepted"))
+	g.Expect(written).To(Receive(Equal("b")))
+	g.Expect(written).NotTo(Receive())
+	for _, name := range []string{"a", "b", "c", "d"} {
+		outcome, _ := wp.LastOutcome(outcomeTarget(name))
+		g.Expect(outcome.Type).To(Equal(OutcomeWritten))
+	}
+}
+
+func TestGroupIdentical(t *testing.T) {
+	g := NewGomegaWithT(t)
+	staged := func(name, reason string) stagedWrite {
+		return stagedWrite{target: outcomeTarget(name), x: &IstioGenerationProvider{&v1alpha1.IstioStatus{
+			Conditions: []*v1alpha1.IstioCondition{{Reason: reason}},
+		}}}
+	}
+	groups := groupIdentical([]stagedWrite{
+		staged("a", "x"), staged("b", "y"), staged("c", "x"), staged("d", "z"), staged("e", "y"),
+	}, unwrappedDeepEqual)
+	var names [][]string
+	for _, group := range groups {
+		var members []string
+		for _, s := range group {
+			members = append(members, s.target.Name)
+		}
+		names = append(names, members)
+	}
+	g.Expect(names).To(Equal([][]string{{"a", "c"}, {"b", "e"}, {"d"}}))
+}
@@ -0,0 +1,132 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultMaxHealthyBacklog = 10000
+	defaultBacklogGrace      = time.Minute
+	defaultWedgedAfter       = 5 * time.Minute
+	defaultStallThreshold    = 1000
+	defaultStallGrace        = time.Minute
+)
+
+// WithHealthThresholds sets when the pool reports itself unhealthy: when more than maxBacklog targets have been
+// queued for at least grace, or when a worker has been processing a single target for at least wedgedAfter.  The
+// defaults are a backlog of 10000 for one minute, and five minutes.  A zero maxBacklog or wedgedAfter disables the
+// corresponding check.
+func WithHealthThresholds(maxBacklog int, grace, wedgedAfter time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.maxHealthyBacklog = maxBacklog
+		wp.backlogGrace = grace
+		wp.wedgedAfter = wedgedAfter
+	}
+}
+
+// Healthy returns an error describing why the pool is unhealthy, or nil.  The backlog is sampled when Healthy is
+// called, so it is only considered sustained if it is above the threshold every time Healthy is called over the grace
+// period; callers should call it periodically, as readiness probes do.
+func (wp *WorkerPool) Healthy() error {
+	for _, shard := range wp.shards {
+		if err := shard.Healthy(); err != nil {
+			return err
+		}
+	}
+	if wp.shards != nil {
+		return nil
+	}
+	now := wp.clock.Now()
+	queued := wp.q.Length()
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	if wp.maxHealthyBacklog > 0 && queued > wp.maxHealthyBacklog {
+		if wp.backlogSince.IsZero() {
+			wp.backlogSince = now
+		}
+		if backlogged := now.Sub(wp.backlogSince); backlogged >= wp.backlogGrace {
+			return fmt.Errorf("%d status updates queued, above %d for %v", queued, wp.maxHealthyBacklog, backlogged)
+		}
+	} else {
+		wp.backlogSince = time.Time{}
+	}
+	if wp.wedgedAfter > 0 {
+		for key, w := range wp.working {
+			if working := now.Sub(w.since); working >= wp.wedgedAfter {
+				return fmt.Errorf("status worker has been processing %s/%s for %v", key.Namespace, key.Name, working)
+			}
+		}
+	}
+	return nil
+}
+
+// WithStallDetection sets when a push reports the pool as stalled: when more than threshold targets have been queued
+// with no worker running for at least grace, which almost certainly means maxWorkers is zero or the pool has been
+// stopped while pushes continue.  A stall is logged as an error and passed to onStalled, which may be nil, once until
+// workers run again or the queue shrinks.  The defaults are 1000 targets for one minute, without a callback.  A zero
+// threshold disables detection.
+func WithStallDetection(threshold int, grace time.Duration, onStalled func(queued int)) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.stallThreshold = threshold
+		wp.stallGrace = grace
+		wp.onStalled = onStalled
+	}
+}
+
+// checkStalled reports the pool as stalled if the queue has grown past the stall threshold with no workers for the
+// grace period.
+func (wp *WorkerPool) checkStalled() {
+	if wp.stallThreshold <= 0 {
+		return
+	}
+	now := wp.clock.Now()
+	queued := wp.q.Length()
+	wp.lock.Lock()
+	if wp.workerCount > 0 || queued <= wp.stallThreshold {
+		wp.stalledSince = time.Time{}
+		wp.stallReported = false
+		wp.lock.Unlock()
+		return
+	}
+	if wp.stalledSince.IsZero() {
+		wp.stalledSince = now
+	}
+	stalled := now.Sub(wp.stalledSince)
+	if wp.stallReported || stalled < wp.stallGrace {
+		wp.lock.Unlock()
+		return
+	}
+	wp.stallReported = true
+	wp.lock.Unlock()
+	scope.Errorf("%d status updates queued with no workers for %v: the status worker pool has no workers or has stopped, "+
+		"and status will not be written", queued, stalled)
+	if wp.onStalled != nil {
+		wp.onStalled(queued)
+	}
+}
+
+// ReadinessProbe returns a probe reporting whether the pool is healthy, compatible with the readiness probes of the
+// Pilot server.
+func (wp *WorkerPool) ReadinessProbe() func() (bool, error) {
+	return func() (bool, error) {
+		if err := wp.Healthy(); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestReadinessProbeBacklog(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	wp := NewWorkerPool(nil, nil, 0, WithHealthThresholds(2, time.Minute, 0), func(wp *WorkerPool) {
+		wp.clock = fakeClock
+	})
+	probe := wp.ReadinessProbe()
+	ready, err := probe()
+	g.Expect(ready).To(BeTrue())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for i := 0; i < 3; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), &Controller{}, nil)
+	}
+	// a backlog is tolerated for the grace period
+	ready, _ = probe()
+	g.Expect(ready).To(BeTrue())
+	fakeClock.Step(time.Minute)
+	ready, err = probe()
+	g.Expect(ready).To(BeFalse())
+	g.Expect(err).To(MatchError(ContainSubstring("3 status updates queued")))
+
+	// draining the backlog restores readiness, and restarts the grace period
+	wp.Delete(outcomeTarget("0"))
+	ready, _ = probe()
+	g.Expect(ready).To(BeTrue())
+	wp.Push(outcomeTarget("0"), &Controller{}, nil)
+	ready, _ = probe()
+	g.Expect(ready).To(BeTrue())
+}
+
+func TestReadinessProbeWedgedWorker(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	release := make(chan struct{})
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		<-release
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithHealthThresholds(0, 0, time.Minute), func(wp *WorkerPool) {
+		wp.clock = fakeClock
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	probe := wp.ReadinessProbe()
+	wp.Push(outcomeTarget("a"), &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}, nil)
+	g.Eventually(func() int { return wp.Stats().InFlight }).Should(Equal(1))
+	ready, _ := probe()
+	g.Expect(ready).To(BeTrue())
+
+	fakeClock.Step(time.Minute)
+	ready, err := probe()
+	g.Expect(ready).To(BeFalse())
+	g.Expect(err).To(MatchError(ContainSubstring("processing r1/a")))
+
+	close(release)
+	g.Eventually(probe).Should(BeTrue())
+}
+
+func TestStallDetection(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	stalled := make(chan int, 2)
+	// a pool without workers, so pushes are never processed
+	wp := NewWorkerPool(nil, nil, 0, WithStallDetection(2, time.Minute, func(queued int) {
+		stalled <- queued
+	}), func(wp *WorkerPool) {
+		wp.clock = fakeClock
+	})
+	for i := 0; i < 3; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), &Controller{}, nil)
+	}
+	// the queue is tolerated for the grace period
+	g.Expect(stalled).NotTo(Receive())
+	fakeClock.Step(time.Minute)
+	wp.Push(outcomeTarget("3"), &Controller{}, nil)
+	g.Expect(stalled).To(Receive(Equal(4)))
+
+	// a stall is only reported once
+	wp.Push(outcomeTarget("4"), &Controller{}, nil)
+	g.Expect(stalled).NotTo(Receive())
+
+	// once the queue shrinks, a new stall is reported again after the grace period
+	for i := 0; i < 5; i++ {
+		wp.Delete(outcomeTarget(strconv.Itoa(i)))
+	}
+	wp.Push(outcomeTarget("0"), &Controller{}, nil)
+	wp.Push(outcomeTarget("1"), &Controller{}, nil)
+	fakeClock.Step(time.Minute)
+	wp.Push(outcomeTarget("2"), &Controller{}, nil)
+	g.Expect(stalled).NotTo(Receive())
+	fakeClock.Step(time.Minute)
+	wp.Push(outcomeTarget("3"), &Controller{}, nil)
+	g.Expect(stalled).To(Receive(Equal(4)))
+}
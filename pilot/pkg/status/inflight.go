@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"sync"
+	"time"
+)
+
+// InFlightTracker records which targets are being processed, so that a target is processed by at most one worker at a
+// time.  A WorkerPool always excludes targets its own workers are processing; a tracker shared with other pools, for
+// example one backed by a lease per resource, extends that guarantee across pools and replicas.
+//
+// Targets are identified by group, version, resource, namespace and name; implementations must ignore Generation.
+// TryAcquire must be atomic with respect to every pool sharing the tracker: at most one caller may hold a target at a
+// time.  Release is only called by the holder, and must be safe to call for a target which is no longer held, for
+// example because its lease expired.  Implementations backed by leases should expire them if the holder goes away,
+// otherwise the target is never processed again.  Contains may be stale, and is only advisory.
+type InFlightTracker interface {
+	// TryAcquire marks target as being processed, returning false if it is already being processed.
+	TryAcquire(target Resource) bool
+	// Release marks target as no longer being processed.
+	Release(target Resource)
+	// Contains reports whether target is being processed.
+	Contains(target Resource) bool
+}
+
+// WithInFlightTracker coordinates processing with other pools sharing tracker.  A target which is being processed
+// elsewhere when a worker pops it is requeued, to be tried again after retryDelay.
+func WithInFlightTracker(tracker InFlightTracker, retryDelay time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.inFlight = tracker
+		wp.inFlightRetryDelay = retryDelay
+	}
+}
+
+// NewInFlightTracker returns an in-memory InFlightTracker, which can coordinate pools within a single process.
+func NewInFlightTracker() InFlightTracker {
+	return &localInFlightTracker{inFlight: make(map[lockResource]struct{})}
+}
+
+type localInFlightTracker struct {
+	inFlight map[lockResource]struct{}
+	lock     sync.Mutex
+}
+
+func (l *localInFlightTracker) TryAcquire(target Resource) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	key := convert(target)
+	if _, ok := l.inFlight[key]; ok {
+		return false
+	}
+	l.inFlight[key] = struct{}{}
+	return true
+}
+
+func (l *localInFlightTracker) Release(target Resource) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.inFlight, convert(target))
+}
+
+func (l *localInFlightTracker) Contains(target Resource) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, ok := l.inFlight[convert(target)]
+	return ok
+}
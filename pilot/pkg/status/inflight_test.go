@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"istio.io/istio/pkg/config"
+)
+
+// fakeLeaseTracker stands in for a tracker shared between replicas, counting contended acquisitions.
+type fakeLeaseTracker struct {
+	InFlightTracker
+	contended int32
+}
+
+func (f *fakeLeaseTracker) TryAcquire(target Resource) bool {
+	if !f.InFlightTracker.TryAcquire(target) {
+		atomic.AddInt32(&f.contended, 1)
+		return false
+	}
+	return true
+}
+
+func TestInFlightTracker(t *testing.T) {
+	g := NewGomegaWithT(t)
+	tracker := NewInFlightTracker()
+	r := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	newer := r
+	newer.Generation = "2"
+
+	g.Expect(tracker.Contains(r)).To(BeFalse())
+	g.Expect(tracker.TryAcquire(r)).To(BeTrue())
+	// generation is not part of the identity of the target
+	g.Expect(tracker.Contains(newer)).To(BeTrue())
+	g.Expect(tracker.TryAcquire(newer)).To(BeFalse())
+	tracker.Release(newer)
+	g.Expect(tracker.Contains(r)).To(BeFalse())
+	tracker.Release(r)
+	g.Expect(tracker.TryAcquire(r)).To(BeTrue())
+}
+
+func TestWorkerPoolSharedInFlightTracker(t *testing.T) {
+	g := NewGomegaWithT(t)
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	tracker := &fakeLeaseTracker{InFlightTracker: NewInFlightTracker()}
+	written := make(chan struct{}, 1)
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		written <- struct{}{}
+		return true, nil
+	}, func(Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Generation: 1}}
+	}, 1, WithInFlightTracker(tracker, 10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	// another replica is processing the target
+	g.Expect(tracker.TryAcquire(target)).To(BeTrue())
+	wp.Push(target, c, nil)
+	g.Eventually(func() int32 { return atomic.LoadInt32(&tracker.contended) }).Should(BeNumerically(">=", 2))
+	g.Expect(written).NotTo(Receive())
+
+	tracker.Release(target)
+	g.Eventually(written).Should(Receive())
+	g.Eventually(func() bool { return tracker.Contains(target) }).Should(BeFalse())
+}
@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// WithKindCaps limits the number of targets of each kind processed at once, so that a flood of targets of a kind
+// whose status is expensive to write cannot occupy every worker and starve other kinds.  caps sets the limit for
+// particular kinds, and defaultCap the limit for every other kind; a zero limit leaves the kind uncapped.  A worker
+// passes over queued targets of a kind at its limit.  Targets processed by ProcessWithController are not held back,
+// but count against the limit.
+func WithKindCaps(caps map[schema.GroupVersionResource]uint, defaultCap uint) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.kindCaps = caps
+		wp.defaultKindCap = defaultCap
+	}
+}
+
+// kindCap returns the maximum number of targets of kind gvr processed at once, or zero if the kind is uncapped.
+func (wp *WorkerPool) kindCap(gvr schema.GroupVersionResource) uint {
+	if n, ok := wp.kindCaps[gvr]; ok {
+		return n
+	}
+	return wp.defaultKindCap
+}
+
+// startKind counts a target of kind gvr as in flight, and stops targets of the kind being popped if it has reached
+// its limit.  The caller must hold wp.lock.
+func (wp *WorkerPool) startKind(gvr schema.GroupVersionResource) {
+	limit := wp.kindCap(gvr)
+	if limit == 0 {
+		return
+	}
+	if wp.kindInFlight == nil {
+		wp.kindInFlight = make(map[schema.GroupVersionResource]uint)
+	}
+	wp.kindInFlight[gvr]++
+	if wp.kindInFlight[gvr] >= limit {
+		wp.q.setCapped(gvr, true)
+	}
+}
+
+// finishKind stops counting a target of kind gvr as in flight.  The caller must hold wp.lock.
+func (wp *WorkerPool) finishKind(gvr schema.GroupVersionResource) {
+	limit := wp.kindCap(gvr)
+	if limit == 0 {
+		return
+	}
+	if wp.kindInFlight[gvr] <= 1 {
+		delete(wp.kindInFlight, gvr)
+	} else {
+		wp.kindInFlight[gvr]--
+	}
+	if wp.kindInFlight[gvr] < limit {
+		wp.q.setCapped(gvr, false)
+	}
+}
+
+// setCapped sets whether targets of kind gvr may be popped.
+func (wq *WorkQueue) setCapped(gvr schema.GroupVersionResource, capped bool) {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	if !capped {
+		delete(wq.capped, gvr)
+		return
+	}
+	if wq.capped == nil {
+		wq.capped = make(map[schema.GroupVersionResource]struct{})
+	}
+	wq.capped[gvr] = struct{}{}
+}
@@ -0,0 +1,127 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolKindCaps(t *testing.T) {
+	g := NewGomegaWithT(t)
+	expensive := schema.GroupVersionResource{Group: "g", Version: "v", Resource: "expensive"}
+	cheap := schema.GroupVersionResource{Group: "g", Version: "v", Resource: "cheap"}
+	var lock sync.Mutex
+	inFlight := map[string]int{}
+	peak := map[string]int{}
+	release := make(chan struct{})
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		kind := strings.Split(cfg.Name, "-")[0]
+		lock.Lock()
+		inFlight[kind]++
+		if inFlight[kind] > peak[kind] {
+			peak[kind] = inFlight[kind]
+		}
+		lock.Unlock()
+		<-release
+		lock.Lock()
+		inFlight[kind]--
+		lock.Unlock()
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 5, WithKindCaps(map[schema.GroupVersionResource]uint{expensive: 1}, 2))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	for i := 0; i < 3; i++ {
+		for _, gvr := range []schema.GroupVersionResource{expensive, cheap} {
+			wp.Push(Resource{
+				GroupVersionResource: gvr,
+				Namespace:            "ns",
+				Name:                 gvr.Resource + "-" + strconv.Itoa(i),
+				Generation:           "1",
+			}, c, nil)
+		}
+	}
+	// a worker remains free, but every remaining target is of a kind at its limit
+	g.Eventually(func() int { return wp.Stats().InFlight }).Should(Equal(3))
+	g.Consistently(func() int { return wp.Stats().InFlight }, 100*time.Millisecond).Should(Equal(3))
+
+	close(release)
+	for i := 0; i < 6; i++ {
+		g.Eventually(written).Should(Receive())
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	g.Expect(peak).To(Equal(map[string]int{"expensive": 1, "cheap": 2}))
+}
+
+func TestWorkerPoolKindCapsEndSync(t *testing.T) {
+	g := NewGomegaWithT(t)
+	read := make(chan string, 10)
+	unblock := make(chan struct{})
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		read <- r.Name
+		if r.Name == "b" {
+			<-unblock
+		}
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2, WithKindCaps(nil, 1))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	wp.BeginSync()
+	wp.Push(outcomeTarget("a"), c, nil)
+	g.Eventually(read).Should(Receive(Equal("a")))
+	g.Eventually(func() OutcomeType {
+		outcome, _ := wp.LastOutcome(outcomeTarget("a"))
+		return outcome.Type
+	}).Should(Equal(OutcomeStaged))
+	wp.Push(outcomeTarget("b"), c, nil)
+	g.Eventually(read).Should(Receive(Equal("b")))
+
+	// writing the staged status does not release the kind's place held by b
+	wp.EndSync()
+	g.Expect(written).To(Receive(Equal("a")))
+	wp.Push(outcomeTarget("c"), c, nil)
+	g.Consistently(read, 100*time.Millisecond).ShouldNot(Receive())
+	close(unblock)
+	g.Eventually(written).Should(Receive(Equal("b")))
+	g.Eventually(read).Should(Receive(Equal("c")))
+	g.Eventually(written).Should(Receive(Equal("c")))
+}
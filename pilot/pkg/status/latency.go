@@ -0,0 +1,128 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Percentiles are latency percentile estimates.
+type Percentiles struct {
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+}
+
+// Latencies are percentile estimates of how long targets wait in the queue and how long they take to process, for
+// environments which do not scrape metrics.
+type Latencies struct {
+	// QueueWait is the time from a target first being pushed to it being popped by a worker.
+	QueueWait Percentiles
+	// Processing is the time taken to retrieve the config of a target, apply the controllers and write status.
+	Processing Percentiles
+	// Observations is the number of tasks processed since latencies started being tracked.
+	Observations int64
+}
+
+// WithLatencyPercentiles tracks in-memory percentile estimates of queue wait and processing time, returned by
+// Latencies.  Each is estimated from a uniform random sample of at most samples observations since the pool was
+// created, so memory use is bounded regardless of throughput.
+func WithLatencyPercentiles(samples int) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		if samples > 0 {
+			wp.latencies = &latencyTracker{
+				queueWait:  newReservoir(samples),
+				processing: newReservoir(samples),
+			}
+		}
+	}
+}
+
+// Latencies returns percentile estimates of queue wait and processing time, and false unless WithLatencyPercentiles
+// was set.
+func (wp *WorkerPool) Latencies() (Latencies, bool) {
+	if wp.latencies == nil {
+		return Latencies{}, false
+	}
+	return wp.latencies.snapshot(), true
+}
+
+type latencyTracker struct {
+	queueWait  *reservoir
+	processing *reservoir
+}
+
+func (l *latencyTracker) observe(queueWait, processing time.Duration) {
+	l.queueWait.add(queueWait)
+	l.processing.add(processing)
+}
+
+func (l *latencyTracker) snapshot() Latencies {
+	queueWait, n := l.queueWait.percentiles()
+	processing, _ := l.processing.percentiles()
+	return Latencies{QueueWait: queueWait, Processing: processing, Observations: n}
+}
+
+// reservoir keeps a uniform random sample of a bounded size from a stream of observations.
+type reservoir struct {
+	samples []time.Duration
+	size    int
+	// the number of observations seen
+	seen int64
+	rand *rand.Rand
+	lock sync.Mutex
+}
+
+func newReservoir(size int) *reservoir {
+	return &reservoir{
+		samples: make([]time.Duration, 0, size),
+		size:    size,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (r *reservoir) add(d time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.seen++
+	if len(r.samples) < r.size {
+		r.samples = append(r.samples, d)
+		return
+	}
+	// replace a sample with probability size/seen, so each observation is equally likely to be kept
+	if i := r.rand.Int63n(r.seen); i < int64(r.size) {
+		r.samples[i] = d
+	}
+}
+
+func (r *reservoir) percentiles() (Percentiles, int64) {
+	r.lock.Lock()
+	sorted := append([]time.Duration(nil), r.samples...)
+	seen := r.seen
+	r.lock.Unlock()
+	if len(sorted) == 0 {
+		return Percentiles{}, seen
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	at := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+	return Percentiles{P50: at(0.5), P95: at(0.95), P99: at(0.99)}, seen
+}
@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestReservoirExact(t *testing.T) {
+	g := NewGomegaWithT(t)
+	r := newReservoir(1000)
+	// 1ms to 100ms, in reverse so that order does not matter
+	for i := 100; i > 0; i-- {
+		r.add(time.Duration(i) * time.Millisecond)
+	}
+	p, n := r.percentiles()
+	g.Expect(n).To(Equal(int64(100)))
+	g.Expect(p.P50).To(Equal(50 * time.Millisecond))
+	g.Expect(p.P95).To(Equal(95 * time.Millisecond))
+	g.Expect(p.P99).To(Equal(99 * time.Millisecond))
+}
+
+func TestReservoirApproximate(t *testing.T) {
+	g := NewGomegaWithT(t)
+	r := newReservoir(1000)
+	// 100 passes over a uniform distribution of 1ms to 1000ms, far more than the reservoir holds
+	for pass := 0; pass < 100; pass++ {
+		for i := 1; i <= 1000; i++ {
+			r.add(time.Duration(i) * time.Millisecond)
+		}
+	}
+	g.Expect(r.samples).To(HaveLen(1000))
+	p, n := r.percentiles()
+	g.Expect(n).To(Equal(int64(100000)))
+	g.Expect(p.P50).To(BeNumerically("~", 500*time.Millisecond, 60*time.Millisecond))
+	g.Expect(p.P95).To(BeNumerically("~", 950*time.Millisecond, 30*time.Millisecond))
+	g.Expect(p.P99).To(BeNumerically("~", 990*time.Millisecond, 15*time.Millisecond))
+}
+
+func TestWorkerPoolLatencies(t *testing.T) {
+	g := NewGomegaWithT(t)
+	_, enabled := NewWorkerPool(nil, nil, 0).Latencies()
+	g.Expect(enabled).To(BeFalse())
+
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithLatencyPercentiles(100))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	wp.Push(outcomeTarget("a"), c, nil)
+	wp.Push(outcomeTarget("b"), c, nil)
+	g.Eventually(func() int64 {
+		latencies, _ := wp.Latencies()
+		return latencies.Observations
+	}).Should(Equal(int64(2)))
+	latencies, enabled := wp.Latencies()
+	g.Expect(enabled).To(BeTrue())
+	g.Expect(latencies.QueueWait.P99).To(BeNumerically(">=", latencies.QueueWait.P50))
+	g.Expect(latencies.QueueWait.P50).To(BeNumerically(">=", 0))
+	g.Expect(latencies.Processing.P50).To(BeNumerically(">=", 0))
+}
@@ -0,0 +1,55 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"istio.io/pkg/log"
+)
+
+// PushWithLogLabels is Push, also attaching logging labels, such as the id of the API request or the user which
+// triggered the update, to the worker's log output for target.  labels are alternating keys and values, as for
+// log.Scope.WithLabels.  As pushes of a queued target are merged, the labels of the latest push carrying any win;
+// pushes without labels leave them unchanged.  Labels apply to the turn in which the target is next processed, and are
+// not kept if it is queued again to be retried.
+func (wp *WorkerPool) PushWithLogLabels(target Resource, controller *Controller, progress interface{},
+	labels ...interface{}) {
+	if len(labels) == 0 {
+		labels = nil
+	}
+	wp.push(target, controller, progress, labels)
+}
+
+// labelWorking records the logging labels of entry, which has just been claimed.  The caller must hold wp.lock.
+func (wp *WorkerPool) labelWorking(entry cacheEntry) {
+	if entry.labels == nil {
+		return
+	}
+	key := convert(entry.cacheResource)
+	w := wp.working[key]
+	w.labels = entry.labels
+	wp.working[key] = w
+}
+
+// logger returns the scope to log to about target, with the logging labels it was pushed with while it is being
+// processed.  The caller must not hold wp.lock.
+func (wp *WorkerPool) logger(target Resource) *log.Scope {
+	wp.lock.Lock()
+	labels := wp.working[convert(target)].labels
+	wp.lock.Unlock()
+	if labels == nil {
+		return scope
+	}
+	return scope.WithLabels(labels...)
+}
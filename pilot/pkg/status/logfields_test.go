@@ -0,0 +1,54 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolPushWithLogLabels(t *testing.T) {
+	g := NewGomegaWithT(t)
+	// the labels attached to the worker's log output are those recorded for the target it is working on
+	labels := make(chan []interface{}, 1)
+	var wp *WorkerPool
+	wp = NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		wp.lock.Lock()
+		labels <- wp.working[convert(outcomeTarget(cfg.Name))].labels
+		wp.lock.Unlock()
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	// the labels of the latest push carrying any win
+	wp.PushWithLogLabels(outcomeTarget("a"), ctl, nil, "request", "first")
+	wp.PushWithLogLabels(outcomeTarget("a"), ctl, nil, "request", "second", "user", "alice")
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	// a target pushed without labels logs through the plain scope
+	wp.Push(outcomeTarget("b"), ctl, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	g.Expect(<-labels).To(Equal([]interface{}{"request", "second", "user", "alice"}))
+	g.Expect(<-labels).To(BeNil())
+	g.Expect(wp.logger(outcomeTarget("b"))).To(BeIdenticalTo(scope))
+}
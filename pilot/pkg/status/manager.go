@@ -17,6 +17,10 @@
 package status
 
 import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
 	"istio.io/api/meta/v1alpha1"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
@@ -35,17 +39,19 @@ type Manager struct {
 	workers WorkerQueue
 }
 
-func NewManager(store model.ConfigStore) *Manager {
-	writeFunc := func(m *config.Config, istatus interface{}) {
+func NewManager(store model.ConfigStore, opts ...WorkerPoolOption) *Manager {
+	writeFunc := func(m *config.Config, istatus interface{}) (bool, error) {
 		scope.Debugf("writing status for resource %s/%s", m.Namespace, m.Name)
 		status := istatus.(GenerationProvider)
 		m.Status = status.Unwrap()
-		_, err := store.UpdateStatus(*m)
+		resourceVersion, err := store.UpdateStatus(*m)
 		if err != nil {
 			// TODO: need better error handling
 			scope.Errorf("Encountered unexpected error updating status for %v, will try again later: %s", m, err)
-			return
+			return false, err
 		}
+		// the api server does not bump the resource version of a no-op update
+		return resourceVersion != m.ResourceVersion, nil
 	}
 	retrieveFunc := func(resource Resource) *config.Config {
 		scope.Debugf("retrieving config for status update: %s/%s", resource.Namespace, resource.Name)
@@ -60,7 +66,7 @@ func NewManager(store model.ConfigStore) *Manager {
 	}
 	return &Manager{
 		store:   store,
-		workers: NewWorkerPool(writeFunc, retrieveFunc, uint(features.StatusMaxWorkers)),
+		workers: NewWorkerPool(writeFunc, retrieveFunc, uint(features.StatusMaxWorkers), opts...),
 	}
 }
 
@@ -84,6 +90,7 @@ func (m *Manager) CreateGenericController(fn UpdateFunc) *Controller {
 		fn:      fn,
 		workers: m.workers,
 	}
+	m.register(result)
 	return result
 }
 
@@ -101,14 +108,91 @@ func (m *Manager) CreateIstioStatusController(fn func(status *v1alpha1.IstioStat
 		fn:      wrapper,
 		workers: m.workers,
 	}
+	m.register(result)
 	return result
 }
 
+// register makes the worker pool aware of a controller before it enqueues any updates, so that writes by other
+// controllers can be deferred if it is required.
+func (m *Manager) register(c *Controller) {
+	if wp, ok := m.workers.(*WorkerPool); ok {
+		wp.register(c)
+	}
+}
+
 type UpdateFunc func(status interface{}, context interface{}) GenerationProvider
 
 type Controller struct {
-	fn      UpdateFunc
-	workers WorkerQueue
+	// Name identifies the controller, and orders controllers of equal Priority.
+	Name string
+	// Priority orders the application of controllers' UpdateFuncs for a single write, lowest first.  Controllers
+	// with equal priority are applied in order of Name; controllers with equal priority and name are applied in
+	// an unspecified order.  Both fields should be set before the controller first enqueues an update.
+	Priority int
+	// Handles restricts the resources the controller may enqueue updates for.  Updates for other resources are logged
+	// and dropped.  If empty, the controller handles all resources.
+	Handles []schema.GroupVersionResource
+	// NilProgress decides what happens when the controller enqueues an update with nil context.
+	NilProgress NilProgressPolicy
+	// Required defers writing the status of a resource the controller handles until the controller has contributed
+	// to it, or the required controller timeout has elapsed, so that incomplete status is not written at startup.
+	// The pool learns of a controller when it is created by the Manager or first enqueues an update.
+	Required bool
+	// Coalescing decides whether the controller's pushes for a queued resource merge into its queued update, or are
+	// each processed separately.
+	Coalescing CoalescingPolicy
+	fn         UpdateFunc
+	workers    WorkerQueue
+}
+
+// NilProgressPolicy decides how an update enqueued with nil context is handled.
+type NilProgressPolicy int
+
+const (
+	// NilProgressApply calls the controller's UpdateFunc with the nil context.
+	NilProgressApply NilProgressPolicy = iota
+	// NilProgressSkip does not call the controller's UpdateFunc, leaving the status as the other controllers set it.
+	NilProgressSkip
+	// NilProgressReject logs and drops the update when it is enqueued.
+	NilProgressReject
+)
+
+// CoalescingPolicy decides how the pushes of a controller for a resource already queued are processed.
+type CoalescingPolicy int
+
+const (
+	// CoalesceLatest replaces the progress the controller has queued, so that only the latest is processed.
+	CoalesceLatest CoalescingPolicy = iota
+	// CoalesceNever processes every push of the controller.  Pushes from all controllers for a queued resource merge
+	// into its latest update, except that when the controller already has progress in it, that update is closed to be
+	// processed first, with the progress it has, and the push joins a new latest update carrying the progress of the
+	// other controllers forward.  Coalescing controllers thus only ever merge into the latest update, and each push of
+	// a non-coalescing controller is processed, in order, in an update of its own.  Closed updates are processed like
+	// generations retained by WithOrderedGenerations, with the generation they were pushed for; they are not bounded,
+	// so a controller pushing faster than the pool writes grows the queue.  A resource deleted while queued only has
+	// its final status written.
+	CoalesceNever
+)
+
+// Identity identifies the controller: its Name, or its address if it has no name.
+func (c *Controller) Identity() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%p", c)
+}
+
+// handles reports whether the controller accepts updates for resources of gvr.
+func (c *Controller) handles(gvr schema.GroupVersionResource) bool {
+	if len(c.Handles) == 0 {
+		return true
+	}
+	for _, h := range c.Handles {
+		if h == gvr {
+			return true
+		}
+	}
+	return false
 }
 
 // EnqueueStatusUpdateResource informs the manager that this controller would like to
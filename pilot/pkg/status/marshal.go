@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jsonmerge "github.com/evanphx/json-patch/v5"
+
+	"istio.io/istio/pkg/config"
+)
+
+// Marshaler prepares the payload handed to the write function from the computed status, so that serialization and
+// patch computation are done once by the pool rather than by every write function.
+type Marshaler interface {
+	// Marshal returns the payload to write for status, computed for cfg.  cfg.Status is the status as it was stored
+	// before the controllers ran.
+	Marshal(cfg *config.Config, status interface{}) (interface{}, error)
+}
+
+// WithMarshaler sets the Marshaler preparing the payload for each write.  By default the computed status is passed to
+// the write function unchanged.  A marshaling error is reported as a failed write.
+func WithMarshaler(m Marshaler) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.marshaler = m
+	}
+}
+
+// MergePatchMarshaler marshals status as a JSON merge patch from the stored status to the computed one.
+type MergePatchMarshaler struct{}
+
+// Marshal returns the merge patch as a []byte.
+func (MergePatchMarshaler) Marshal(cfg *config.Config, status interface{}) (interface{}, error) {
+	if p, ok := status.(GenerationProvider); ok {
+		status = p.Unwrap()
+	}
+	original, err := json.Marshal(cfg.Status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stored status: %v", err)
+	}
+	modified, err := json.Marshal(status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal status: %v", err)
+	}
+	return jsonmerge.CreateMergePatch(original, modified)
+}
+
+// marshaling returns a WriteFunc which marshals status with m before writing it with write.
+func marshaling(m Marshaler, write WriteFunc) WriteFunc {
+	return func(cfg *config.Config, status interface{}) (bool, error) {
+		payload, err := m.Marshal(cfg, status)
+		if err != nil {
+			return false, err
+		}
+		return write(cfg, payload)
+	}
+}
+
+// workingStatus returns the status of cfg for the controllers to update.  If there is a Marshaler it is a copy, so
+// that the stored status is left intact for the Marshaler to compare against.
+func (wp *WorkerPool) workingStatus(cfg *config.Config) interface{} {
+	if wp.marshaler != nil {
+		if stored := storedIstioStatus(cfg.Status); stored != nil {
+			return stored
+		}
+	}
+	return cfg.Status
+}
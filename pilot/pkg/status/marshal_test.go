@@ -0,0 +1,74 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func TestMergePatchMarshaler(t *testing.T) {
+	g := NewGomegaWithT(t)
+	cfg := &config.Config{Status: &v1alpha1.IstioStatus{
+		Conditions: []*v1alpha1.IstioCondition{{Type: "Reconciled", Status: "True"}},
+	}}
+	patch, err := MergePatchMarshaler{}.Marshal(cfg, &IstioGenerationProvider{&v1alpha1.IstioStatus{
+		Conditions:         []*v1alpha1.IstioCondition{{Type: "Reconciled", Status: "True"}},
+		ObservedGeneration: 2,
+	}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(patch.([]byte))).To(MatchJSON(`{"observedGeneration":"2"}`))
+
+	// an unchanged status results in an empty patch
+	patch, err = MergePatchMarshaler{}.Marshal(cfg, cfg.Status)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(patch.([]byte))).To(MatchJSON(`{}`))
+}
+
+func TestWorkerPoolMarshaler(t *testing.T) {
+	g := NewGomegaWithT(t)
+	type write struct {
+		cfg     *config.Config
+		payload interface{}
+	}
+	written := make(chan write, 1)
+	wp := NewWorkerPool(func(cfg *config.Config, payload interface{}) (bool, error) {
+		written <- write{cfg, payload}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}, Status: &v1alpha1.IstioStatus{
+			Conditions:         []*v1alpha1.IstioCondition{{Type: "Reconciled", Status: "True"}},
+			ObservedGeneration: 1,
+		}}
+	}, 1, WithMarshaler(MergePatchMarshaler{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	// the controller updates the stored status in place
+	wp.Push(outcomeTarget("a"), &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		s := status.(*IstioGenerationProvider)
+		s.Conditions[0].Status = "False"
+		return s
+	}}, nil)
+	var w write
+	g.Eventually(written).Should(Receive(&w))
+	g.Expect(string(w.payload.([]byte))).To(MatchJSON(`{"conditions":[{"type":"Reconciled","status":"False"}]}`))
+	g.Expect(w.cfg.Status.(*v1alpha1.IstioStatus).Conditions[0].Status).To(Equal("True"))
+}
@@ -0,0 +1,179 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+const (
+	writeChanged   = "changed"
+	writeUnchanged = "unchanged"
+	writeError     = "error"
+
+	modeLive   = "live"
+	modeDryRun = "dry_run"
+
+	phaseStartup = "startup"
+	phaseSteady  = "steady"
+)
+
+var (
+	resultTag = monitoring.MustCreateLabel("result")
+	phaseTag  = monitoring.MustCreateLabel("phase")
+	modeTag   = monitoring.MustCreateLabel("mode")
+	workerTag = monitoring.MustCreateLabel("worker")
+
+	// writes are labeled with the mode of the pool, so that writes captured by a dry run are not mistaken for real
+	// ones.
+	statusWrites = monitoring.NewSum(
+		"pilot_status_writes",
+		"Status writes attempted by the status workers, by whether a change was persisted.",
+		monitoring.WithLabels(resultTag, modeTag),
+	)
+
+	namespaceDeletedTasks = monitoring.NewSum(
+		"pilot_status_namespace_deleted_tasks",
+		"Queued status tasks removed because all work for their namespace was cancelled.",
+	)
+
+	// pushes and pops are labeled with the phase of the pool, so that the initial resync can be told apart from steady
+	// state churn.  Until MarkSteadyState is called, everything is counted as startup.
+	statusPushes = monitoring.NewSum(
+		"pilot_status_pushes",
+		"Targets pushed to the status workers, by whether the pool has reached steady state.",
+		monitoring.WithLabels(phaseTag),
+	)
+
+	statusPops = monitoring.NewSum(
+		"pilot_status_pops",
+		"Targets popped for processing by the status workers, by whether the pool has reached steady state.",
+		monitoring.WithLabels(phaseTag),
+	)
+
+	controllerLimitRejections = monitoring.NewSum(
+		"pilot_status_controller_limit_rejections",
+		"Status updates dropped because their controller had the maximum number of targets queued.",
+	)
+
+	// a write which only bumps the observed generation is still needed for generation tracking, but is counted
+	// separately to show how much of the write volume is pure generation bookkeeping.
+	generationOnlyWrites = monitoring.NewSum(
+		"pilot_status_generation_only_writes",
+		"Status writes whose only change to the stored status was the observed generation.",
+		monitoring.WithLabels(modeTag),
+	)
+
+	spuriousWakeups = monitoring.NewSum(
+		"pilot_status_spurious_wakeups",
+		"Status worker wakeups which found no task that could be processed.",
+	)
+
+	resultsDropped = monitoring.NewSum(
+		"pilot_status_results_dropped",
+		"Processing results dropped because the results channel was full.",
+	)
+
+	invalidStatus = monitoring.NewSum(
+		"pilot_status_invalid",
+		"Computed statuses which were not written because they failed validation.",
+	)
+
+	throttledWrites = monitoring.NewSum(
+		"pilot_status_throttled_writes",
+		"Status writes which the store throttled, pausing the status workers.",
+	)
+
+	wastedPushes = monitoring.NewSum(
+		"pilot_status_wasted_pushes",
+		"Status pushes whose task was dropped without writing, because the target was deleted, not found, of another "+
+			"generation or given invalid status.",
+	)
+
+	inFlightReaped = monitoring.NewSum(
+		"pilot_status_in_flight_reaped",
+		"Targets released by the reaper after being in flight for longer than the in-flight timeout.",
+	)
+
+	workerBusySeconds = monitoring.NewSum(
+		"pilot_status_worker_busy_seconds",
+		"Time status workers spent processing targets.",
+	)
+
+	workerIdleSeconds = monitoring.NewSum(
+		"pilot_status_worker_idle_seconds",
+		"Time status workers spent waiting for a target to become available.",
+	)
+
+	workerUtilization = monitoring.NewGauge(
+		"pilot_status_worker_utilization",
+		"Fraction of status worker capacity spent processing targets, over the last five minutes.",
+	)
+
+	workerSlotUtilization = monitoring.NewGauge(
+		"pilot_status_worker_slot_utilization",
+		"Fraction of the last five minutes each status worker slot spent processing targets.",
+		monitoring.WithLabels(workerTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(statusWrites, namespaceDeletedTasks, statusPushes, statusPops,
+		controllerLimitRejections, generationOnlyWrites, spuriousWakeups, resultsDropped,
+		invalidStatus, throttledWrites, wastedPushes, inFlightReaped, workerBusySeconds, workerIdleSeconds,
+		workerUtilization, workerSlotUtilization)
+}
+
+func recordWrite(dryRun bool, changed bool, err error) {
+	result := writeUnchanged
+	if err != nil {
+		result = writeError
+	} else if changed {
+		result = writeChanged
+	}
+	statusWrites.With(resultTag.Value(result), modeTag.Value(writeMode(dryRun))).Increment()
+}
+
+func recordGenerationOnlyWrite(dryRun bool) {
+	generationOnlyWrites.With(modeTag.Value(writeMode(dryRun))).Increment()
+}
+
+func writeMode(dryRun bool) string {
+	if dryRun {
+		return modeDryRun
+	}
+	return modeLive
+}
+
+func recordNamespaceDeletion(tasks int) {
+	if tasks > 0 {
+		namespaceDeletedTasks.RecordInt(int64(tasks))
+	}
+}
+
+func phase(steady bool) string {
+	if steady {
+		return phaseSteady
+	}
+	return phaseStartup
+}
+
+func recordPush(steady bool) {
+	statusPushes.With(phaseTag.Value(phase(steady))).Increment()
+}
+
+func recordPop(steady bool) {
+	statusPops.With(phaseTag.Value(phase(steady))).Increment()
+}
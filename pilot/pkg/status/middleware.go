@@ -0,0 +1,44 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+// ApplyFunc applies the UpdateFunc of ctl, with the progress ctl has queued, to status, returning the updated status.
+type ApplyFunc func(status GenerationProvider, ctl *Controller, progress interface{}) GenerationProvider
+
+// ApplyMiddleware wraps the application of each controller's UpdateFunc, for cross-cutting concerns such as timing,
+// panic isolation or skipping controllers.  It may call next, or return a status without calling it.
+type ApplyMiddleware func(next ApplyFunc) ApplyFunc
+
+// WithApplyMiddleware wraps the application of every controller's UpdateFunc with mw.  Middleware set by earlier
+// options wraps middleware set by later ones, so the first is outermost.
+func WithApplyMiddleware(mw ApplyMiddleware) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.applyMiddleware = append(wp.applyMiddleware, mw)
+	}
+}
+
+// applyUpdate calls the UpdateFunc of ctl.
+func applyUpdate(status GenerationProvider, ctl *Controller, progress interface{}) GenerationProvider {
+	return ctl.fn(status, progress)
+}
+
+// applyChain returns the ApplyFunc calling the UpdateFunc of a controller through the configured middleware.
+func (wp *WorkerPool) applyChain() ApplyFunc {
+	apply := ApplyFunc(applyUpdate)
+	for i := len(wp.applyMiddleware) - 1; i >= 0; i-- {
+		apply = wp.applyMiddleware[i](apply)
+	}
+	return apply
+}
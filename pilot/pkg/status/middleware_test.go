@@ -0,0 +1,94 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolApplyMiddleware(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var lock sync.Mutex
+	var calls []string
+	timings := map[string]time.Duration{}
+	timing := func(next ApplyFunc) ApplyFunc {
+		return func(status GenerationProvider, ctl *Controller, progress interface{}) GenerationProvider {
+			start := time.Now()
+			defer func() {
+				lock.Lock()
+				defer lock.Unlock()
+				calls = append(calls, "timing:"+ctl.Name)
+				timings[ctl.Name] += time.Since(start)
+			}()
+			return next(status, ctl, progress)
+		}
+	}
+	recovery := func(next ApplyFunc) ApplyFunc {
+		return func(status GenerationProvider, ctl *Controller, progress interface{}) (out GenerationProvider) {
+			defer func() {
+				if r := recover(); r != nil {
+					lock.Lock()
+					defer lock.Unlock()
+					calls = append(calls, "recovered:"+ctl.Name)
+					// leave the status as the controllers before this one set it
+					out = status
+				}
+			}()
+			return next(status, ctl, progress)
+		}
+	}
+	written := make(chan *v1alpha1.IstioStatus, 1)
+	wp := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+		written <- status.(*IstioGenerationProvider).IstioStatus
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithApplyMiddleware(timing), WithApplyMiddleware(recovery))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+
+	good := &Controller{Name: "good", Priority: 1, fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{
+			Conditions: []*v1alpha1.IstioCondition{{Type: "Reconciled"}},
+		}}
+	}}
+	bad := &Controller{Name: "bad", Priority: 2, fn: func(status interface{}, context interface{}) GenerationProvider {
+		panic("controller bug")
+	}}
+	// both controllers are applied in a single write
+	wp.Hold(outcomeTarget("a"))
+	wp.Push(outcomeTarget("a"), good, nil)
+	wp.Push(outcomeTarget("a"), bad, nil)
+	wp.Release(outcomeTarget("a"))
+
+	var status *v1alpha1.IstioStatus
+	g.Eventually(written).Should(Receive(&status))
+	g.Expect(status.Conditions).To(HaveLen(1))
+	lock.Lock()
+	defer lock.Unlock()
+	// the first middleware is outermost, so it times the recovery too
+	g.Expect(calls).To(Equal([]string{"timing:good", "recovered:bad", "timing:bad"}))
+	g.Expect(timings).To(HaveKey("good"))
+	g.Expect(timings).To(HaveKey("bad"))
+}
@@ -0,0 +1,106 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+// WriteAfter orders the status writes of two targets: while parent is queued or being processed, child is not popped,
+// so that clients never observe the status of child ahead of that of parent.  The ordering applies to every later
+// generation of both targets, until child is deleted or ClearWriteAfter is called.  An ordering which would create a
+// cycle is logged and not added.  A sharded pool only orders targets in the same shard.  It reports whether the
+// ordering was added.
+func (wp *WorkerPool) WriteAfter(child, parent Resource) bool {
+	if wp.shards != nil {
+		shard := wp.shard(child)
+		if shard != wp.shard(parent) {
+			scope.Warnf("not ordering %s after %s, which are in different shards", child, parent)
+			return false
+		}
+		return shard.WriteAfter(child, parent)
+	}
+	if !wp.q.addOrdering(convert(child), convert(parent)) {
+		scope.Warnf("not ordering %s after %s, which would create a cycle", child, parent)
+		return false
+	}
+	return true
+}
+
+// ClearWriteAfter removes the orderings of child after other targets.
+func (wp *WorkerPool) ClearWriteAfter(child Resource) {
+	if wp.shards != nil {
+		wp.shard(child).ClearWriteAfter(child)
+		return
+	}
+	wp.q.clearOrdering(convert(child))
+	wp.lock.Lock()
+	// child may have been waiting for a parent
+	wp.idle.Broadcast()
+	wp.lock.Unlock()
+}
+
+// clearOrdering removes the orderings of child after other targets.
+func (wq *WorkQueue) clearOrdering(child lockResource) {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	delete(wq.after, child)
+}
+
+// addOrdering orders child after parent, unless parent is already ordered after child, directly or indirectly.
+func (wq *WorkQueue) addOrdering(child, parent lockResource) bool {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	if child == parent || wq.orderedAfter(parent, child, map[lockResource]struct{}{}) {
+		return false
+	}
+	if wq.after == nil {
+		wq.after = make(map[lockResource][]lockResource)
+	}
+	for _, p := range wq.after[child] {
+		if p == parent {
+			return true
+		}
+	}
+	wq.after[child] = append(wq.after[child], parent)
+	return true
+}
+
+// orderedAfter reports whether key is ordered after target, directly or indirectly.  The caller must hold wq.lock.
+func (wq *WorkQueue) orderedAfter(key, target lockResource, seen map[lockResource]struct{}) bool {
+	for _, parent := range wq.after[key] {
+		if parent == target {
+			return true
+		}
+		if _, ok := seen[parent]; ok {
+			continue
+		}
+		seen[parent] = struct{}{}
+		if wq.orderedAfter(parent, target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitingOnParent reports whether a target key is ordered after is queued, or in exclusion as it is being processed.
+// The caller must hold wq.lock.
+func (wq *WorkQueue) waitingOnParent(key lockResource, exclusion map[lockResource]struct{}) bool {
+	for _, parent := range wq.after[key] {
+		if _, queued := wq.cache[parent]; queued {
+			return true
+		}
+		if _, working := exclusion[parent]; working {
+			return true
+		}
+	}
+	return false
+}
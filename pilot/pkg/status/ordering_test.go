@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolWriteAfter(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	parent, child := outcomeTarget("parent"), outcomeTarget("child")
+	g.Expect(wp.WriteAfter(child, parent)).To(BeTrue())
+
+	// the child waits for its parent, although a worker is free
+	wp.Hold(parent)
+	wp.Push(parent, ctl, nil)
+	wp.Push(child, ctl, nil)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	wp.Release(parent)
+	g.Eventually(written).Should(Receive(Equal("parent")))
+	g.Eventually(written).Should(Receive(Equal("child")))
+
+	// the child is not held back when its parent is not pending
+	wp.Push(child, ctl, nil)
+	g.Eventually(written).Should(Receive(Equal("child")))
+
+	// deleting a queued parent lets the child be written
+	wp.Hold(parent)
+	wp.Push(parent, ctl, nil)
+	wp.Push(child, ctl, nil)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	wp.Delete(parent)
+	g.Eventually(written).Should(Receive(Equal("child")))
+	wp.Release(parent)
+
+	// so does deleting a batch including the parent
+	wp.Hold(parent)
+	wp.Push(parent, ctl, nil)
+	wp.Push(child, ctl, nil)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(wp.DeleteBatch([]Resource{parent})).To(Equal(1))
+	g.Eventually(written).Should(Receive(Equal("child")))
+	wp.Release(parent)
+
+	// orderings which would create a cycle are refused
+	grandchild := outcomeTarget("grandchild")
+	g.Expect(wp.WriteAfter(grandchild, child)).To(BeTrue())
+	g.Expect(wp.WriteAfter(parent, grandchild)).To(BeFalse())
+	g.Expect(wp.WriteAfter(parent, parent)).To(BeFalse())
+
+	// once cleared, the child no longer waits
+	wp.ClearWriteAfter(child)
+	wp.Hold(parent)
+	wp.Push(parent, ctl, nil)
+	wp.Push(child, ctl, nil)
+	g.Eventually(written).Should(Receive(Equal("child")))
+	wp.Release(parent)
+	g.Eventually(written).Should(Receive(Equal("parent")))
+}
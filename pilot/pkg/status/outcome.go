@@ -0,0 +1,199 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// defaultOutcomeHistorySize is the default number of targets for which the last outcome is retained.
+const defaultOutcomeHistorySize = 1000
+
+// OutcomeType describes what last happened to a target in the worker pool.
+type OutcomeType int
+
+const (
+	// OutcomeQueued means the target was pushed, and was not already queued.
+	OutcomeQueued OutcomeType = iota
+	// OutcomeDeduped means the target was pushed while already queued, and was merged with the queued task.
+	OutcomeDeduped
+	// OutcomeDeleted means the target was deleted from the queue.
+	OutcomeDeleted
+	// OutcomeNotFound means the target could not be retrieved when it was processed.
+	OutcomeNotFound
+	// OutcomeGenerationMismatch means the generation of the target did not match the retrieved config.
+	OutcomeGenerationMismatch
+	// OutcomeWritten means the status of the target was written, and a change was persisted.
+	OutcomeWritten
+	// OutcomeNoop means the status of the target was written, but the store did not persist a change.
+	OutcomeNoop
+	// OutcomeFailed means writing the status of the target failed.
+	OutcomeFailed
+	// OutcomeDeferred means writing the status of the target was deferred until a required controller contributes, or
+	// until its dependencies are ready.
+	OutcomeDeferred
+	// OutcomeStaged means the status of the target was computed during a sync, and will be written when it ends.
+	OutcomeStaged
+	// OutcomeInvalid means the status computed for the target failed validation, and was not written.
+	OutcomeInvalid
+	// OutcomeEmpty means the target had no progress from any controller when it was processed, and was not written.
+	OutcomeEmpty
+)
+
+func (o OutcomeType) String() string {
+	switch o {
+	case OutcomeQueued:
+		return "queued"
+	case OutcomeDeduped:
+		return "deduped"
+	case OutcomeDeleted:
+		return "deleted"
+	case OutcomeNotFound:
+		return "not found"
+	case OutcomeGenerationMismatch:
+		return "generation mismatch"
+	case OutcomeWritten:
+		return "written"
+	case OutcomeNoop:
+		return "noop"
+	case OutcomeFailed:
+		return "failed"
+	case OutcomeDeferred:
+		return "deferred"
+	case OutcomeStaged:
+		return "staged"
+	case OutcomeInvalid:
+		return "invalid"
+	case OutcomeEmpty:
+		return "empty"
+	}
+	return "unknown"
+}
+
+// writeOutcome returns the outcome of a write.
+func writeOutcome(changed bool, err error) OutcomeType {
+	switch {
+	case err != nil:
+		return OutcomeFailed
+	case changed:
+		return OutcomeWritten
+	}
+	return OutcomeNoop
+}
+
+// Outcome records what last happened to a target, and when.
+type Outcome struct {
+	Type OutcomeType
+	Time time.Time
+	// Generation is the generation of the target the outcome applies to.
+	Generation string
+	// Err is the write error, for OutcomeFailed, or the validation error, for OutcomeInvalid.
+	Err error
+}
+
+// WithOutcomeHistory sets the number of targets for which the last outcome is retained.  Once more targets have been
+// seen, the least recently updated are evicted.  A size of zero disables tracking.
+func WithOutcomeHistory(size int) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.outcomes.size = size
+	}
+}
+
+type outcomeEntry struct {
+	key     lockResource
+	outcome Outcome
+}
+
+// outcomeTracker retains the last outcome for a bounded number of targets.
+type outcomeTracker struct {
+	size    int
+	clock   clock.PassiveClock
+	entries map[lockResource]*list.Element
+	// order of entries, least recently updated first
+	order *list.List
+	lock  sync.Mutex
+	// if set, every outcome is also recorded as an operation
+	trace *operationTrace
+}
+
+func newOutcomeTracker() *outcomeTracker {
+	return &outcomeTracker{
+		size:    defaultOutcomeHistorySize,
+		clock:   clock.RealClock{},
+		entries: make(map[lockResource]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (o *outcomeTracker) record(target Resource, typ OutcomeType, err error) {
+	if o.size <= 0 && o.trace == nil {
+		return
+	}
+	now := o.clock.Now()
+	if o.trace != nil {
+		detail := typ.String()
+		if err != nil {
+			detail += ": " + err.Error()
+		}
+		o.trace.record(operationFor(typ), target, now, detail)
+	}
+	if o.size <= 0 {
+		return
+	}
+	key := convert(target)
+	outcome := Outcome{
+		Type:       typ,
+		Time:       now,
+		Generation: target.Generation,
+		Err:        err,
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if e, ok := o.entries[key]; ok {
+		e.Value.(*outcomeEntry).outcome = outcome
+		o.order.MoveToBack(e)
+		return
+	}
+	o.entries[key] = o.order.PushBack(&outcomeEntry{key: key, outcome: outcome})
+	for o.order.Len() > o.size {
+		oldest := o.order.Front()
+		o.order.Remove(oldest)
+		delete(o.entries, oldest.Value.(*outcomeEntry).key)
+	}
+}
+
+func (o *outcomeTracker) get(target Resource) (Outcome, bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	e, ok := o.entries[convert(target)]
+	if !ok {
+		return Outcome{}, false
+	}
+	return e.Value.(*outcomeEntry).outcome, true
+}
+
+// LastOutcome returns what last happened to target: whether it was queued, merged with an already queued task,
+// deleted, skipped, written or failed.  It returns false if nothing is known about target, either because it has
+// not been seen or because it has been evicted from the bounded history.
+func (wp *WorkerPool) LastOutcome(target Resource) (Outcome, bool) {
+	if wp.shards != nil {
+		return wp.shard(target).LastOutcome(target)
+	}
+	return wp.outcomes.get(target)
+}
@@ -0,0 +1,125 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"istio.io/istio/pkg/config"
+)
+
+func outcomeTarget(name string) Resource {
+	return Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 name,
+		Generation:           "1",
+	}
+}
+
+func TestLastOutcomeQueue(t *testing.T) {
+	g := NewGomegaWithT(t)
+	// without workers, pushed targets stay queued
+	wp := NewWorkerPool(nil, nil, 0)
+	target := outcomeTarget("a")
+	_, found := wp.LastOutcome(target)
+	g.Expect(found).To(BeFalse())
+
+	wp.Push(target, &Controller{}, nil)
+	outcome, found := wp.LastOutcome(target)
+	g.Expect(found).To(BeTrue())
+	g.Expect(outcome.Type).To(Equal(OutcomeQueued))
+	g.Expect(outcome.Time).NotTo(BeZero())
+
+	wp.Push(target, &Controller{}, nil)
+	outcome, _ = wp.LastOutcome(target)
+	g.Expect(outcome.Type).To(Equal(OutcomeDeduped))
+
+	wp.Delete(target)
+	outcome, _ = wp.LastOutcome(target)
+	g.Expect(outcome.Type).To(Equal(OutcomeDeleted))
+}
+
+func TestLastOutcomeProcessing(t *testing.T) {
+	writeErr := errors.New("conflict")
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		switch cfg.Name {
+		case "noop":
+			return false, nil
+		case "fail":
+			return false, writeErr
+		}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		switch r.Name {
+		case "missing":
+			return nil
+		case "stale":
+			return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 2}}
+		}
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	cases := map[string]OutcomeType{
+		"missing": OutcomeNotFound,
+		"stale":   OutcomeGenerationMismatch,
+		"written": OutcomeWritten,
+		"noop":    OutcomeNoop,
+		"fail":    OutcomeFailed,
+	}
+	for name, want := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			target := outcomeTarget(name)
+			wp.Push(target, c, nil)
+			g.Eventually(func() OutcomeType {
+				outcome, _ := wp.LastOutcome(target)
+				return outcome.Type
+			}).Should(Equal(want))
+			if want == OutcomeFailed {
+				outcome, _ := wp.LastOutcome(target)
+				g.Expect(outcome.Err).To(Equal(writeErr))
+			}
+		})
+	}
+}
+
+func TestLastOutcomeEviction(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0, WithOutcomeHistory(2))
+	wp.Push(outcomeTarget("a"), &Controller{}, nil)
+	wp.Push(outcomeTarget("b"), &Controller{}, nil)
+	// updating a keeps it, so b is the oldest
+	wp.Push(outcomeTarget("a"), &Controller{}, nil)
+	wp.Push(outcomeTarget("c"), &Controller{}, nil)
+
+	_, found := wp.LastOutcome(outcomeTarget("b"))
+	g.Expect(found).To(BeFalse())
+	for _, name := range []string{"a", "c"} {
+		_, found := wp.LastOutcome(outcomeTarget(name))
+		g.Expect(found).To(BeTrue())
+	}
+}
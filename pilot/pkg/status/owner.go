@@ -0,0 +1,52 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+// WithOwnerGrouping processes queued targets with the same owner together, so that the status of resources sharing an
+// owner, such as the pods of a workload or the config generated from one source, presents a consistent view.  When a
+// worker pops a target, it also pops every other eligible queued target for which owner returns the same key, and
+// processes them one after another before popping again.  Targets for which owner returns the empty string are not
+// grouped, and a target in flight or held is left for a later turn, so each target is still processed by at most one
+// worker at a time.
+func WithOwnerGrouping(owner func(target Resource) string) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.ownerKey = owner
+	}
+}
+
+// popOwned removes the eligible tasks not in exclusion whose targets have the same owner as target, in queue order.
+func (wq *WorkQueue) popOwned(owner func(Resource) string, target Resource, exclusion map[lockResource]struct{}) []cacheEntry {
+	key := owner(target)
+	if key == "" {
+		return nil
+	}
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	now := wq.now()
+	var group []cacheEntry
+	for i := 0; i < len(wq.tasks); i++ {
+		task := wq.tasks[i]
+		t, ok := wq.cache[task]
+		if !ok || wq.excluded(task, exclusion) || wq.eligibleAt(t).After(now) || owner(t.cacheResource) != key {
+			continue
+		}
+		group = append(group, wq.remove(i, t))
+		if _, queued := wq.cache[task]; !queued {
+			// the task was removed, rather than an earlier generation of it
+			i--
+		}
+	}
+	return group
+}
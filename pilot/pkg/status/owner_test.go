@@ -0,0 +1,70 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolOwnerGrouping(t *testing.T) {
+	g := NewGomegaWithT(t)
+	blocked := make(chan struct{})
+	events := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		if cfg.Name == "blocker" {
+			<-blocked
+		}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithOwnerGrouping(func(target Resource) string {
+		return strings.Split(target.Name, "-")[0]
+	}), WithOnStartProcessing(func(target Resource, _ time.Duration) {
+		events <- "start " + target.Name
+	}), WithProcessHooks(nil, func(target Resource, err error, duration time.Duration) {
+		events <- "done " + target.Name
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	// the only worker is busy while the targets are queued
+	wp.Push(outcomeTarget("blocker"), ctl, nil)
+	g.Eventually(events).Should(Receive(Equal("start blocker")))
+	for _, name := range []string{"app-1", "other-1", "app-2", "app-3"} {
+		wp.Push(outcomeTarget(name), ctl, nil)
+	}
+	close(blocked)
+	g.Eventually(events).Should(Receive(Equal("done blocker")))
+
+	// the targets owned by app are claimed in one turn, ahead of other-1 which was queued before two of them
+	for _, event := range []string{
+		"start app-1", "start app-2", "start app-3",
+		"done app-1", "done app-2", "done app-3",
+		"start other-1", "done other-1",
+	} {
+		g.Eventually(events).Should(Receive(Equal(event)))
+	}
+}
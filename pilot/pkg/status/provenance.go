@@ -0,0 +1,103 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Provenance records who last wrote the status of a target, and for which generation.
+type Provenance struct {
+	// Controllers are the identities of the controllers whose UpdateFuncs computed the status, in the order they were
+	// applied.
+	Controllers []string
+	// Generation is the generation of the target the status was written for.
+	Generation string
+	Time       time.Time
+}
+
+// WithWriteProvenance records which controllers contributed to the last successful write of up to size targets, and
+// at which generation, to be queried with LastWriteProvenance.  Once more targets have been written, the least
+// recently written are evicted.
+func WithWriteProvenance(size int) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		if size > 0 {
+			wp.provenance = newProvenanceCache(size)
+		}
+	}
+}
+
+// LastWriteProvenance returns the provenance of the last status successfully written for target.  It returns false
+// if provenance is not recorded, no status has been written for target, or it has been evicted.
+func (wp *WorkerPool) LastWriteProvenance(target Resource) (Provenance, bool) {
+	if wp.provenance == nil {
+		return Provenance{}, false
+	}
+	return wp.provenance.get(target)
+}
+
+type provenanceEntry struct {
+	key        lockResource
+	provenance Provenance
+}
+
+// provenanceCache retains the provenance of the last write for a bounded number of targets.
+type provenanceCache struct {
+	size    int
+	entries map[lockResource]*list.Element
+	// order of entries, least recently written first
+	order *list.List
+	lock  sync.Mutex
+}
+
+func newProvenanceCache(size int) *provenanceCache {
+	return &provenanceCache{
+		size:    size,
+		entries: make(map[lockResource]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (p *provenanceCache) add(target Resource, controllers []string, at time.Time) {
+	key := convert(target)
+	provenance := Provenance{Controllers: controllers, Generation: target.Generation, Time: at}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if e, ok := p.entries[key]; ok {
+		e.Value.(*provenanceEntry).provenance = provenance
+		p.order.MoveToBack(e)
+		return
+	}
+	p.entries[key] = p.order.PushBack(&provenanceEntry{key: key, provenance: provenance})
+	for p.order.Len() > p.size {
+		oldest := p.order.Front()
+		p.order.Remove(oldest)
+		delete(p.entries, oldest.Value.(*provenanceEntry).key)
+	}
+}
+
+func (p *provenanceCache) get(target Resource) (Provenance, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	e, ok := p.entries[convert(target)]
+	if !ok {
+		return Provenance{}, false
+	}
+	provenance := e.Value.(*provenanceEntry).provenance
+	provenance.Controllers = append([]string(nil), provenance.Controllers...)
+	return provenance, true
+}
@@ -0,0 +1,65 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolWriteProvenance(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithWriteProvenance(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	fn := func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}
+	ready := &Controller{Name: "ready", Priority: 1, fn: fn}
+	analysis := &Controller{Name: "analysis", Priority: 2, fn: fn}
+
+	_, ok := wp.LastWriteProvenance(outcomeTarget("a"))
+	g.Expect(ok).To(BeFalse())
+	wp.Hold(outcomeTarget("a"))
+	wp.Push(outcomeTarget("a"), analysis, "progress")
+	wp.Push(outcomeTarget("a"), ready, "progress")
+	wp.Release(outcomeTarget("a"))
+	g.Eventually(func() bool {
+		_, ok := wp.LastWriteProvenance(outcomeTarget("a"))
+		return ok
+	}).Should(BeTrue())
+	provenance, _ := wp.LastWriteProvenance(outcomeTarget("a"))
+	g.Expect(provenance.Controllers).To(Equal([]string{"ready", "analysis"}))
+	g.Expect(provenance.Generation).To(Equal("1"))
+	g.Expect(provenance.Time.IsZero()).To(BeFalse())
+
+	// the least recently written target is evicted
+	wp.Push(outcomeTarget("b"), ready, "progress")
+	g.Eventually(func() bool {
+		_, ok := wp.LastWriteProvenance(outcomeTarget("a"))
+		return ok
+	}).Should(BeFalse())
+	provenance, _ = wp.LastWriteProvenance(outcomeTarget("b"))
+	g.Expect(provenance.Controllers).To(Equal([]string{"ready"}))
+}
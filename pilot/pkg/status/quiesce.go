@@ -0,0 +1,57 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+)
+
+// QuiesceInFlight stops workers from popping queued tasks and waits for the tasks already being processed to finish,
+// leaving the queue intact, for example so that pending work can be captured with Snapshot or Dump and handed to
+// another pool during a failover.  Unlike Shutdown with drain, queued tasks are not processed.  Tasks pushed while the
+// pool is quiesced are queued but not processed until Resume is called.  If ctx is done first, its error is returned
+// and the pool stays quiesced.
+func (wp *WorkerPool) QuiesceInFlight(ctx context.Context) error {
+	for _, shard := range wp.shards {
+		shard.setQuiesced(true)
+	}
+	for _, shard := range wp.shards {
+		if err := shard.QuiesceInFlight(ctx); err != nil {
+			return err
+		}
+	}
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	wp.quiesced = true
+	return waitCond(ctx, wp.cond, func() bool {
+		return len(wp.currentlyWorking) == 0
+	})
+}
+
+// Resume lets workers pop queued tasks again after QuiesceInFlight.
+func (wp *WorkerPool) Resume() {
+	for _, shard := range wp.shards {
+		shard.Resume()
+	}
+	wp.setQuiesced(false)
+	wp.maybeAddWorker()
+}
+
+func (wp *WorkerPool) setQuiesced(quiesced bool) {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	wp.quiesced = quiesced
+	wp.idle.Broadcast()
+}
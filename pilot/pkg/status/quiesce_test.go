@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolQuiesceInFlight(t *testing.T) {
+	g := NewGomegaWithT(t)
+	writing := make(chan string, 10)
+	unblock := make(chan struct{})
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		writing <- cfg.Name
+		if cfg.Name == "a" {
+			<-unblock
+		}
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	g.Eventually(writing).Should(Receive(Equal("a")))
+	wp.Push(outcomeTarget("b"), ctl, nil)
+	wp.Push(outcomeTarget("c"), ctl, nil)
+
+	// the in-flight write is waited for, and gives up with ctx
+	short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort()
+	g.Expect(wp.QuiesceInFlight(short)).To(Equal(context.DeadlineExceeded))
+	quiesced := make(chan error, 1)
+	go func() {
+		quiesced <- wp.QuiesceInFlight(ctx)
+	}()
+	g.Consistently(quiesced, 100*time.Millisecond).ShouldNot(Receive())
+	close(unblock)
+	g.Eventually(quiesced).Should(Receive(BeNil()))
+	g.Expect(written).To(Receive(Equal("a")))
+
+	// queued tasks are left for handoff, and new pushes are not processed either
+	wp.Push(outcomeTarget("d"), ctl, nil)
+	g.Consistently(writing, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(wp.Stats().Queued).To(Equal(3))
+	g.Expect(DiffSnapshots(Snapshot{}, wp.Snapshot())).To(ContainElements(
+		"+tasks r1/r1//r1/b", "+tasks r1/r1//r1/c", "+tasks r1/r1//r1/d"))
+
+	// once resumed, the queue is processed
+	wp.Resume()
+	for _, name := range []string{"b", "c", "d"} {
+		g.Eventually(written).Should(Receive(Equal(name)))
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"time"
+)
+
+// reaperChecksPerTimeout is how many times the reaper checks in-flight targets per in-flight timeout, so that a leaked
+// target is released at most a quarter of the timeout late.
+const reaperChecksPerTimeout = 4
+
+// WithInFlightTimeout releases targets which have been in flight for longer than timeout, as a safety net against a
+// worker stuck in a controller, a store call or a bug in the pool, whatever the cause.  The reaper runs from Run, logs
+// each release as an error and counts it in metrics.  A released target may be claimed again, for example to process
+// progress pushed since it was claimed; the progress the stuck worker popped is lost.  If a worker held the target,
+// its place is given to a new worker, and the stuck worker exits without writing more once it returns.  The default,
+// zero, disables the reaper; timeout should be far longer than any legitimate write.
+func WithInFlightTimeout(timeout time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.inFlightTimeout = timeout
+	}
+}
+
+// reapInFlight releases targets in flight for longer than the in-flight timeout until ctx is done.
+func (wp *WorkerPool) reapInFlight(ctx context.Context) {
+	for {
+		t := wp.clock.NewTimer(wp.inFlightTimeout / reaperChecksPerTimeout)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C():
+		}
+		for i := wp.reap(); i > 0; i-- {
+			wp.maybeAddWorker()
+		}
+	}
+}
+
+// reap releases targets in flight for longer than the in-flight timeout, and returns how many it released.
+func (wp *WorkerPool) reap() int {
+	now := wp.clock.Now()
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	reaped := 0
+	stuckTurns := make(map[uint64]struct{})
+	for key, w := range wp.working {
+		held := now.Sub(w.since)
+		if held < wp.inFlightTimeout {
+			continue
+		}
+		scope.Errorf("releasing %s, which has been in flight for %v, longer than the limit of %v; its worker may be stuck",
+			w.target, held, wp.inFlightTimeout)
+		inFlightReaped.Increment()
+		wp.releaseClaim(key, w)
+		if w.turn != 0 {
+			stuckTurns[w.turn] = struct{}{}
+		}
+		reaped++
+	}
+	if len(stuckTurns) > 0 {
+		// the rest of a stuck worker's owner group is released with it, as the worker will abandon the group
+		for key, w := range wp.working {
+			if _, ok := stuckTurns[w.turn]; ok {
+				wp.releaseClaim(key, w)
+				reaped++
+			}
+		}
+	}
+	for range stuckTurns {
+		// the stuck worker no longer counts, so that another can take its place
+		wp.workerCount--
+		if wp.onWorkerStop != nil {
+			wp.onWorkerStop(wp.workerCount)
+		}
+	}
+	if reaped > 0 {
+		wp.cond.Broadcast()
+	}
+	return reaped
+}
+
+// releaseClaim releases the claim w on the target with key.  The caller must hold wp.lock.
+func (wp *WorkerPool) releaseClaim(key lockResource, w workingTarget) {
+	delete(wp.currentlyWorking, key)
+	delete(wp.working, key)
+	wp.finishKind(w.target.GroupVersionResource)
+}
+
+// exitReaped ends a worker whose target was released by the reaper while it was stuck.  The reaper has already
+// stopped counting the worker.  A worker is started for the tasks it abandoned, if there is room for one.
+func (wp *WorkerPool) exitReaped() {
+	scope.Warnf("stuck status worker returned after its target was released")
+	wp.maybeAddWorker()
+	wp.running.Done()
+}
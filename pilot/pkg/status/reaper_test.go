@@ -0,0 +1,150 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"go.opencensus.io/stats/view"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func inFlightReapedCount(t *testing.T) float64 {
+	rows, err := view.RetrieveData(inFlightReaped.Name())
+	if err != nil {
+		t.Fatalf("failed to get value for %s: %v", inFlightReaped.Name(), err)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return rows[0].Data.(*view.SumData).Value
+}
+
+func TestWorkerPoolInFlightTimeout(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	stuck := make(chan struct{})
+	var writes int32
+	written := make(chan struct{}, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		// the first write leaks its worker until the test unblocks it
+		if atomic.AddInt32(&writes, 1) == 1 {
+			<-stuck
+		}
+		written <- struct{}{}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithInFlightTimeout(time.Minute), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	before := inFlightReapedCount(t)
+
+	wp.Push(outcomeTarget("a"), c, nil)
+	g.Eventually(func() int { return wp.Stats().InFlight }).Should(Equal(1))
+	wp.Push(outcomeTarget("a"), c, nil)
+	// the reaper only releases the target once it has been in flight for the timeout
+	for i := 0; i < reaperChecksPerTimeout-1; i++ {
+		g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+		fakeClock.Step(time.Minute / reaperChecksPerTimeout)
+	}
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+	fakeClock.Step(time.Minute / reaperChecksPerTimeout)
+
+	// a new worker processes the target again while the stuck worker is still blocked
+	g.Eventually(written).Should(Receive())
+	g.Expect(inFlightReapedCount(t) - before).To(Equal(1.0))
+	// the new worker took the second slot, as the stuck worker still holds the first
+	idle := Stats{
+		WorkerUtilization: []float64{0, 0},
+		HotTargets:        []TargetAttempts{{Target: outcomeTarget("a"), Attempts: 2}},
+	}
+	g.Eventually(wp.Stats).Should(Equal(idle))
+
+	// the stuck worker finishes its write, then exits without disturbing the pool
+	close(stuck)
+	g.Eventually(written).Should(Receive())
+	// the stuck worker was busy for the whole time it was stuck
+	idle.Utilization = 1
+	idle.WorkerUtilization = []float64{1, 0}
+	g.Eventually(wp.Stats).Should(Equal(idle))
+	g.Consistently(wp.Stats, 100*time.Millisecond).Should(Equal(idle))
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	g.Expect(wp.workerCount).To(Equal(uint(0)))
+}
+
+func TestWorkerPoolInFlightTimeoutOwnerGroup(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	stuck := make(chan struct{})
+	var writes int32
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		if atomic.AddInt32(&writes, 1) == 1 {
+			<-stuck
+		}
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithInFlightTimeout(time.Minute), WithOwnerGrouping(func(Resource) string {
+		return "owner"
+	}), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	// both targets are claimed by one worker, which gets stuck writing the first
+	wp.Push(outcomeTarget("a"), c, nil)
+	wp.Push(outcomeTarget("b"), c, nil)
+	g.Eventually(func() int { return wp.Stats().InFlight }).Should(Equal(2))
+	for i := 0; i < reaperChecksPerTimeout; i++ {
+		g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+		fakeClock.Step(time.Minute / reaperChecksPerTimeout)
+	}
+
+	// the whole group is released, and the stuck worker is only discounted once
+	g.Eventually(func() int { return wp.Stats().InFlight }).Should(Equal(0))
+	wp.lock.Lock()
+	g.Expect(wp.workerCount).To(Equal(uint(0)))
+	wp.lock.Unlock()
+	wp.Push(outcomeTarget("c"), c, nil)
+	g.Eventually(written).Should(Receive(Equal("c")))
+
+	// the stuck worker requeues the rest of its group once it returns
+	close(stuck)
+	g.Eventually(written).Should(Receive(Equal("a")))
+	g.Eventually(written).Should(Receive(Equal("b")))
+	g.Eventually(func() uint {
+		wp.lock.Lock()
+		defer wp.lock.Unlock()
+		return wp.workerCount
+	}).Should(Equal(uint(0)))
+}
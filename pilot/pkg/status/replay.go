@@ -0,0 +1,123 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// WithReplay remembers the last successfully written status for up to size targets, so that it can be written again
+// with ReplayLast.  Once more targets have been written, the least recently written are evicted.
+func WithReplay(size int) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		if size > 0 {
+			wp.lastWritten = newWrittenStatusCache(size)
+		}
+	}
+}
+
+// ReplayLast writes the last status successfully written for target to the current version of target again, for
+// example to restore status after it was overwritten by another actor, without recomputing it.  It is not
+// coordinated with the workers, so a task for target processed concurrently may write before or after the replay.
+func (wp *WorkerPool) ReplayLast(target Resource) error {
+	if wp.shards != nil {
+		return wp.shard(target).ReplayLast(target)
+	}
+	if wp.lastWritten == nil {
+		return fmt.Errorf("status replay is not enabled")
+	}
+	status, ok := wp.lastWritten.get(target)
+	if !ok {
+		return fmt.Errorf("no status has been written for %s", target)
+	}
+	cfg, err := wp.read(target)
+	if err != nil {
+		return fmt.Errorf("cannot replay status for %s: %v", target, err)
+	}
+	if cfg == nil {
+		return fmt.Errorf("cannot replay status for %s: not found", target)
+	}
+	x, err := GetOGProvider(status)
+	if err != nil {
+		x = replayedStatus{status}
+	}
+	changed, err := wp.writer(target)(cfg, x)
+	wp.pauseIfThrottled(target, err)
+	recordWrite(wp.dryRun != nil, changed, err)
+	return err
+}
+
+// replayedStatus wraps a replayed status which has no GenerationProvider.  Its observed generation was already set
+// when it was first written.
+type replayedStatus struct {
+	status interface{}
+}
+
+func (r replayedStatus) SetObservedGeneration(int64) {}
+
+func (r replayedStatus) Unwrap() interface{} {
+	return r.status
+}
+
+type writtenStatus struct {
+	key    lockResource
+	status interface{}
+}
+
+// writtenStatusCache retains the last written status for a bounded number of targets.
+type writtenStatusCache struct {
+	size    int
+	entries map[lockResource]*list.Element
+	// order of entries, least recently written first
+	order *list.List
+	lock  sync.Mutex
+}
+
+func newWrittenStatusCache(size int) *writtenStatusCache {
+	return &writtenStatusCache{
+		size:    size,
+		entries: make(map[lockResource]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (w *writtenStatusCache) add(target Resource, status interface{}) {
+	key := convert(target)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if e, ok := w.entries[key]; ok {
+		e.Value.(*writtenStatus).status = status
+		w.order.MoveToBack(e)
+		return
+	}
+	w.entries[key] = w.order.PushBack(&writtenStatus{key: key, status: status})
+	for w.order.Len() > w.size {
+		oldest := w.order.Front()
+		w.order.Remove(oldest)
+		delete(w.entries, oldest.Value.(*writtenStatus).key)
+	}
+}
+
+func (w *writtenStatusCache) get(target Resource) (interface{}, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	e, ok := w.entries[convert(target)]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*writtenStatus).status, true
+}
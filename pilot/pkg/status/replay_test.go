@@ -0,0 +1,92 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func TestReplayLast(t *testing.T) {
+	g := NewGomegaWithT(t)
+	// a fake store holding the persisted status of each config
+	var lock sync.Mutex
+	stored := map[string]interface{}{}
+	read := func(name string) interface{} {
+		lock.Lock()
+		defer lock.Unlock()
+		return stored[name]
+	}
+	wp := NewWorkerPool(func(cfg *config.Config, status interface{}) (bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		stored[cfg.Name] = status.(GenerationProvider).Unwrap()
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}, Status: read(r.Name)}
+	}, 1, WithReplay(10))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{ObservedGeneration: 1, Conditions: []*v1alpha1.IstioCondition{
+			{Type: "Reconciled", Status: "True"},
+		}}}
+	}}
+	target := outcomeTarget("a")
+	g.Expect(wp.ReplayLast(target)).NotTo(Succeed())
+
+	wp.Push(target, c, nil)
+	g.Eventually(func() interface{} { return read("a") }).ShouldNot(BeNil())
+	written := read("a")
+
+	// another actor wipes the status
+	lock.Lock()
+	stored["a"] = &v1alpha1.IstioStatus{}
+	lock.Unlock()
+
+	g.Expect(wp.ReplayLast(target)).To(Succeed())
+	g.Expect(read("a")).To(Equal(written))
+}
+
+func TestReplayLastDisabled(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0)
+	g.Expect(wp.ReplayLast(outcomeTarget("a"))).To(MatchError("status replay is not enabled"))
+}
+
+func TestWrittenStatusCacheEviction(t *testing.T) {
+	g := NewGomegaWithT(t)
+	w := newWrittenStatusCache(2)
+	w.add(outcomeTarget("a"), "a1")
+	w.add(outcomeTarget("b"), "b1")
+	// rewriting a keeps it, so b is the oldest
+	w.add(outcomeTarget("a"), "a2")
+	w.add(outcomeTarget("c"), "c1")
+
+	_, found := w.get(outcomeTarget("b"))
+	g.Expect(found).To(BeFalse())
+	status, found := w.get(outcomeTarget("a"))
+	g.Expect(found).To(BeTrue())
+	g.Expect(status).To(Equal("a2"))
+	status, _ = w.get(outcomeTarget("c"))
+	g.Expect(status).To(Equal("c1"))
+}
@@ -0,0 +1,103 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"time"
+)
+
+const (
+	defaultRequiredTimeout    = 30 * time.Second
+	defaultRequiredRetryDelay = time.Second
+)
+
+// WithRequiredControllerTimeout sets how long a write may be deferred waiting for a required controller to contribute,
+// measured from the first time it was deferred, and how long to wait between checks.
+func WithRequiredControllerTimeout(timeout, retryDelay time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.requiredTimeout = timeout
+		wp.requiredRetryDelay = retryDelay
+	}
+}
+
+// register makes the pool aware of c, so that writes can be deferred until c contributes if it is required.
+func (wp *WorkerPool) register(c *Controller) {
+	wp.lock.Lock()
+	_, known := wp.controllers[c]
+	wp.lock.Unlock()
+	if known {
+		return
+	}
+	for _, shard := range wp.shards {
+		shard.register(c)
+	}
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	if wp.q.byIdentity {
+		for old := range wp.controllers {
+			if old != c && old.Identity() == c.Identity() {
+				delete(wp.controllers, old)
+			}
+		}
+	}
+	wp.controllers[c] = struct{}{}
+}
+
+// missingRequired returns a required controller handling target which has not contributed to perControllerWork, or
+// nil if there is none.
+func (wp *WorkerPool) missingRequired(target Resource, perControllerWork map[*Controller]interface{}) *Controller {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	for c := range wp.controllers {
+		if !c.Required || !c.handles(target.GroupVersionResource) {
+			continue
+		}
+		if _, ok := perControllerWork[c]; !ok {
+			return c
+		}
+	}
+	return nil
+}
+
+// deferForRequired requeues target if a required controller has not yet contributed to it, until the required
+// controller timeout has elapsed.  It reports whether the write was deferred.
+func (wp *WorkerPool) deferForRequired(target Resource, perControllerWork map[*Controller]interface{}) bool {
+	missing := wp.missingRequired(target, perControllerWork)
+	key := convert(target)
+	if missing == nil {
+		wp.lock.Lock()
+		delete(wp.requiredWaits, key)
+		wp.lock.Unlock()
+		return false
+	}
+	now := wp.clock.Now()
+	wp.lock.Lock()
+	since, waiting := wp.requiredWaits[key]
+	if !waiting {
+		since = now
+		wp.requiredWaits[key] = now
+	}
+	if now.Sub(since) >= wp.requiredTimeout {
+		delete(wp.requiredWaits, key)
+		wp.lock.Unlock()
+		wp.logger(target).Warnf("writing status for %s without required controller %q, which has not contributed after %v",
+			target, missing.Identity(), wp.requiredTimeout)
+		return false
+	}
+	wp.lock.Unlock()
+	wp.logger(target).Debugf("deferring status write for %s until required controller %q contributes", target, missing.Identity())
+	wp.q.requeue(target, perControllerWork, wp.requiredRetryDelay)
+	return true
+}
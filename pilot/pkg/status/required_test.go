@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func newRequiredTestPool(written chan []string, timeout time.Duration) *WorkerPool {
+	return NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+		var types []string
+		for _, c := range status.(*IstioGenerationProvider).Conditions {
+			types = append(types, c.Type)
+		}
+		written <- types
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}, Status: &v1alpha1.IstioStatus{}}
+	}, 1, WithRequiredControllerTimeout(timeout, 10*time.Millisecond))
+}
+
+func conditionController(name string, required bool) *Controller {
+	return &Controller{Name: name, Required: required, fn: func(status interface{}, context interface{}) GenerationProvider {
+		s := status.(*IstioGenerationProvider)
+		s.Conditions = append(s.Conditions, &v1alpha1.IstioCondition{Type: name})
+		return s
+	}}
+}
+
+func TestRequiredControllerArrivesLate(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan []string, 10)
+	wp := newRequiredTestPool(written, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ready := conditionController("Ready", true)
+	wp.register(ready)
+	other := conditionController("Other", false)
+	target := outcomeTarget("a")
+
+	wp.Push(target, other, nil)
+	g.Consistently(written, 200*time.Millisecond).ShouldNot(Receive())
+	outcome, _ := wp.LastOutcome(target)
+	g.Expect(outcome.Type).To(Equal(OutcomeDeferred))
+
+	wp.Push(target, ready, nil)
+	g.Eventually(written).Should(Receive(ConsistOf("Ready", "Other")))
+}
+
+func TestRequiredControllerTimeout(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan []string, 10)
+	wp := newRequiredTestPool(written, 100*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	wp.register(conditionController("Ready", true))
+
+	wp.Push(outcomeTarget("a"), conditionController("Other", false), nil)
+	g.Eventually(written).Should(Receive(Equal([]string{"Other"})))
+	g.Expect(wp.requiredWaits).To(BeEmpty())
+}
+
+func TestRequiredControllerOnlyGatesHandledResources(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan []string, 10)
+	wp := newRequiredTestPool(written, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ready := conditionController("Ready", true)
+	ready.Handles = []schema.GroupVersionResource{{Group: "other", Version: "v1", Resource: "things"}}
+	wp.register(ready)
+
+	wp.Push(outcomeTarget("a"), conditionController("Other", false), nil)
+	g.Eventually(written).Should(Receive(Equal([]string{"Other"})))
+}
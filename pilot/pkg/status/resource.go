@@ -40,15 +40,17 @@ func ResourceFromString(s string) *Resource {
 		scope.Errorf("cannot unmarshal %s into resource identifier", s)
 		return nil
 	}
+	gvr := schema.GroupVersionResource{
+		Group:    pieces[0],
+		Version:  pieces[1],
+		Resource: pieces[2],
+	}
 	return &Resource{
-		GroupVersionResource: schema.GroupVersionResource{
-			Group:    pieces[0],
-			Version:  pieces[1],
-			Resource: pieces[2],
-		},
-		Namespace:  pieces[3],
-		Name:       pieces[4],
-		Generation: pieces[5],
+		GroupVersionResource: gvr,
+		Namespace:            pieces[3],
+		Name:                 pieces[4],
+		Generation:           pieces[5],
+		ClusterScoped:        isClusterScoped(gvr),
 	}
 }
 
@@ -58,6 +60,19 @@ type Resource struct {
 	Namespace  string
 	Name       string
 	Generation string
+	// ClusterScoped is set for resources which are not namespaced, so that they are never confused with a namespaced
+	// resource of the same name.  It is not included in the string form, and is inferred from the schema when parsed.
+	ClusterScoped bool
+	// Cluster identifies the cluster the resource lives in, for pools which write status to several clusters.  It is
+	// part of the key, so same-named resources in different clusters are queued and processed separately.  It is empty
+	// for a single cluster, and, like ClusterScoped, is not included in the string form.
+	Cluster string
+}
+
+// isClusterScoped reports whether the schema of gvr is cluster scoped.  Unknown resources are assumed to be namespaced.
+func isClusterScoped(gvr schema.GroupVersionResource) bool {
+	s, found := collections.All.FindByGroupVersionResource(gvr)
+	return found && s.Resource().IsClusterScoped()
 }
 
 func (r Resource) String() string {
@@ -78,6 +93,19 @@ func ResourceFromMetadata(i resource.Metadata) Resource {
 		Namespace:            i.FullName.Namespace.String(),
 		Name:                 i.FullName.Name.String(),
 		Generation:           strconv.FormatInt(i.Generation, 10),
+		ClusterScoped:        i.Schema.IsClusterScoped(),
+	}
+}
+
+// NewResource returns the Resource identifying cfg, a config of the resource gvr.  Its generation is formatted as the
+// generation check expects, so status computed for it is written to cfg.
+func NewResource(cfg *config.Config, gvr schema.GroupVersionResource) Resource {
+	return Resource{
+		GroupVersionResource: gvr,
+		Namespace:            cfg.Namespace,
+		Name:                 cfg.Name,
+		Generation:           strconv.FormatInt(cfg.Generation, 10),
+		ClusterScoped:        isClusterScoped(gvr),
 	}
 }
 
@@ -86,12 +114,7 @@ func ResourceFromModelConfig(c config.Config) Resource {
 	if gvr == nil {
 		return Resource{}
 	}
-	return Resource{
-		GroupVersionResource: *gvr,
-		Namespace:            c.Namespace,
-		Name:                 c.Name,
-		Generation:           strconv.FormatInt(c.Generation, 10),
-	}
+	return NewResource(&c, *gvr)
 }
 
 func ResourceToModelConfig(c Resource) config.Meta {
@@ -120,6 +143,9 @@ func GetOGProvider(in interface{}) (out GenerationProvider, err error) {
 	if ret, ok := in.(*v1alpha1.IstioStatus); ok {
 		return &IstioGenerationProvider{ret}, nil
 	}
+	if ret, ok := in.(SectionedStatus); ok {
+		return ret, nil
+	}
 	return nil, fmt.Errorf("cannot cast %T: %v to GenerationProvider", in, in)
 }
 
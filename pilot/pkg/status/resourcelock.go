@@ -16,10 +16,17 @@ package status
 
 import (
 	"context"
+	"fmt"
+	"runtime/pprof"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/clock"
 
 	"istio.io/api/meta/v1alpha1"
 	"istio.io/istio/pkg/config"
@@ -41,16 +48,64 @@ type WorkerQueue interface {
 }
 
 type cacheEntry struct {
-	// the cacheVale represents the latest version of the resource, including ResourceVersion
+	// the cacheVale represents the latest version of the resource, including Generation
 	cacheResource Resource
 	// the perControllerStatus represents the latest version of the ResourceStatus
 	perControllerStatus map[*Controller]interface{}
+	// the times at which the target was first and most recently pushed since it was last popped
+	firstPushed time.Time
+	lastPushed  time.Time
+	// if set, the task is not eligible before this time
+	notBefore time.Time
+	// set if the target was deleted while queued, and a final status should be written for it
+	deleted bool
+	// earlier generations of the target retained by WithOrderedGenerations, oldest first, to be processed before this
+	older []cacheEntry
+	// set on an earlier generation popped from older
+	retained bool
+	// identifies this stay of the target in the queue, once pushed, so that a PushToken cannot retract later pushes
+	id uint64
+	// logging labels of the latest push which carried any, attached to the worker's log output for the target
+	labels []interface{}
+	// if set, closed when this stay of the target in the queue ends, for PushContext to stop watching its context
+	stayEnded chan struct{}
 }
 
 type lockResource struct {
 	schema.GroupVersionResource
-	Namespace string
-	Name      string
+	Namespace     string
+	Name          string
+	ClusterScoped bool
+	Cluster       string
+}
+
+// inNamespace reports whether l is a namespaced resource in namespace.
+func (l lockResource) inNamespace(namespace string) bool {
+	return !l.ClusterScoped && l.Namespace == namespace
+}
+
+// less orders lockResources by group, version and resource, then cluster, then cluster-scoped before namespaced, then
+// namespace, then name.
+func (l lockResource) less(o lockResource) bool {
+	if l.Group != o.Group {
+		return l.Group < o.Group
+	}
+	if l.Version != o.Version {
+		return l.Version < o.Version
+	}
+	if l.Resource != o.Resource {
+		return l.Resource < o.Resource
+	}
+	if l.Cluster != o.Cluster {
+		return l.Cluster < o.Cluster
+	}
+	if l.ClusterScoped != o.ClusterScoped {
+		return l.ClusterScoped
+	}
+	if l.Namespace != o.Namespace {
+		return l.Namespace < o.Namespace
+	}
+	return l.Name < o.Name
 }
 
 func convert(i Resource) lockResource {
@@ -58,9 +113,25 @@ func convert(i Resource) lockResource {
 		GroupVersionResource: i.GroupVersionResource,
 		Namespace:            i.Namespace,
 		Name:                 i.Name,
+		ClusterScoped:        i.ClusterScoped,
+		Cluster:              i.Cluster,
 	}
 }
 
+// QueueOrder determines the order in which eligible tasks are popped from a WorkQueue.
+type QueueOrder int
+
+const (
+	// FIFOOrder pops tasks in the order they were first pushed.
+	FIFOOrder QueueOrder = iota
+	// SortedOrder pops tasks in key order (group, version and resource, then cluster, then cluster-scoped before
+	// namespaced, then namespace, then name) regardless of the order they were pushed in, making processing order
+	// reproducible.  Tasks are kept sorted as they are pushed, so pushing a new task costs a binary search plus
+	// shifting the tasks after it, rather than an append.  It is intended for tests and debugging rather than
+	// production throughput.
+	SortedOrder
+)
+
 type WorkQueue struct {
 	// tasks which are not currently executing but need to run
 	tasks []lockResource
@@ -68,45 +139,500 @@ type WorkQueue struct {
 	lock sync.Mutex
 	// for each task, a cacheEntry which can be updated before the task is run so that execution will have latest values
 	cache map[lockResource]cacheEntry
+	// if non-zero, a task is only eligible once it has not been pushed for this long
+	debounce time.Duration
+	// if non-zero, a debounced task becomes eligible at most this long after it was first pushed
+	maxDebounceWait time.Duration
+	// if non-zero, a task becomes eligible, ahead of other tasks, at most this long after it was first pushed
+	maxQueueLatency time.Duration
+	clock           clock.PassiveClock
+	order           QueueOrder
+	// if set, per-target progress maps are reused once their task has been processed
+	progressMaps *sync.Pool
+	// targets which remain queued but are not processed until released
+	held map[lockResource]struct{}
+	// if non-zero, the number of distinct targets each controller may have queued at once
+	maxPendingPerController int
+	// the number of queued targets each controller has progress in
+	pendingPerController map[*Controller]int
+	// if non-zero, the number of generations of each target kept queued, rather than only the latest
+	maxGenerations int
+	// if set, progress from controllers with the same Identity replaces rather than joins each other's
+	byIdentity bool
+	// kinds of target which are not popped, as they have the maximum number in flight
+	capped map[schema.GroupVersionResource]struct{}
+	// the last id given to a queued target
+	lastID uint64
+	// for each target ordered by WriteAfter, the targets it is written after
+	after map[lockResource][]lockResource
+	// if non-zero, the minimum time between writes of each target, the time each recently written target may next be
+	// popped, and the size of that map after it was last swept
+	minWriteInterval time.Duration
+	nextWrite        map[lockResource]time.Time
+	swept            int
+	// if non-zero, how long after its first push a target not queued before may be popped, and the targets queued
+	// before
+	initialSettle time.Duration
+	seen          map[lockResource]struct{}
+	// set once the pool has closed, so that PushContext no longer watches stays
+	staysEnded bool
 
 	OnPush func()
 }
 
-func (wq *WorkQueue) Push(target Resource, ctl *Controller, progress interface{}) {
+// Push adds progress from ctl to the task for target, reporting whether the target was already queued.  It reports
+// rejected if ctl already has the maximum number of targets queued.
+func (wq *WorkQueue) Push(target Resource, ctl *Controller, progress interface{}) (merged, rejected bool) {
+	merged, rejected, _ = wq.push(target, ctl, progress, nil)
+	return merged, rejected
+}
+
+// push is Push, also returning the id of the queued target.  labels, if set, replace the logging labels of the target.
+func (wq *WorkQueue) push(target Resource, ctl *Controller, progress interface{},
+	labels []interface{}) (merged, rejected bool, id uint64) {
 	wq.lock.Lock()
 	key := convert(target)
-	if item, inqueue := wq.cache[key]; inqueue {
+	now := wq.now()
+	item, inqueue := wq.cache[key]
+	_, pending := item.perControllerStatus[ctl]
+	if !pending {
+		if wq.maxPendingPerController > 0 && wq.pendingPerController[ctl] >= wq.maxPendingPerController {
+			wq.lock.Unlock()
+			return inqueue, true, 0
+		}
+		wq.addPending(ctl)
+	}
+	if inqueue && wq.byIdentity {
+		if old := wq.sameIdentity(item.perControllerStatus, ctl); old != nil && old != ctl {
+			delete(item.perControllerStatus, old)
+			wq.removePendingFor(old)
+		}
+	}
+	split := false
+	if inqueue {
+		if wq.maxGenerations > 1 && !item.deleted && item.cacheResource.Generation != target.Generation {
+			wq.retain(&item, wq.maxGenerations)
+		} else if ctl.Coalescing == CoalesceNever && pending && !item.deleted {
+			// the progress ctl already queued is processed in an event of its own, before this push
+			wq.retain(&item, 0)
+			split = true
+		}
+		// keep the latest version of the target, so that it is processed against the newest generation
+		item.cacheResource = target
+		// a push after a deletion means the target has been recreated
+		item.deleted = false
 		item.perControllerStatus[ctl] = progress
+		item.lastPushed = now
+		if labels != nil {
+			item.labels = labels
+		}
+		if item.id == 0 {
+			// requeued rather than pushed
+			wq.lastID++
+			item.id = wq.lastID
+		}
 		wq.cache[key] = item
+		id = item.id
 	} else {
+		perControllerStatus := wq.newProgressMap()
+		perControllerStatus[ctl] = progress
+		wq.lastID++
+		id = wq.lastID
 		wq.cache[key] = cacheEntry{
 			cacheResource:       target,
-			perControllerStatus: map[*Controller]interface{}{ctl: progress},
+			perControllerStatus: perControllerStatus,
+			firstPushed:         now,
+			lastPushed:          now,
+			notBefore:           wq.settle(key, now),
+			id:                  id,
+			labels:              labels,
 		}
-		wq.tasks = append(wq.tasks, key)
+		wq.addTask(key)
 	}
 	wq.lock.Unlock()
 	if wq.OnPush != nil {
 		wq.OnPush()
 	}
+	return inqueue && !split, false, id
+}
+
+// retain keeps the queued generation of item, so that it is processed before the generation being pushed, dropping
+// the oldest retained generation if there are already limit queued.  A zero limit retains every generation.  The caller
+// must hold wq.lock.
+func (wq *WorkQueue) retain(item *cacheEntry, limit int) {
+	perControllerStatus := wq.newProgressMap()
+	for c, progress := range item.perControllerStatus {
+		perControllerStatus[c] = progress
+	}
+	item.older = append(item.older, cacheEntry{
+		cacheResource:       item.cacheResource,
+		perControllerStatus: perControllerStatus,
+		firstPushed:         item.firstPushed,
+		lastPushed:          item.lastPushed,
+		retained:            true,
+		labels:              item.labels,
+	})
+	if limit > 0 && len(item.older) >= limit {
+		wq.releaseProgressMap(item.older[0].perControllerStatus)
+		item.older = item.older[1:]
+	}
+}
+
+// restore puts an earlier generation, popped but not processed, back in front of the generations retained for its
+// target, not to be processed for delay.  It is dropped if the target is no longer queued.
+func (wq *WorkQueue) restore(entry cacheEntry, delay time.Duration) {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	key := convert(entry.cacheResource)
+	item, inqueue := wq.cache[key]
+	if !inqueue {
+		wq.releaseProgressMap(entry.perControllerStatus)
+		return
+	}
+	item.older = append([]cacheEntry{entry}, item.older...)
+	if notBefore := wq.now().Add(delay); notBefore.After(item.notBefore) {
+		item.notBefore = notBefore
+	}
+	wq.cache[key] = item
+}
+
+// addPending counts a queued target ctl has progress in.  The caller must hold wq.lock.
+func (wq *WorkQueue) addPending(ctl *Controller) {
+	if wq.maxPendingPerController == 0 {
+		return
+	}
+	if wq.pendingPerController == nil {
+		wq.pendingPerController = make(map[*Controller]int)
+	}
+	wq.pendingPerController[ctl]++
+}
+
+// removePending stops counting a target removed from the queue against the controllers with progress in it.  The
+// caller must hold wq.lock.
+func (wq *WorkQueue) removePending(item cacheEntry) {
+	if wq.maxPendingPerController == 0 {
+		return
+	}
+	for ctl := range item.perControllerStatus {
+		wq.removePendingFor(ctl)
+	}
+}
+
+// removePendingFor stops counting one queued target against ctl.  The caller must hold wq.lock.
+func (wq *WorkQueue) removePendingFor(ctl *Controller) {
+	if wq.maxPendingPerController == 0 {
+		return
+	}
+	if wq.pendingPerController[ctl] <= 1 {
+		delete(wq.pendingPerController, ctl)
+	} else {
+		wq.pendingPerController[ctl]--
+	}
+}
+
+// sameIdentity returns the controller in perControllerStatus with the same Identity as ctl, preferring ctl itself,
+// or nil if there is none.  The caller must hold wq.lock.
+func (wq *WorkQueue) sameIdentity(perControllerStatus map[*Controller]interface{}, ctl *Controller) *Controller {
+	if _, ok := perControllerStatus[ctl]; ok {
+		return ctl
+	}
+	id := ctl.Identity()
+	for c := range perControllerStatus {
+		if c.Identity() == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// requeue queues perControllerWork for target again, to become eligible no sooner than delay from now.  If target
+// has been pushed since it was popped, the newer progress from each controller is kept.
+func (wq *WorkQueue) requeue(target Resource, perControllerWork map[*Controller]interface{}, delay time.Duration) {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	key := convert(target)
+	now := wq.now()
+	notBefore := now.Add(delay)
+	if item, inqueue := wq.cache[key]; inqueue {
+		for c, progress := range perControllerWork {
+			if wq.byIdentity && wq.sameIdentity(item.perControllerStatus, c) != nil {
+				continue
+			}
+			if _, ok := item.perControllerStatus[c]; !ok {
+				item.perControllerStatus[c] = progress
+				wq.addPending(c)
+			}
+		}
+		if notBefore.After(item.notBefore) {
+			item.notBefore = notBefore
+			wq.cache[key] = item
+		}
+		return
+	}
+	perControllerStatus := wq.newProgressMap()
+	for c, progress := range perControllerWork {
+		perControllerStatus[c] = progress
+		wq.addPending(c)
+	}
+	wq.cache[key] = cacheEntry{
+		cacheResource:       target,
+		perControllerStatus: perControllerStatus,
+		firstPushed:         now,
+		lastPushed:          now,
+		notBefore:           notBefore,
+	}
+	wq.addTask(key)
+}
+
+// newProgressMap returns an empty per-target progress map, reusing a released one if pooling is enabled.
+func (wq *WorkQueue) newProgressMap() map[*Controller]interface{} {
+	if wq.progressMaps == nil {
+		return make(map[*Controller]interface{})
+	}
+	return wq.progressMaps.Get().(map[*Controller]interface{})
+}
+
+// releaseProgressMap returns a progress map, which must no longer be referenced, for reuse.
+func (wq *WorkQueue) releaseProgressMap(m map[*Controller]interface{}) {
+	if wq.progressMaps == nil {
+		return
+	}
+	// clear the map so that the pool does not retain progress or controllers
+	for c := range m {
+		delete(m, c)
+	}
+	wq.progressMaps.Put(m)
+}
+
+// addTask adds key to the tasks to run, in the position determined by the queue order.
+func (wq *WorkQueue) addTask(key lockResource) {
+	if wq.order != SortedOrder {
+		wq.tasks = append(wq.tasks, key)
+		return
+	}
+	i := sort.Search(len(wq.tasks), func(i int) bool {
+		return key.less(wq.tasks[i])
+	})
+	wq.tasks = append(wq.tasks, lockResource{})
+	copy(wq.tasks[i+1:], wq.tasks[i:])
+	wq.tasks[i] = key
+}
+
+// PopResult describes the result of a Pop.
+type PopResult int
+
+const (
+	// PopGot means a task was popped.
+	PopGot PopResult = iota
+	// PopEmpty means there are no tasks in the queue.
+	PopEmpty
+	// PopAllExcluded means there are tasks in the queue, but none can be popped yet, because they are excluded, held
+	// or not yet eligible.
+	PopAllExcluded
+)
+
+func (p PopResult) String() string {
+	switch p {
+	case PopGot:
+		return "got"
+	case PopEmpty:
+		return "empty"
+	case PopAllExcluded:
+		return "all excluded"
+	}
+	return "unknown"
+}
+
+// Pop removes the first eligible item in the queue not in exclusion, and returns it along with it's latest progress.
+func (wq *WorkQueue) Pop(exclusion map[lockResource]struct{}) (Resource, map[*Controller]interface{}, PopResult) {
+	entry, result := wq.pop(exclusion)
+	return entry.cacheResource, entry.perControllerStatus, result
 }
 
-// Pop returns the first item in the queue not in exclusion, along with it's latest progress
-func (wq *WorkQueue) Pop(exclusion map[lockResource]struct{}) (target Resource, progress map[*Controller]interface{}) {
+// pop removes the first eligible entry in the queue not in exclusion.  Tasks which have been deleted are discarded as
+// they are encountered.
+func (wq *WorkQueue) pop(exclusion map[lockResource]struct{}) (cacheEntry, PopResult) {
 	wq.lock.Lock()
 	defer wq.lock.Unlock()
+	now := wq.now()
+	if wq.maxQueueLatency > 0 {
+		// tasks which have waited for the maximum latency jump ahead of all others
+		for i := 0; i < len(wq.tasks); i++ {
+			if wq.excluded(wq.tasks[i], exclusion) {
+				continue
+			}
+			if t, ok := wq.cache[wq.tasks[i]]; ok && !wq.deadline(t).After(now) && !wq.writableAt(t).After(now) {
+				return wq.remove(i, t), PopGot
+			}
+		}
+	}
 	for i := 0; i < len(wq.tasks); i++ {
-		if _, ok := exclusion[wq.tasks[i]]; !ok {
-			// remove from tasks
-			t, ok := wq.cache[wq.tasks[i]]
+		if wq.excluded(wq.tasks[i], exclusion) {
+			continue
+		}
+		t, ok := wq.cache[wq.tasks[i]]
+		if ok && wq.eligibleAt(t).After(now) {
+			continue
+		}
+		if !ok {
+			// deleted while queued
 			wq.tasks = append(wq.tasks[:i], wq.tasks[i+1:]...)
-			if !ok {
-				return Resource{}, nil
-			}
-			return t.cacheResource, t.perControllerStatus
+			i--
+			continue
+		}
+		return wq.remove(i, t), PopGot
+	}
+	if len(wq.tasks) == 0 {
+		return cacheEntry{}, PopEmpty
+	}
+	return cacheEntry{}, PopAllExcluded
+}
+
+// remove removes the task at index i, with cache entry t, from the queue.  If earlier generations of the target are
+// retained, the oldest is removed instead, and the task stays queued.  The caller must hold wq.lock.
+func (wq *WorkQueue) remove(i int, t cacheEntry) cacheEntry {
+	key := wq.tasks[i]
+	if len(t.older) > 0 && !t.deleted {
+		oldest := t.older[0]
+		t.older = t.older[1:]
+		wq.cache[key] = t
+		return oldest
+	}
+	for _, older := range t.older {
+		// a deleted target only has its final status written
+		wq.releaseProgressMap(older.perControllerStatus)
+	}
+	wq.tasks = append(wq.tasks[:i], wq.tasks[i+1:]...)
+	delete(wq.cache, key)
+	wq.removePending(t)
+	wq.endStay(&t)
+	return t
+}
+
+// NextEligible returns the earliest time at which a task not in exclusion, which is not yet eligible, will become
+// eligible.  It returns the zero time if there are no such tasks.
+func (wq *WorkQueue) NextEligible(exclusion map[lockResource]struct{}) time.Time {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	now := wq.now()
+	var next time.Time
+	for _, key := range wq.tasks {
+		if wq.excluded(key, exclusion) {
+			continue
+		}
+		t, ok := wq.cache[key]
+		if !ok {
+			continue
+		}
+		if at := wq.eligibleAt(t); at.After(now) && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	return next
+}
+
+// excluded reports whether key may not be popped, either because it is in exclusion or because it is held.  The
+// caller must hold wq.lock.
+func (wq *WorkQueue) excluded(key lockResource, exclusion map[lockResource]struct{}) bool {
+	if _, ok := exclusion[key]; ok {
+		return true
+	}
+	if _, held := wq.held[key]; held {
+		return true
+	}
+	if _, capped := wq.capped[key.GroupVersionResource]; capped {
+		return true
+	}
+	return wq.waitingOnParent(key, exclusion)
+}
+
+// Hold prevents target from being popped until it is released.  It may still be pushed.
+func (wq *WorkQueue) Hold(target Resource) {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	if wq.held == nil {
+		wq.held = make(map[lockResource]struct{})
+	}
+	wq.held[convert(target)] = struct{}{}
+}
+
+// Release allows a held target to be popped again.
+func (wq *WorkQueue) Release(target Resource) {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	delete(wq.held, convert(target))
+}
+
+// eligibleAt returns the time at which entry may be popped.
+func (wq *WorkQueue) eligibleAt(entry cacheEntry) time.Time {
+	at := wq.debouncedAt(entry)
+	if entry.notBefore.After(at) {
+		at = entry.notBefore
+	}
+	if wq.maxQueueLatency > 0 {
+		if deadline := wq.deadline(entry); deadline.Before(at) {
+			at = deadline
+		}
+	}
+	// the write interval holds even over the maximum queue latency
+	if writable := wq.writableAt(entry); writable.After(at) {
+		at = writable
+	}
+	return at
+}
+
+// deadline returns the time by which entry must be popped, if a maximum queue latency is set.
+func (wq *WorkQueue) deadline(entry cacheEntry) time.Time {
+	return entry.firstPushed.Add(wq.maxQueueLatency)
+}
+
+// debouncedAt returns the time at which entry may be popped, according to the debounce settings.
+func (wq *WorkQueue) debouncedAt(entry cacheEntry) time.Time {
+	if wq.debounce == 0 {
+		return entry.firstPushed
+	}
+	at := entry.lastPushed.Add(wq.debounce)
+	if wq.maxDebounceWait > 0 {
+		if deadline := entry.firstPushed.Add(wq.maxDebounceWait); deadline.Before(at) {
+			return deadline
+		}
+	}
+	return at
+}
+
+func (wq *WorkQueue) now() time.Time {
+	if wq.clock == nil {
+		return time.Now()
+	}
+	return wq.clock.Now()
+}
+
+// Pending returns a copy of the progress queued for target, keyed by controller name, or nil if target is not queued.
+func (wq *WorkQueue) Pending(target Resource) map[string]interface{} {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	item, inqueue := wq.cache[convert(target)]
+	if !inqueue {
+		return nil
+	}
+	out := make(map[string]interface{}, len(item.perControllerStatus))
+	for c, progress := range item.perControllerStatus {
+		out[c.Identity()] = progress
+	}
+	return out
+}
+
+// progress returns the progress ctl has queued for target, or nil.
+func (wq *WorkQueue) progress(target Resource, ctl *Controller) interface{} {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	perControllerStatus := wq.cache[convert(target)].perControllerStatus
+	if wq.byIdentity {
+		if c := wq.sameIdentity(perControllerStatus, ctl); c != nil {
+			return perControllerStatus[c]
 		}
 	}
-	return Resource{}, nil
+	return perControllerStatus[ctl]
 }
 
 func (wq *WorkQueue) Length() int {
@@ -115,129 +641,1531 @@ func (wq *WorkQueue) Length() int {
 	return len(wq.tasks)
 }
 
+// oldestPending returns when the longest queued target was first pushed, or the zero time if nothing is queued.
+func (wq *WorkQueue) oldestPending() time.Time {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	var oldest time.Time
+	for _, item := range wq.cache {
+		if oldest.IsZero() || item.firstPushed.Before(oldest) {
+			oldest = item.firstPushed
+		}
+	}
+	return oldest
+}
+
+// Delete removes the task for target from the queue.
 func (wq *WorkQueue) Delete(target Resource) {
 	wq.lock.Lock()
 	defer wq.lock.Unlock()
-	delete(wq.cache, convert(target))
+	key := convert(target)
+	delete(wq.nextWrite, key)
+	delete(wq.seen, key)
+	item, inqueue := wq.cache[key]
+	if !inqueue {
+		return
+	}
+	delete(wq.cache, key)
+	wq.removePending(item)
+	wq.endStay(&item)
+	wq.releaseProgressMap(item.perControllerStatus)
+	for i, k := range wq.tasks {
+		if k == key {
+			wq.tasks = append(wq.tasks[:i], wq.tasks[i+1:]...)
+			break
+		}
+	}
+}
+
+// markDeleted marks the task for target, if it is queued, to have a final status written, reporting whether it was
+// queued.
+func (wq *WorkQueue) markDeleted(target Resource) bool {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	key := convert(target)
+	delete(wq.seen, key)
+	item, inqueue := wq.cache[key]
+	if inqueue {
+		item.deleted = true
+		wq.cache[key] = item
+	}
+	return inqueue
+}
+
+// DeleteNamespace removes all queued tasks for targets in namespace, returning the removed targets.
+func (wq *WorkQueue) DeleteNamespace(namespace string) []Resource {
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	var deleted []Resource
+	tasks := wq.tasks[:0]
+	for _, key := range wq.tasks {
+		if !key.inNamespace(namespace) {
+			tasks = append(tasks, key)
+			continue
+		}
+		if item, ok := wq.cache[key]; ok {
+			deleted = append(deleted, item.cacheResource)
+			delete(wq.cache, key)
+			wq.removePending(item)
+			wq.endStay(&item)
+			wq.releaseProgressMap(item.perControllerStatus)
+		}
+	}
+	for key := range wq.after {
+		if key.inNamespace(namespace) {
+			delete(wq.after, key)
+		}
+	}
+	for key := range wq.seen {
+		if key.inNamespace(namespace) {
+			delete(wq.seen, key)
+		}
+	}
+	// clear the tail so that removed keys are not retained by the backing array
+	for i := len(tasks); i < len(wq.tasks); i++ {
+		wq.tasks[i] = lockResource{}
+	}
+	wq.tasks = tasks
+	return deleted
 }
 
 type WorkerPool struct {
 	q WorkQueue
 	// indicates the queue is closing
 	closing bool
-	// the function which will be run for each task in queue
-	write func(*config.Config, interface{})
+	// closed once the context passed to the last Run is done, and the pool has closed
+	stopped chan struct{}
+	// the function which will be run for each task in queue, guarded by lock so that it can be replaced at runtime
+	write WriteFunc
 	// the function to retrieve the initial status
 	get func(Resource) *config.Config
 	// current worker routine count
 	workerCount uint
-	// maximum worker routine count
+	// maximum worker routine count, adjusted by the autoscaler if autoscaling is enabled
 	maxWorkers       uint
 	currentlyWorking map[lockResource]struct{}
 	lock             sync.Mutex
+	// optionally emits Kubernetes events for targets which repeatedly fail to write
+	failureEvents *failureEventRecorder
+	// decides whether status computed for a target may be written to the retrieved config
+	generationMatch GenerationMatchFunc
+	// if set, derives the generation of a retrieved config from something other than metadata.generation
+	generationOf GenerationOfFunc
+	// broadcast when targets finish or are deleted, or the pool is closing, for callers waiting on the pool
+	cond *sync.Cond
+	// workers with nothing to pop wait on idle rather than cond, so that a push or a finished target wakes just one
+	idle  *sync.Cond
+	clock clock.WithDelayedExecution
+	// optional hooks invoked with the resulting workerCount as worker routines start and stop
+	onWorkerStart func(workerCount uint)
+	onWorkerStop  func(workerCount uint)
+	// invoked around the processing of each target, without holding the lock
+	beforeProcess func(target Resource)
+	afterProcess  func(target Resource, err error, duration time.Duration)
+	// invoked as a worker claims a target, with how long it was queued
+	onStartProcessing func(target Resource, queuedFor time.Duration)
+	// if set, invoked when processing a target fails, for the failures selected by errorReport
+	onError     func(target Resource, err error)
+	errorReport ErrorReport
+	// the last outcome of recently seen targets
+	outcomes *outcomeTracker
+	// if set, a ring of recent operations on targets
+	trace *operationTrace
+	// rolling totals of the time workers spent processing and waiting
+	utilization *utilizationTracker
+	// rolling processing attempt counts of recently processed targets
+	attempts *attemptTracker
+	// rolling counts of merged and total pushes
+	dedup *dedupTracker
+	// pushes not yet written, and rolling counts of wasted and total pushes
+	waste *wasteTracker
+	// rolling count of successful writes
+	throughput *throughputTracker
+	// wraps the application of each controller's UpdateFunc, and the resulting chain
+	applyMiddleware []ApplyMiddleware
+	apply           ApplyFunc
+	// if set, workers label themselves with their current target for goroutine profiles
+	profilerLabels bool
+	// the number of times a target superseded by a newer generation is requeued for that generation
+	maxRefetches uint
+	refetches    map[lockResource]uint
+	// coordinates processing of targets with other pools, if set
+	inFlight           InFlightTracker
+	inFlightRetryDelay time.Duration
+	// if set, IstioStatus conditions from different controllers are merged by type according to conditionPolicy
+	mergeConditions bool
+	conditionPolicy ConditionConflictPolicy
+	// if set, receives the result of each processed task
+	results chan ProcessResult
+	// if set, the last successfully written status of recently written targets
+	lastWritten *writtenStatusCache
+	// if set, the controllers which contributed to the last write of recently written targets
+	provenance *provenanceCache
+	// set once the caller has completed its initial sync, to label push and pop metrics
+	steady int32
+	// if set, targets are routed to these sub-pools rather than queued in this pool
+	shardCount uint
+	shards     []*WorkerPool
+	// if set, percentile estimates of queue wait and processing time
+	latencies *latencyTracker
+	// controllers known to the pool, and when targets first had their write deferred for a required controller
+	controllers        map[*Controller]struct{}
+	requiredWaits      map[lockResource]time.Time
+	requiredTimeout    time.Duration
+	requiredRetryDelay time.Duration
+	// dependencies registered for targets, and when targets first had their write deferred for them
+	dependencies         map[lockResource][]DependencyFunc
+	dependencyWaits      map[lockResource]dependencyWait
+	dependencyRetryDelay time.Duration
+	dependencyMaxWait    time.Duration
+	// decides whether targets deleted while queued are dropped or have a final status written
+	deleteMode DeleteMode
+	finalizer  FinalizerFunc
+	// each target being worked on, and when it was popped
+	working map[lockResource]workingTarget
+	// tracks running worker routines, so that Shutdown can wait for them to exit
+	running sync.WaitGroup
+	// if set, a semaphore limiting concurrent calls to get
+	reads chan struct{}
+	// how long to wait before processing a target again after get panicked
+	readRetryDelay time.Duration
+	// if set, receives the status which would have been written, in place of write
+	dryRun func(*config.Config, interface{})
+	// if set, prepares the payload for each write
+	marshaler Marshaler
+	// if set, at most one target is processed at a time across the pool
+	singleThreaded bool
+	// the number of syncs in progress, and the status staged during them by target, in the order first staged
+	syncs       int
+	staged      map[lockResource]stagedWrite
+	stagedOrder []lockResource
+	// how often to log a summary, and the tasks processed and skipped, guarded by lock
+	summaryInterval time.Duration
+	processed       int
+	skipped         int
+	// thresholds for Healthy, and when the backlog was first seen above maxHealthyBacklog
+	maxHealthyBacklog int
+	backlogGrace      time.Duration
+	wedgedAfter       time.Duration
+	backlogSince      time.Time
+	// thresholds for reporting a queue growing with no workers, when it was first seen, and whether it was reported
+	stallThreshold int
+	stallGrace     time.Duration
+	onStalled      func(queued int)
+	stalledSince   time.Time
+	stallReported  bool
+	// the maximum number of targets of each kind processed at once, and the number in flight, if kinds are capped
+	kindCaps       map[schema.GroupVersionResource]uint
+	defaultKindCap uint
+	kindInFlight   map[schema.GroupVersionResource]uint
+	// checks computed status before it is written, and the delay before retrying a target whose status is invalid
+	validateBeforeWrite func(target Resource, status interface{}) error
+	invalidRetryDelay   time.Duration
+	// classifies throttled writes, the pause after one without a Retry-After, and when workers may write again
+	throttled     ThrottleFunc
+	throttlePause time.Duration
+	pausedUntil   time.Time
+	// if workerCap is non-zero, maxWorkers is scaled between minWorkers and workerCap to keep the oldest queued target
+	// from waiting longer than targetLatency
+	targetLatency time.Duration
+	minWorkers    uint
+	workerCap     uint
+	// set while Shutdown drains the queue, and the transform applied to status written meanwhile
+	draining       bool
+	drainFinalizer DrainFinalizerFunc
+	// the pool which absorbed this one, to which pushes are forwarded
+	absorbedBy *WorkerPool
+	// the last claim made on a target, and how long a claim may be held before the reaper releases it
+	claims          uint64
+	inFlightTimeout time.Duration
+	// if set, derives the owner of a target, so that queued targets with the same owner are processed together
+	ownerKey func(target Resource) string
+	// decides whether a target with no progress from any controller is written
+	emptyProgress EmptyProgressPolicy
+	// if set, staged statuses identical according to statusEqual are written together
+	writeGrouped GroupedWriteFunc
+	statusEqual  StatusEqualFunc
+	// if set, shrinks progress before it is queued
+	reduceProgress func(progress interface{}) interface{}
+	// queue length thresholds and their callbacks, and whether the length last crossed the high one
+	highWatermark   int
+	lowWatermark    int
+	onHighWatermark func(queued int)
+	onLowWatermark  func(queued int)
+	aboveWatermark  bool
+	// the open transaction each target pushed in a transaction belongs to
+	transactions map[lockResource]*Transaction
+	// the callers of AwaitProcessed waiting on each target
+	awaiting map[lockResource]*awaitState
+	// if set, consulted before claiming each target, with workers parked while it returns false
+	canProcess     func() bool
+	breakerRecheck time.Duration
+	// if set, verifies that no target is processed by two routines at once
+	exclusivity *exclusivityCheck
+	// if tickBatch is set, workers only claim targets after a tick, up to tickBudget targets, ticking every
+	// tickInterval if it is set
+	tickBatch    int
+	tickInterval time.Duration
+	tickBudget   int
+	// if set, the generation is checked against a config retrieved again just before writing, rather than before the
+	// controllers are applied
+	lateGenerationCheck bool
+	// if set, status for targets with a cluster is written with clusterWrite rather than write
+	clusterWrite ClusterWriteFunc
+	// set by QuiesceInFlight until Resume, parking workers rather than letting them pop tasks
+	quiesced bool
+}
+
+// WorkerPoolOption configures optional behavior of a WorkerPool.
+type WorkerPoolOption func(*WorkerPool)
+
+// WriteFunc persists the computed status for a config.  It reports whether a change was actually persisted, which
+// will be false if the store recognized the update as a no-op and skipped it.
+type WriteFunc func(cfg *config.Config, status interface{}) (changed bool, err error)
+
+// WriteFuncFromVoid adapts a write function which does not report its outcome to a WriteFunc.  Every write is assumed
+// to have persisted a change.
+func WriteFuncFromVoid(write func(*config.Config, interface{})) WriteFunc {
+	return func(cfg *config.Config, status interface{}) (bool, error) {
+		write(cfg, status)
+		return true, nil
+	}
 }
 
-func NewWorkerPool(write func(*config.Config, interface{}), get func(Resource) *config.Config, maxWorkers uint) WorkerQueue {
-	return &WorkerPool{
-		write:            write,
-		get:              get,
-		maxWorkers:       maxWorkers,
-		currentlyWorking: make(map[lockResource]struct{}),
+func NewWorkerPool(write WriteFunc, get func(Resource) *config.Config, maxWorkers uint,
+	opts ...WorkerPoolOption) *WorkerPool {
+	wp := &WorkerPool{
+		write:                write,
+		get:                  get,
+		maxWorkers:           maxWorkers,
+		currentlyWorking:     make(map[lockResource]struct{}),
+		working:              make(map[lockResource]workingTarget),
+		maxHealthyBacklog:    defaultMaxHealthyBacklog,
+		backlogGrace:         defaultBacklogGrace,
+		wedgedAfter:          defaultWedgedAfter,
+		stallThreshold:       defaultStallThreshold,
+		stallGrace:           defaultStallGrace,
+		refetches:            make(map[lockResource]uint),
+		controllers:          make(map[*Controller]struct{}),
+		requiredWaits:        make(map[lockResource]time.Time),
+		requiredTimeout:      defaultRequiredTimeout,
+		requiredRetryDelay:   defaultRequiredRetryDelay,
+		dependencies:         make(map[lockResource][]DependencyFunc),
+		dependencyWaits:      make(map[lockResource]dependencyWait),
+		dependencyRetryDelay: defaultDependencyRetryDelay,
+		dependencyMaxWait:    defaultDependencyMaxWait,
+		readRetryDelay:       defaultReadRetryDelay,
+		throttlePause:        defaultThrottlePause,
+		generationMatch:      NumericGenerationMatch,
+		clock:                clock.RealClock{},
+		outcomes:             newOutcomeTracker(),
+		utilization:          newUtilizationTracker(),
+		attempts:             newAttemptTracker(),
+		dedup:                newDedupTracker(),
+		waste:                newWasteTracker(),
+		throughput:           newThroughputTracker(),
 		q: WorkQueue{
 			tasks:  make([]lockResource, 0),
 			cache:  make(map[lockResource]cacheEntry),
 			OnPush: nil,
 		},
 	}
+	wp.cond = sync.NewCond(&wp.lock)
+	wp.idle = sync.NewCond(&wp.lock)
+	for _, opt := range opts {
+		opt(wp)
+	}
+	wp.apply = wp.applyChain()
+	if wp.singleThreaded {
+		wp.maxWorkers = 1
+		wp.shardCount = 0
+		wp.q.order = FIFOOrder
+		wp.workerCap = 0
+	}
+	if wp.workerCap > 0 {
+		wp.maxWorkers = wp.minWorkers
+	}
+	wp.q.clock = wp.clock
+	wp.outcomes.clock = wp.clock
+	wp.utilization.clock = wp.clock
+	wp.utilization.setLimit(wp.maxWorkers)
+	wp.attempts.clock = wp.clock
+	wp.dedup.clock = wp.clock
+	wp.waste.clock = wp.clock
+	wp.throughput.clock = wp.clock
+	wp.throughput.created = wp.clock.Now()
+	if wp.shardCount > 1 {
+		wp.newShards(write, get, opts)
+	}
+	return wp
 }
 
-func (wp *WorkerPool) Delete(target Resource) {
-	wp.q.Delete(target)
+// WithSingleThreaded processes one target at a time across the whole pool, in exchange for throughput, to rule out
+// concurrency issues in controllers.  It overrides maxWorkers to 1, disables WithShards and forces FIFOOrder, so that
+// eligible targets are processed strictly in the order they were first pushed; debounced, delayed and held targets are
+// still only processed once eligible.  ProcessWithController waits for the worker to be idle, and the worker waits
+// for it.
+func WithSingleThreaded() WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.singleThreaded = true
+	}
 }
 
-func (wp *WorkerPool) Push(target Resource, controller *Controller, context interface{}) {
-	wp.q.Push(target, controller, context)
-	wp.maybeAddWorker()
+// WithMaxPendingPerController limits the number of distinct targets each controller may have queued at once, so that
+// a controller flooding the queue cannot starve the others.  Pushes from a controller at the limit for targets it has
+// not already queued are dropped and counted in the pilot_status_controller_limit_rejections metric.  Zero, the
+// default, is unlimited.
+func WithMaxPendingPerController(n int) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.q.maxPendingPerController = n
+	}
 }
 
-func (wp *WorkerPool) Run(ctx context.Context) {
-	go func() {
-		<-ctx.Done()
-		wp.lock.Lock()
-		wp.closing = true
-		wp.lock.Unlock()
+// WithMaxConcurrentReads limits the number of concurrent calls to retrieve configs, independently of the number of
+// workers, so that read and write pressure on the API server can be tuned separately.  Zero, the default, is
+// unlimited.
+func WithMaxConcurrentReads(n int) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		if n > 0 {
+			wp.reads = make(chan struct{}, n)
+		}
+	}
+}
+
+const defaultReadRetryDelay = time.Second
+
+// WithReadRetryDelay sets how long a target waits to be processed again after retrieving its config panicked, as
+// stores may while they are being swapped during reconfiguration.  The default is one second.
+func WithReadRetryDelay(delay time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.readRetryDelay = delay
+	}
+}
+
+// read retrieves the current config for target, waiting for the read semaphore if there is one.  A panic in get is
+// returned as an error.
+func (wp *WorkerPool) read(target Resource) (cfg *config.Config, err error) {
+	if wp.reads != nil {
+		wp.reads <- struct{}{}
+		defer func() {
+			<-wp.reads
+		}()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("get panicked: %v", r)
+		}
 	}()
+	return wp.get(target), nil
 }
 
-// maybeAddWorker adds a worker unless we are at maxWorkers.  Workers exit when there are no more tasks, except for the
-// last worker, which stays alive indefinitely.
-func (wp *WorkerPool) maybeAddWorker() {
-	wp.lock.Lock()
-	if wp.workerCount >= wp.maxWorkers || wp.q.Length() == 0 {
-		wp.lock.Unlock()
+// retry requeues work for a target which could not be processed, not to be processed again for delay, so that the
+// update is not lost to a transient failure such as a store error.
+func (wp *WorkerPool) retry(target Resource, perControllerWork map[*Controller]interface{}, retained bool,
+	delay time.Duration) {
+	if !retained {
+		wp.q.requeue(target, perControllerWork, delay)
 		return
 	}
-	wp.workerCount++
-	wp.lock.Unlock()
-	go func() {
-		for {
-			wp.lock.Lock()
-			if wp.closing || wp.q.Length() == 0 {
-				wp.workerCount--
-				wp.lock.Unlock()
-				return
-			}
+	// an earlier generation goes back in front of the newer ones, with a copy of its progress as the worker releases
+	// the original
+	perControllerStatus := wp.q.newProgressMap()
+	for c, progress := range perControllerWork {
+		perControllerStatus[c] = progress
+	}
+	wp.q.restore(cacheEntry{
+		cacheResource:       target,
+		perControllerStatus: perControllerStatus,
+		retained:            true,
+	}, delay)
+}
 
-			target, perControllerWork := wp.q.Pop(wp.currentlyWorking)
+// WithDryRun runs the pool without persisting anything: targets are processed as usual, but the computed status, or
+// the payload prepared by the Marshaler, is passed to capture rather than written, including by ReplayLast and when
+// processing deleted targets.  Every captured write is reported as a change, and is counted with the dry_run mode in
+// metrics.
+func WithDryRun(capture func(cfg *config.Config, status interface{})) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.dryRun = capture
+	}
+}
 
-			if target == (Resource{}) {
-				// continue or return?
-				// could have been deleted, or could be no items in queue not currently worked on.  need a way to differentiate.
-				wp.lock.Unlock()
-				continue
-			}
-			wp.q.Delete(target)
-			wp.currentlyWorking[convert(target)] = struct{}{}
-			wp.lock.Unlock()
-			// work should be done without holding the lock
-			cfg := wp.get(target)
-			if cfg != nil {
-				// Check that generation matches
-				if strconv.FormatInt(cfg.Generation, 10) == target.Generation {
-					var x GenerationProvider
-					x, err := GetOGProvider(cfg.Status)
-					if err != nil {
-						scope.Warnf("status has no observed generation, overwriting: %s", err)
-					} else {
-						x.SetObservedGeneration(cfg.Generation)
-					}
-					for c, i := range perControllerWork {
-						// TODO: this does not guarantee controller order.  perhaps it should?
-						x = c.fn(x, i)
-					}
-					wp.write(cfg, x)
-				}
-			}
-			wp.lock.Lock()
-			delete(wp.currentlyWorking, convert(target))
-			wp.lock.Unlock()
+// WithControllerIdentity keys each target's progress by controller Identity rather than by *Controller, so that a
+// controller recreated with the same Name replaces the contribution of the one it succeeds rather than applying
+// alongside it.  Unnamed controllers are still distinguished by address.
+func WithControllerIdentity() WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.q.byIdentity = true
+	}
+}
+
+// WithOrderedGenerations keeps up to n generations of each target queued, rather than collapsing pushes for a newer
+// generation into the queued task, so that the status of every generation is written in order.  Each earlier
+// generation is written with its own observed generation, with the progress controllers had pushed up to that
+// generation.  Once n generations are queued the oldest is dropped.  This trades write volume for completeness, and
+// applies to every target in the pool.  A target deleted while queued only has its final status written.
+func WithOrderedGenerations(n int) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.q.maxGenerations = n
+	}
+}
+
+// WithQueueOrder sets the order in which eligible tasks are processed.
+func WithQueueOrder(order QueueOrder) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.q.order = order
+	}
+}
+
+// WithProgressMapPooling reuses the per-target maps of controller progress once their task has been processed, to
+// reduce allocations when many distinct targets are pushed.
+func WithProgressMapPooling() WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.q.progressMaps = &sync.Pool{
+			New: func() interface{} {
+				return make(map[*Controller]interface{})
+			},
 		}
-	}()
+	}
 }
 
-type GenerationProvider interface {
-	SetObservedGeneration(int64)
-	Unwrap() interface{}
+// WithWorkerHooks sets functions which are invoked with the resulting worker count whenever a worker routine starts or
+// stops.  Either may be nil.  The hooks are invoked while holding the pool's lock, so they must be fast and must not
+// call back into the pool.
+func WithWorkerHooks(onStart, onStop func(workerCount uint)) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.onWorkerStart = onStart
+		wp.onWorkerStop = onStop
+	}
 }
 
-type IstioGenerationProvider struct {
-	*v1alpha1.IstioStatus
+// WithProcessHooks sets functions which are invoked before and after each target is processed, for cross-cutting
+// concerns such as tracing or per-target logging.  Either may be nil.  after receives the write error, which is nil if
+// the target was skipped, and how long processing took.  The hooks are invoked by the worker without holding the pool's
+// lock, so they may call back into the pool, but they delay the worker until they return.
+func WithProcessHooks(before func(target Resource), after func(target Resource, err error, duration time.Duration)) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.beforeProcess = before
+		wp.afterProcess = after
+	}
 }
 
-func (i *IstioGenerationProvider) SetObservedGeneration(in int64) {
-	i.ObservedGeneration = in
+// WithOnStartProcessing sets a function invoked as soon as a worker claims a target, before it is processed, with how
+// long the target was queued since it was first pushed.  Unlike the before hook of WithProcessHooks, it is invoked for
+// every claimed target, including those then found to be processed by another pool.  It is invoked without holding the
+// pool's lock, but delays the worker until it returns.
+func WithOnStartProcessing(onStart func(target Resource, queuedFor time.Duration)) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.onStartProcessing = onStart
+	}
 }
 
-func (i *IstioGenerationProvider) Unwrap() interface{} {
-	return i.IstioStatus
+// WithProgressReducer stores the progress pushed by controllers as reduce returns it, for example with verbose fields
+// which status is not computed from dropped, to bound the memory held by queued progress.  reduce must be lossless for
+// status purposes: controllers' UpdateFuncs receive the reduced progress.  It is not called for nil progress.  By
+// default progress is stored as pushed.
+func WithProgressReducer(reduce func(progress interface{}) interface{}) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.reduceProgress = reduce
+	}
+}
+
+// WithProfilerLabels sets pprof labels identifying the target on each worker while it processes the target, so that
+// goroutine profiles show which resource each worker is working on.
+func WithProfilerLabels() WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.profilerLabels = true
+	}
+}
+
+// WithGenerationRefetch requeues a target whose generation has been superseded by the time it is processed, for the
+// generation of the config just retrieved, rather than dropping it.  This keeps status from falling perpetually
+// behind a rapidly changing config.  A target is requeued at most maxAttempts times in a row.
+func WithGenerationRefetch(maxAttempts uint) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.maxRefetches = maxAttempts
+	}
+}
+
+// WithLateGenerationCheck checks the generation of a target just before its status is written, against the config
+// retrieved again, rather than before the controllers are applied.  Status is computed whatever the generation of the
+// config first retrieved, and only the write is gated, so that work is not dropped for a generation change which
+// settles before processing ends.  It suits controllers which are cheap and idempotent, at the cost of a second read
+// per target.  Status is written to the config retrieved again, and a mismatch is handled as usual, including by
+// WithGenerationRefetch.
+func WithLateGenerationCheck() WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.lateGenerationCheck = true
+	}
+}
+
+// WithDebounce delays processing of a target until it has not been pushed for the quiet period, so that only the
+// settled state of a resource updated in a burst is written.  Each push resets the quiet period; if maxWait is
+// non-zero, a target is processed at most maxWait after it was first pushed even if it is still being updated.
+func WithDebounce(quiet, maxWait time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.q.debounce = quiet
+		wp.q.maxDebounceWait = maxWait
+	}
+}
+
+// WithMaxQueueLatency bounds how long a target may remain queued, regardless of debouncing or retry delays, so that
+// status never falls more than roughly maxLatency behind even under continuous churn.  Once a target has been queued
+// for maxLatency since it was first pushed, it becomes eligible immediately and is processed ahead of other tasks.
+func WithMaxQueueLatency(maxLatency time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.q.maxQueueLatency = maxLatency
+	}
+}
+
+// Delete removes the queued task for target, or with DeleteProcessFinal marks it to have a final status written.
+func (wp *WorkerPool) Delete(target Resource) {
+	if wp.shards != nil {
+		wp.shard(target).Delete(target)
+		return
+	}
+	if wp.deleteMode != DeleteProcessFinal || !wp.q.markDeleted(target) {
+		wp.q.Delete(target)
+		wp.dropped(target, OutcomeDeleted)
+	}
+	wp.lock.Lock()
+	delete(wp.refetches, convert(target))
+	delete(wp.requiredWaits, convert(target))
+	wp.forgetDependencies(convert(target))
+	wp.q.clearOrdering(convert(target))
+	wp.notifyDeleted(convert(target))
+	// wake Shutdown if it is draining the queue
+	wp.cond.Broadcast()
+	// wake workers waiting for targets ordered after target
+	wp.idle.Broadcast()
+	wp.lock.Unlock()
+	wp.outcomes.record(target, OutcomeDeleted, nil)
+	if wp.failureEvents != nil {
+		wp.failureEvents.forget(target)
+	}
+	wp.checkWatermarks()
+}
+
+// DeleteNamespace removes all queued tasks for targets in namespace, for example because the namespace is being
+// deleted, and returns the number of tasks removed.  Targets in the namespace which are already being processed are
+// not interrupted.
+func (wp *WorkerPool) DeleteNamespace(namespace string) int {
+	if wp.shards != nil {
+		deleted := 0
+		for _, shard := range wp.shards {
+			deleted += shard.DeleteNamespace(namespace)
+		}
+		return deleted
+	}
+	deleted := wp.q.DeleteNamespace(namespace)
+	wp.lock.Lock()
+	for key := range wp.refetches {
+		if key.inNamespace(namespace) {
+			delete(wp.refetches, key)
+		}
+	}
+	for key := range wp.requiredWaits {
+		if key.inNamespace(namespace) {
+			delete(wp.requiredWaits, key)
+		}
+	}
+	for key := range wp.dependencies {
+		if key.inNamespace(namespace) {
+			wp.forgetDependencies(key)
+		}
+	}
+	for key := range wp.awaiting {
+		if key.inNamespace(namespace) {
+			wp.notifyDeleted(key)
+		}
+	}
+	// wake Shutdown if it is draining the queue, and workers waiting for targets ordered after the deleted targets
+	wp.cond.Broadcast()
+	wp.idle.Broadcast()
+	wp.lock.Unlock()
+	for _, target := range deleted {
+		wp.dropped(target, OutcomeDeleted)
+		wp.outcomes.record(target, OutcomeDeleted, nil)
+		if wp.failureEvents != nil {
+			wp.failureEvents.forget(target)
+		}
+	}
+	recordNamespaceDeletion(len(deleted))
+	wp.checkWatermarks()
+	return len(deleted)
+}
+
+// Hold stops target from being processed until Release is called, for example while investigating a resource caught
+// in a write loop, without stopping the rest of the pool.  A held target stays queued and keeps accumulating the
+// latest progress from pushes.  A target being processed when it is held is not interrupted.
+func (wp *WorkerPool) Hold(target Resource) {
+	if wp.shards != nil {
+		wp.shard(target).Hold(target)
+		return
+	}
+	wp.q.Hold(target)
+}
+
+// Release allows a target stopped by Hold to be processed again.
+func (wp *WorkerPool) Release(target Resource) {
+	if wp.shards != nil {
+		wp.shard(target).Release(target)
+		return
+	}
+	wp.q.Release(target)
+	wp.maybeAddWorker()
+}
+
+// MarkSteadyState records that the caller has completed its initial sync, for example once all informers have synced
+// and the initial resync has been pushed.  Until then, pushes and pops are counted in the pilot_status_pushes and
+// pilot_status_pops metrics with phase "startup", and afterwards with phase "steady", so that the expected churn when
+// Pilot starts can be excluded from alerting.  Other metrics are not affected.
+func (wp *WorkerPool) MarkSteadyState() {
+	for _, shard := range wp.shards {
+		shard.MarkSteadyState()
+	}
+	atomic.StoreInt32(&wp.steady, 1)
+}
+
+func (wp *WorkerPool) isSteady() bool {
+	return atomic.LoadInt32(&wp.steady) == 1
+}
+
+// SetWrite replaces the function used to write status, for example to migrate between status backends without
+// dropping queued work.  Tasks which start processing after SetWrite returns use write; a task already being processed
+// completes with the function it started with, so writes by the previous function may still happen shortly after
+// SetWrite returns.
+func (wp *WorkerPool) SetWrite(write WriteFunc) {
+	for _, shard := range wp.shards {
+		shard.SetWrite(write)
+	}
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	wp.write = write
+}
+
+// writer returns the function which writes status for target.
+func (wp *WorkerPool) writer(target Resource) WriteFunc {
+	var write WriteFunc
+	if wp.dryRun != nil {
+		write = WriteFuncFromVoid(wp.dryRun)
+	} else if wp.clusterWrite != nil && target.Cluster != "" {
+		write = wp.clusterWriter(target.Cluster)
+	} else {
+		wp.lock.Lock()
+		write = wp.write
+		wp.lock.Unlock()
+	}
+	if wp.marshaler != nil {
+		return marshaling(wp.marshaler, write)
+	}
+	return write
+}
+
+// PendingProgress returns a copy of the progress each controller has queued for target, keyed by controller name, or
+// nil if target is not queued.  Controllers without a name are keyed by their address.
+func (wp *WorkerPool) PendingProgress(target Resource) map[string]interface{} {
+	if wp.shards != nil {
+		return wp.shard(target).PendingProgress(target)
+	}
+	return wp.q.Pending(target)
+}
+
+func (wp *WorkerPool) Push(target Resource, controller *Controller, context interface{}) {
+	wp.push(target, controller, context, nil)
+}
+
+// push is Push, returning a token for the progress queued, which is the zero PushToken if the push was dropped.
+// labels, if set, are attached to the worker's log output for target.
+func (wp *WorkerPool) push(target Resource, controller *Controller, context interface{}, labels []interface{}) PushToken {
+	if next := wp.forwardTo(); next != nil {
+		return next.push(target, controller, context, labels)
+	}
+	wp.register(controller)
+	if wp.shards != nil {
+		return wp.shard(target).push(target, controller, context, labels)
+	}
+	if !controller.handles(target.GroupVersionResource) {
+		scope.Warnf("dropping status update for %s from controller %q, which does not handle %s",
+			target, controller.Name, target.GroupVersionResource)
+		return PushToken{}
+	}
+	if context == nil && controller.NilProgress == NilProgressReject {
+		scope.Warnf("dropping status update for %s from controller %q with nil context", target, controller.Name)
+		return PushToken{}
+	}
+	if context != nil && wp.reduceProgress != nil {
+		context = wp.reduceProgress(context)
+	}
+	recordPush(wp.isSteady())
+	merged, rejected, id := wp.q.push(target, controller, context, labels)
+	if rejected {
+		scope.Warnf("dropping status update for %s from controller %q, which has too many targets queued",
+			target, controller.Identity())
+		controllerLimitRejections.Increment()
+		return PushToken{}
+	}
+	wp.dedup.record(merged)
+	wp.waste.pushed(target)
+	if merged {
+		wp.outcomes.record(target, OutcomeDeduped, nil)
+	} else {
+		wp.outcomes.record(target, OutcomeQueued, nil)
+	}
+	wp.maybeAddWorker()
+	wp.checkStalled()
+	wp.checkWatermarks()
+	return PushToken{pool: wp, key: convert(target), ctl: controller, id: id}
+}
+
+func (wp *WorkerPool) Run(ctx context.Context) {
+	for _, shard := range wp.shards {
+		shard.Run(ctx)
+	}
+	if wp.summaryInterval > 0 {
+		go wp.logSummaries(ctx)
+	}
+	if wp.workerCap > 0 && wp.shards == nil {
+		go wp.autoscale(ctx)
+	}
+	if wp.inFlightTimeout > 0 && wp.shards == nil {
+		go wp.reapInFlight(ctx)
+	}
+	if wp.tickBatch > 0 && wp.tickInterval > 0 && wp.shards == nil {
+		go wp.tickEvery(ctx)
+	}
+	stopped := make(chan struct{})
+	wp.lock.Lock()
+	wp.stopped = stopped
+	wp.lock.Unlock()
+	go func() {
+		<-ctx.Done()
+		wp.close()
+		close(stopped)
+	}()
+}
+
+func (wp *WorkerPool) close() {
+	wp.lock.Lock()
+	wp.closing = true
+	wp.q.endStays()
+	wp.cond.Broadcast()
+	wp.idle.Broadcast()
+	wp.lock.Unlock()
+}
+
+// Shutdown stops the pool and blocks until no more writes will happen, so that callers such as a leader handing off
+// can be sure the pool has stopped writing.  If drain is set, queued tasks which can be processed are processed first;
+// held and delayed tasks are waited for too, and status written while draining passes through the drain finalizer, if
+// set.  Targets being processed when the pool stops complete their write.  If ctx is done first, Shutdown returns its
+// error, and writes may still happen.
+func (wp *WorkerPool) Shutdown(ctx context.Context, drain bool) error {
+	for _, shard := range wp.shards {
+		if err := shard.Shutdown(ctx, drain); err != nil {
+			return err
+		}
+	}
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	wp.draining = drain
+	if drain {
+		_ = waitCond(ctx, wp.cond, func() bool {
+			return wp.q.Length() == 0 && len(wp.currentlyWorking) == 0
+		})
+	}
+	wp.closing = true
+	wp.q.endStays()
+	wp.cond.Broadcast()
+	wp.idle.Broadcast()
+	_ = waitCond(ctx, wp.cond, func() bool {
+		return len(wp.currentlyWorking) == 0
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	wp.lock.Unlock()
+	// workers exit once they see the pool is closing, without writing
+	wp.running.Wait()
+	wp.lock.Lock()
+	return nil
+}
+
+// maybeAddWorker adds a worker unless we are at maxWorkers.  Workers exit when there are no more tasks, including the
+// last one, so an idle pool has no workers.  A push cannot be stranded by the last worker exiting: the task is queued
+// before maybeAddWorker is called, and both the worker's exit check and this check of the queue length happen under
+// wp.lock, so either the worker sees the task and keeps going, or this sees the worker gone and starts another.
+func (wp *WorkerPool) maybeAddWorker() {
+	wp.lock.Lock()
+	// wake a worker waiting for an excluded or delayed task, as this push may have made a task eligible.  A push adds at
+	// most one task, so waking every worker would only have all but one find nothing and wait again.
+	wp.idle.Signal()
+	if wp.closing || wp.workerCount >= wp.maxWorkers || wp.q.Length() == 0 {
+		wp.lock.Unlock()
+		return
+	}
+	wp.workerCount++
+	if wp.onWorkerStart != nil {
+		wp.onWorkerStart(wp.workerCount)
+	}
+	wp.running.Add(1)
+	wp.lock.Unlock()
+	go wp.work()
+}
+
+// work pops and processes tasks until the queue is empty, the pool is closing or the autoscaler has lowered
+// maxWorkers below the number of workers.
+func (wp *WorkerPool) work() {
+	slot := wp.utilization.join()
+	defer wp.utilization.leave(slot)
+	// set once the worker has waited for a task, until it next pops one
+	woken := false
+	for {
+		closed := wp.breakerClosed()
+		wp.lock.Lock()
+		if wp.closing || wp.q.Length() == 0 || wp.workerCount > wp.maxWorkers {
+			if wp.q.Length() == 0 {
+				// no target is ready, so the rest of this tick's allowance lapses
+				wp.spendTick(wp.tickBudget)
+			}
+			wp.workerCount--
+			if wp.onWorkerStop != nil {
+				wp.onWorkerStop(wp.workerCount)
+			}
+			wp.running.Done()
+			wp.lock.Unlock()
+			return
+		}
+		if wp.quiesced {
+			wp.waitForWork(time.Time{})
+			wp.lock.Unlock()
+			continue
+		}
+		if wp.singleThreaded && len(wp.currentlyWorking) > 0 {
+			// ProcessWithController is processing a target
+			wp.waitForWork(time.Time{})
+			wp.lock.Unlock()
+			continue
+		}
+		if !closed {
+			// the circuit breaker is open, park until it is consulted again
+			wp.waitForWork(wp.clock.Now().Add(wp.breakerRecheck))
+			wp.lock.Unlock()
+			continue
+		}
+		if wp.clock.Now().Before(wp.pausedUntil) {
+			// a write was throttled, hold off until the store is ready for more
+			wp.waitForWork(wp.pausedUntil)
+			wp.lock.Unlock()
+			continue
+		}
+		if wp.awaitingTick() {
+			wp.waitForWork(time.Time{})
+			wp.lock.Unlock()
+			continue
+		}
+
+		entry, result := wp.q.pop(wp.currentlyWorking)
+		switch result {
+		case PopEmpty:
+			// the remaining tasks had been deleted, exit at the top of the loop
+			wp.lock.Unlock()
+			continue
+		case PopAllExcluded:
+			if woken {
+				spuriousWakeups.Increment()
+			}
+			// no target is ready, so the rest of this tick's allowance lapses
+			wp.spendTick(wp.tickBudget)
+			if next := wp.q.NextEligible(wp.currentlyWorking); !next.IsZero() {
+				// the remaining tasks are delayed, wait for them rather than spinning
+				wp.waitForWork(next)
+			} else {
+				// the remaining tasks are being processed or held, wait for a worker to finish, a push or a release
+				wp.waitForWork(time.Time{})
+			}
+			woken = true
+			wp.lock.Unlock()
+			continue
+		}
+		woken = false
+		claim := wp.claim(entry.cacheResource, true)
+		wp.labelWorking(entry)
+		var group []cacheEntry
+		if wp.ownerKey != nil {
+			// targets with the same owner are processed in this turn too, to present a consistent view
+			group = wp.q.popOwned(wp.ownerKey, entry.cacheResource, wp.currentlyWorking)
+		}
+		claims := make([]uint64, len(group))
+		for i, e := range group {
+			claims[i] = wp.claimInTurn(e.cacheResource, claim)
+			wp.labelWorking(e)
+		}
+		wp.spendTick(1 + len(group))
+		wp.lock.Unlock()
+		wp.checkWatermarks()
+		if wp.onStartProcessing != nil {
+			wp.onStartProcessing(entry.cacheResource, wp.clock.Since(entry.firstPushed))
+			for _, e := range group {
+				wp.onStartProcessing(e.cacheResource, wp.clock.Since(e.firstPushed))
+			}
+		}
+		if !wp.processEntry(entry, claim, slot) {
+			wp.abandon(group, claims)
+			wp.exitReaped()
+			return
+		}
+		for i, e := range group {
+			if !wp.processEntry(e, claims[i], slot) {
+				wp.abandon(group[i+1:], claims[i+1:])
+				wp.exitReaped()
+				return
+			}
+		}
+	}
+}
+
+// processEntry processes a popped task, claimed by the worker in slot under claim.  It reports false if the reaper
+// released the claim while the task was being processed, in which case the worker must exit.
+func (wp *WorkerPool) processEntry(entry cacheEntry, claim uint64, slot int) bool {
+	target, perControllerWork := entry.cacheResource, entry.perControllerStatus
+	recordPop(wp.isSteady())
+	wp.trace.record(OperationPop, target, wp.clock.Now(), "")
+	if wp.inFlight != nil && !wp.inFlight.TryAcquire(target) {
+		// another pool is processing the target, try again later
+		wp.logger(target).Debugf("%s is being processed elsewhere, requeueing", target)
+		wp.requeueEntry(entry, wp.inFlightRetryDelay)
+		return wp.finish(target, claim)
+	}
+	wp.attempts.record(target)
+	start := wp.clock.Now()
+	// work should be done without holding the lock
+	process := wp.process
+	if entry.deleted {
+		process = wp.processFinal
+	} else if entry.retained {
+		process = wp.processRetained
+	}
+	if wp.beforeProcess != nil {
+		wp.beforeProcess(target)
+	}
+	wp.exclusivity.begin(target)
+	var err error
+	if wp.profilerLabels {
+		labels := pprof.Labels("gvr", target.GroupVersionResource.String(), "namespace", target.Namespace, "name", target.Name)
+		pprof.Do(context.Background(), labels, func(context.Context) {
+			err = process(target, perControllerWork)
+		})
+	} else {
+		err = process(target, perControllerWork)
+	}
+	wp.exclusivity.end(target)
+	wp.utilization.record(slot, wp.clock.Since(start))
+	if wp.afterProcess != nil {
+		wp.afterProcess(target, err, wp.clock.Since(start))
+	}
+	if wp.latencies != nil {
+		wp.latencies.observe(start.Sub(entry.firstPushed), wp.clock.Since(start))
+	}
+	wp.q.releaseProgressMap(perControllerWork)
+	if wp.inFlight != nil {
+		wp.inFlight.Release(target)
+	}
+	return wp.finish(target, claim)
+}
+
+// requeueEntry queues a popped task again, not to be processed for delay.
+func (wp *WorkerPool) requeueEntry(entry cacheEntry, delay time.Duration) {
+	if entry.retained {
+		wp.q.restore(entry, delay)
+		return
+	}
+	wp.q.requeue(entry.cacheResource, entry.perControllerStatus, delay)
+	if entry.deleted {
+		wp.q.markDeleted(entry.cacheResource)
+	}
+	wp.q.releaseProgressMap(entry.perControllerStatus)
+}
+
+// abandon queues again the tasks of a group a worker will not process, releasing their claims.
+func (wp *WorkerPool) abandon(group []cacheEntry, claims []uint64) {
+	for i, entry := range group {
+		wp.requeueEntry(entry, 0)
+		wp.finish(entry.cacheResource, claims[i])
+	}
+}
+
+// workingTarget is a target being worked on, with the full identity it was pushed with.
+type workingTarget struct {
+	target Resource
+	since  time.Time
+	// identifies this claim on the target, so that a claim released by the reaper is not released again
+	claim uint64
+	// set if the target is being processed by a worker, rather than by ProcessWithController, to the claim on the
+	// first target the worker popped in its turn, so that the targets of an owner group count as a single worker
+	turn uint64
+	// the logging labels pushed with the target, if any
+	labels []interface{}
+}
+
+// claim marks target as being worked on, by a worker starting a turn if worker is set, and returns the claim to pass
+// to finish.  The caller must hold wp.lock.
+func (wp *WorkerPool) claim(target Resource, worker bool) uint64 {
+	var turn uint64
+	if worker {
+		turn = wp.claims + 1
+	}
+	return wp.claimInTurn(target, turn)
+}
+
+// claimInTurn marks target as being worked on by the worker whose turn began with the claim turn, or not by a worker
+// if turn is zero, and returns the claim to pass to finish.  The caller must hold wp.lock.
+func (wp *WorkerPool) claimInTurn(target Resource, turn uint64) uint64 {
+	wp.claims++
+	key := convert(target)
+	wp.currentlyWorking[key] = struct{}{}
+	wp.working[key] = workingTarget{target: target, since: wp.clock.Now(), claim: wp.claims, turn: turn}
+	wp.startKind(target.GroupVersionResource)
+	return wp.claims
+}
+
+// finish marks target as no longer being worked on under claim, waking workers waiting for it.  It reports false,
+// doing nothing, if the reaper has already released the claim.  A zero claim is released unconditionally.
+func (wp *WorkerPool) finish(target Resource, claim uint64) bool {
+	wp.lock.Lock()
+	if w, ok := wp.working[convert(target)]; claim != 0 && (!ok || w.claim != claim) {
+		wp.lock.Unlock()
+		return false
+	}
+	delete(wp.currentlyWorking, convert(target))
+	delete(wp.working, convert(target))
+	wp.finishKind(target.GroupVersionResource)
+	wp.cond.Broadcast()
+	// the target's queued task, if any, may now be processed
+	wp.idle.Signal()
+	wp.lock.Unlock()
+	return true
+}
+
+// waitUntil blocks the calling worker until at, or until it is woken by a push, a worker finishing or the pool
+// closing.  The caller must hold wp.lock.
+func (wp *WorkerPool) waitUntil(at time.Time) {
+	timer := wp.clock.AfterFunc(at.Sub(wp.clock.Now()), func() {
+		wp.lock.Lock()
+		wp.idle.Signal()
+		wp.lock.Unlock()
+	})
+	wp.idle.Wait()
+	timer.Stop()
+}
+
+// waitCond waits on cond until ready reports true, returning nil, or until ctx is done, returning its error.  The
+// caller must hold cond.L, with which ready is called.
+func waitCond(ctx context.Context, cond *sync.Cond, ready func() bool) error {
+	if ready() {
+		return nil
+	}
+	if ctx.Done() != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			// wake the waiter if ctx is done while it is waiting
+			select {
+			case <-ctx.Done():
+				cond.L.Lock()
+				cond.Broadcast()
+				cond.L.Unlock()
+			case <-done:
+			}
+		}()
+	}
+	for !ready() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cond.Wait()
+	}
+	return nil
+}
+
+// process retrieves the current config for target, applies each controller's contribution and writes the result.
+func (wp *WorkerPool) process(target Resource, perControllerWork map[*Controller]interface{}) error {
+	return wp.processGeneration(target, perControllerWork, false)
+}
+
+// processRetained processes an earlier generation of target retained by WithOrderedGenerations.  Its status is written
+// with its own observed generation, although the config has since moved on to a newer generation.
+func (wp *WorkerPool) processRetained(target Resource, perControllerWork map[*Controller]interface{}) error {
+	return wp.processGeneration(target, perControllerWork, true)
+}
+
+func (wp *WorkerPool) processGeneration(target Resource, perControllerWork map[*Controller]interface{}, retained bool) error {
+	start := wp.clock.Now()
+	if wp.skipEmpty(target, perControllerWork) {
+		wp.dropped(target, OutcomeEmpty)
+		wp.outcomes.record(target, OutcomeEmpty, nil)
+		wp.sendResult(target, OutcomeEmpty, nil, start)
+		return nil
+	}
+	if wp.deferForDependencies(target, perControllerWork, retained) {
+		wp.outcomes.record(target, OutcomeDeferred, nil)
+		wp.sendResult(target, OutcomeDeferred, nil, start)
+		return nil
+	}
+	write := wp.writer(target)
+	cfg, err := wp.read(target)
+	if err != nil {
+		wp.logger(target).Warnf("failed to get %s, retrying in %v: %v", target, wp.readRetryDelay, err)
+		wp.outcomes.record(target, OutcomeFailed, err)
+		wp.sendResult(target, OutcomeFailed, err, start)
+		wp.retry(target, perControllerWork, retained, wp.readRetryDelay)
+		wp.reportError(target, err, true)
+		return err
+	}
+	if cfg == nil {
+		wp.dropped(target, OutcomeNotFound)
+		wp.outcomes.record(target, OutcomeNotFound, nil)
+		wp.sendResult(target, OutcomeNotFound, nil, start)
+		return nil
+	}
+	generation := cfg.Generation
+	if retained {
+		gen, err := strconv.ParseInt(strings.TrimSpace(target.Generation), 10, 64)
+		if err != nil {
+			// the generation cannot be written as observed, so check it as if it were the latest
+			wp.logger(target).Warnf("cannot parse retained generation %q of %s: %v", target.Generation, target, err)
+			retained = false
+		} else {
+			generation = gen
+		}
+	}
+	if !retained && !wp.lateGenerationCheck && !wp.matchesGeneration(cfg, target) {
+		wp.mismatched(target, cfg, perControllerWork, start)
+		return nil
+	}
+	if wp.maxRefetches > 0 {
+		wp.lock.Lock()
+		delete(wp.refetches, convert(target))
+		wp.lock.Unlock()
+	}
+	if wp.deferForRequired(target, perControllerWork) {
+		wp.outcomes.record(target, OutcomeDeferred, nil)
+		wp.sendResult(target, OutcomeDeferred, nil, start)
+		return nil
+	}
+	stored := storedIstioStatus(cfg.Status)
+	var x GenerationProvider
+	x, err = GetOGProvider(wp.workingStatus(cfg))
+	if err != nil {
+		wp.logger(target).Warnf("status has no observed generation, overwriting: %s", err)
+	}
+	var controllers []string
+	for _, c := range sortedControllers(perControllerWork) {
+		if perControllerWork[c] == nil && c.NilProgress == NilProgressSkip {
+			wp.logger(target).Debugf("skipping controller %q for %s with nil context", c.Name, target)
+			continue
+		}
+		controllers = append(controllers, c.Identity())
+		var previous []*v1alpha1.IstioCondition
+		if wp.mergeConditions {
+			previous = istioConditions(x)
+		}
+		x = wp.apply(x, c, perControllerWork[c])
+		if wp.mergeConditions {
+			mergeIstioConditions(previous, x, wp.conditionPolicy)
+		}
+	}
+	// set once all controllers have run, so that a controller returning a reset status cannot leave it stale
+	setObservedGeneration(x, generation)
+	x = wp.finalizeForDrain(target, x)
+	if err := wp.validate(target, x); err != nil {
+		wp.rejectInvalid(target, perControllerWork, retained, err, start)
+		return err
+	}
+	if wp.lateGenerationCheck && !retained {
+		current, err := wp.recheckGeneration(target, cfg, perControllerWork, start)
+		if current == nil {
+			return err
+		}
+		if current.Generation != generation {
+			// the status was computed for the generation of target, which the config now has
+			setObservedGeneration(x, current.Generation)
+		}
+		cfg, stored = current, storedIstioStatus(current.Status)
+	}
+	if wp.stageForTransaction(target, cfg, stored, x, controllers) ||
+		wp.stage(target, cfg, stored, x, controllers, perControllerWork, retained) {
+		wp.outcomes.record(target, OutcomeStaged, nil)
+		wp.sendResult(target, OutcomeStaged, nil, start)
+		return nil
+	}
+	throttled, err := wp.writeStatus(write, target, cfg, stored, x, controllers, start, true)
+	if throttled {
+		wp.retry(target, perControllerWork, retained, 0)
+	}
+	return err
+}
+
+// writeStatus writes x, the status computed for target by controllers, to cfg, and records the outcome.  stored is a
+// copy of the status of cfg before the controllers ran, if it is an IstioStatus.  It reports whether the write was
+// throttled, in which case the caller retries it if retry is set.
+func (wp *WorkerPool) writeStatus(write WriteFunc, target Resource, cfg *config.Config, stored *v1alpha1.IstioStatus,
+	x GenerationProvider, controllers []string, start time.Time, retry bool) (bool, error) {
+	changed, err := write(cfg, x)
+	return wp.recordWrite(target, stored, x, controllers, changed, err, start, retry), err
+}
+
+// recordWrite records the result of writing x as the status of target, reporting whether the store throttled the
+// write.
+func (wp *WorkerPool) recordWrite(target Resource, stored *v1alpha1.IstioStatus, x GenerationProvider,
+	controllers []string, changed bool, err error, start time.Time, retry bool) bool {
+	throttled := wp.pauseIfThrottled(target, err)
+	if !throttled {
+		wp.waste.written(target)
+	}
+	recordWrite(wp.dryRun != nil, changed, err)
+	if err == nil {
+		wp.throughput.record()
+	}
+	if err == nil && changed {
+		wp.q.markWritten(target)
+	}
+	if err == nil && generationOnly(stored, x) {
+		recordGenerationOnlyWrite(wp.dryRun != nil)
+	}
+	outcome := writeOutcome(changed, err)
+	wp.outcomes.record(target, outcome, err)
+	wp.sendResult(target, outcome, err, start)
+	if err == nil && wp.lastWritten != nil && x != nil {
+		wp.lastWritten.add(target, x.Unwrap())
+	}
+	if err == nil && wp.provenance != nil {
+		wp.provenance.add(target, controllers, wp.clock.Now())
+	}
+	if wp.failureEvents != nil {
+		wp.failureEvents.record(target, err)
+	}
+	wp.reportError(target, err, throttled && retry)
+	return throttled
+}
+
+// ProcessWithController writes the status of target applying only ctl, with the progress ctl has queued for target if
+// any, to isolate the effect of one controller.  It waits for any worker processing target to finish, and workers do
+// not process target until it returns.  Progress queued for target, including that of ctl, is left queued.
+func (wp *WorkerPool) ProcessWithController(target Resource, ctl *Controller) error {
+	if wp.shards != nil {
+		return wp.shard(target).ProcessWithController(target, ctl)
+	}
+	key := convert(target)
+	wp.lock.Lock()
+	for {
+		if _, working := wp.currentlyWorking[key]; !working && !(wp.singleThreaded && len(wp.currentlyWorking) > 0) {
+			break
+		}
+		wp.cond.Wait()
+	}
+	claim := wp.claim(target, false)
+	wp.lock.Unlock()
+	defer wp.finish(target, claim)
+	wp.exclusivity.begin(target)
+	defer wp.exclusivity.end(target)
+
+	cfg, err := wp.read(target)
+	if err != nil {
+		return fmt.Errorf("cannot process %s: %v", target, err)
+	}
+	if cfg == nil {
+		return fmt.Errorf("cannot process %s: not found", target)
+	}
+	if !wp.matchesGeneration(cfg, target) {
+		return fmt.Errorf("cannot process %s: generation is %s", target, wp.generationOfConfig(cfg))
+	}
+	start := wp.clock.Now()
+	stored := storedIstioStatus(cfg.Status)
+	x, err := GetOGProvider(wp.workingStatus(cfg))
+	if err != nil {
+		wp.logger(target).Warnf("status has no observed generation, overwriting: %s", err)
+	}
+	x = wp.apply(x, ctl, wp.q.progress(target, ctl))
+	setObservedGeneration(x, cfg.Generation)
+	x = wp.finalizeForDrain(target, x)
+	_, err = wp.writeStatus(wp.writer(target), target, cfg, stored, x, []string{ctl.Identity()}, start, false)
+	return err
+}
+
+// GenerationMatchFunc reports whether status computed for target may be written to cfg, the latest retrieved version
+// of the target.
+type GenerationMatchFunc func(cfg *config.Config, target Resource) bool
+
+// WithGenerationMatch overrides how the generation of a retrieved config is compared to the generation of a target.
+// The default, NumericGenerationMatch, requires them to be equal; GenerationRangeMatch also accepts newer configs.
+func WithGenerationMatch(match GenerationMatchFunc) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.generationMatch = match
+	}
+}
+
+// NumericGenerationMatch is the default GenerationMatchFunc.  It parses the generation of target as a base 10 integer,
+// so that formatting differences such as leading zeros or surrounding whitespace do not cause a mismatch.
+func NumericGenerationMatch(cfg *config.Config, target Resource) bool {
+	gen, ok := numericGeneration(target)
+	return ok && gen == cfg.Generation
+}
+
+// GenerationRangeMatch returns a GenerationMatchFunc which, like NumericGenerationMatch, accepts a config of the
+// generation of the target, and also one up to maxAhead generations newer, for resources whose status stays valid
+// while their spec moves on a little.  This avoids dropping status when the generation advances during processing.
+// Such status is written with the generation of the retrieved config as its observed generation.  A negative maxAhead
+// accepts any newer generation.  Older configs never match.
+func GenerationRangeMatch(maxAhead int64) GenerationMatchFunc {
+	return func(cfg *config.Config, target Resource) bool {
+		gen, ok := numericGeneration(target)
+		return ok && cfg.Generation >= gen && (maxAhead < 0 || cfg.Generation-gen <= maxAhead)
+	}
+}
+
+// numericGeneration parses the generation of target as a base 10 integer.
+func numericGeneration(target Resource) (int64, bool) {
+	gen, err := strconv.ParseInt(strings.TrimSpace(target.Generation), 10, 64)
+	if err != nil {
+		scope.Debugf("cannot parse generation %q of %s: %v", target.Generation, target, err)
+		return 0, false
+	}
+	return gen, true
+}
+
+// GenerationOfFunc returns the generation of a retrieved config, which is compared with Resource.Generation.
+type GenerationOfFunc func(cfg *config.Config) string
+
+// WithGenerationOf gates writes on the generation returned by generationOf, such as a hash of the spec, rather than
+// on metadata.generation.  Status computed for a target is written only if generationOf returns exactly the generation
+// of the target.  As such generations have no order, superseded targets are not refetched.  It replaces the
+// GenerationMatchFunc, so it should not be combined with WithGenerationMatch.
+func WithGenerationOf(generationOf GenerationOfFunc) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.generationOf = generationOf
+		wp.generationMatch = func(cfg *config.Config, target Resource) bool {
+			return generationOf(cfg) == target.Generation
+		}
+	}
+}
+
+// generationOfConfig returns the generation of cfg, as compared with the generation of targets.
+func (wp *WorkerPool) generationOfConfig(cfg *config.Config) string {
+	if wp.generationOf != nil {
+		return wp.generationOf(cfg)
+	}
+	return strconv.FormatInt(cfg.Generation, 10)
+}
+
+// matchesGeneration checks that the status computed for target is for the generation of cfg.
+func (wp *WorkerPool) matchesGeneration(cfg *config.Config, target Resource) bool {
+	return wp.generationMatch(cfg, target)
+}
+
+// mismatched records that the generation of cfg does not match target, and requeues or drops its work.
+func (wp *WorkerPool) mismatched(target Resource, cfg *config.Config, perControllerWork map[*Controller]interface{},
+	start time.Time) {
+	wp.outcomes.record(target, OutcomeGenerationMismatch, nil)
+	wp.sendResult(target, OutcomeGenerationMismatch, nil, start)
+	if !wp.refetch(target, cfg, perControllerWork) {
+		wp.dropped(target, OutcomeGenerationMismatch)
+	}
+}
+
+// refetch requeues work for a target which has been superseded by the newer generation of cfg, so that status is
+// eventually written for the current generation.  It reports whether the work was requeued.
+func (wp *WorkerPool) refetch(target Resource, cfg *config.Config, perControllerWork map[*Controller]interface{}) bool {
+	if wp.maxRefetches == 0 || wp.generationOf != nil {
+		return false
+	}
+	gen, err := strconv.ParseInt(strings.TrimSpace(target.Generation), 10, 64)
+	if err != nil || cfg.Generation <= gen {
+		// only move forward, the retrieved config may be stale
+		return false
+	}
+	key := convert(target)
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	if wp.refetches[key] >= wp.maxRefetches {
+		scope.Debugf("dropping status update for %s after %d generation refetches", target, wp.refetches[key])
+		delete(wp.refetches, key)
+		return false
+	}
+	wp.refetches[key]++
+	target.Generation = strconv.FormatInt(cfg.Generation, 10)
+	wp.q.requeue(target, perControllerWork, 0)
+	return true
+}
+
+// setObservedGeneration records that the computed status x reflects generation, if there is a status.
+func setObservedGeneration(x GenerationProvider, generation int64) {
+	if x != nil {
+		x.SetObservedGeneration(generation)
+	}
+}
+
+// sortedControllers returns the controllers with work for a target in the order their UpdateFuncs should be applied.
+func sortedControllers(perControllerWork map[*Controller]interface{}) []*Controller {
+	controllers := make([]*Controller, 0, len(perControllerWork))
+	for c := range perControllerWork {
+		controllers = append(controllers, c)
+	}
+	sort.SliceStable(controllers, func(i, j int) bool {
+		if controllers[i].Priority != controllers[j].Priority {
+			return controllers[i].Priority < controllers[j].Priority
+		}
+		return controllers[i].Name < controllers[j].Name
+	})
+	return controllers
+}
+
+type GenerationProvider interface {
+	SetObservedGeneration(int64)
+	Unwrap() interface{}
+}
+
+type IstioGenerationProvider struct {
+	*v1alpha1.IstioStatus
+}
+
+func (i *IstioGenerationProvider) SetObservedGeneration(in int64) {
+	if i.IstioStatus == nil {
+		return
+	}
+	i.ObservedGeneration = in
+}
+
+func (i *IstioGenerationProvider) Unwrap() interface{} {
+	return i.IstioStatus
+}
+
+// recheckGeneration retrieves the config of target again, once its status has been computed from cfg, and returns it
+// if its generation matches target.  Otherwise it handles the missing config or mismatch as processGeneration does and
+// returns nil, along with any error retrieving the config.
+func (wp *WorkerPool) recheckGeneration(target Resource, cfg *config.Config,
+	perControllerWork map[*Controller]interface{}, start time.Time) (*config.Config, error) {
+	current, err := wp.read(target)
+	if err != nil {
+		wp.logger(target).Warnf("failed to get %s before writing, retrying in %v: %v", target, wp.readRetryDelay, err)
+		wp.outcomes.record(target, OutcomeFailed, err)
+		wp.sendResult(target, OutcomeFailed, err, start)
+		wp.retry(target, perControllerWork, false, wp.readRetryDelay)
+		wp.reportError(target, err, true)
+		return nil, err
+	}
+	if current == nil {
+		wp.dropped(target, OutcomeNotFound)
+		wp.outcomes.record(target, OutcomeNotFound, nil)
+		wp.sendResult(target, OutcomeNotFound, nil, start)
+		return nil, nil
+	}
+	if current.Generation != cfg.Generation {
+		wp.logger(target).Debugf("generation of %s moved from %d to %d while its status was computed", target, cfg.Generation,
+			current.Generation)
+	}
+	if !wp.matchesGeneration(current, target) {
+		wp.mismatched(target, current, perControllerWork, start)
+		return nil, nil
+	}
+	return current, nil
 }
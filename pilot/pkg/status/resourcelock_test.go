@@ -16,14 +16,24 @@ package status
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	"go.opencensus.io/stats/view"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	clocktesting "k8s.io/utils/clock/testing"
 
 	"istio.io/api/meta/v1alpha1"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collections"
 )
 
 func TestResourceLock_Lock(t *testing.T) {
@@ -47,6 +57,7 @@ func TestResourceLock_Lock(t *testing.T) {
 		Generation: "12",
 	}
 	var runCount int32
+	generation := int64(11)
 	x := make(chan struct{})
 	y := make(chan struct{})
 	mgr := NewManager(nil)
@@ -58,10 +69,11 @@ func TestResourceLock_Lock(t *testing.T) {
 	}
 	c1 := mgr.CreateIstioStatusController(fakefunc)
 	c2 := mgr.CreateIstioStatusController(fakefunc)
-	workers := NewWorkerPool(func(_ *config.Config, _ interface{}) {
+	workers := NewWorkerPool(func(_ *config.Config, _ interface{}) (bool, error) {
+		return true, nil
 	}, func(resource Resource) *config.Config {
 		return &config.Config{
-			Meta: config.Meta{Generation: 11},
+			Meta: config.Meta{Generation: atomic.LoadInt64(&generation)},
 		}
 	}, 10)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -73,6 +85,8 @@ func TestResourceLock_Lock(t *testing.T) {
 	<-y
 	<-x
 	workers.Push(r1, c1, nil)
+	// the queued task is processed for the latest pushed generation
+	atomic.StoreInt64(&generation, 12)
 	workers.Push(r1a, c1, nil)
 	<-y
 	<-x
@@ -86,3 +100,1805 @@ func TestResourceLock_Lock(t *testing.T) {
 	g.Expect(result).To(Equal(int32(3)))
 	cancel()
 }
+
+func TestWorkerPoolAppliesControllersByPriority(t *testing.T) {
+	g := NewGomegaWithT(t)
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	var order []string
+	newController := func(name string, priority int) *Controller {
+		return &Controller{
+			Name:     name,
+			Priority: priority,
+			fn: func(status interface{}, context interface{}) GenerationProvider {
+				order = append(order, name)
+				return &IstioGenerationProvider{}
+			},
+		}
+	}
+	// registered deliberately out of priority order
+	refine := newController("refine", 10)
+	baseB := newController("base-b", 0)
+	baseA := newController("base-a", 0)
+	written := make(chan struct{})
+	workers := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		close(written)
+		return true, nil
+	}, func(Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workers.Run(ctx)
+	// queue all contributions before starting a worker, so they are applied in a single write
+	workers.q.Push(target, refine, nil)
+	workers.q.Push(target, baseB, nil)
+	workers.q.Push(target, baseA, nil)
+	workers.maybeAddWorker()
+	<-written
+	g.Expect(order).To(Equal([]string{"base-a", "base-b", "refine"}))
+}
+
+func TestWorkerPoolControllerIdentity(t *testing.T) {
+	g := NewGomegaWithT(t)
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	var applied []string
+	newController := func(instance string) *Controller {
+		return &Controller{
+			Name: "ctl",
+			fn: func(status interface{}, context interface{}) GenerationProvider {
+				applied = append(applied, instance)
+				return &IstioGenerationProvider{}
+			},
+		}
+	}
+	written := make(chan struct{})
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		close(written)
+		return true, nil
+	}, func(Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Generation: 1}}
+	}, 1, WithControllerIdentity())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	wp.Hold(target)
+	wp.Push(target, newController("old"), nil)
+	// the controller is recreated, and contributes again
+	wp.Push(target, newController("new"), nil)
+	g.Expect(wp.PendingProgress(target)).To(HaveLen(1))
+	wp.Release(target)
+	<-written
+	g.Expect(applied).To(Equal([]string{"new"}))
+}
+
+func TestNumericGenerationMatch(t *testing.T) {
+	cfg := &config.Config{Meta: config.Meta{Generation: 7}}
+	cases := []struct {
+		generation string
+		match      bool
+	}{
+		{"7", true},
+		{"007", true},
+		{" 7\n", true},
+		{"+7", true},
+		{"8", false},
+		{"70", false},
+		{"", false},
+		{"seven", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.generation, func(t *testing.T) {
+			if got := NumericGenerationMatch(cfg, Resource{Generation: tt.generation}); got != tt.match {
+				t.Fatalf("NumericGenerationMatch(%q) = %v, want %v", tt.generation, got, tt.match)
+			}
+		})
+	}
+}
+
+func TestWorkerPoolGenerationMatchOverride(t *testing.T) {
+	g := NewGomegaWithT(t)
+	cfg := &config.Config{Meta: config.Meta{Generation: 7}}
+	wp := NewWorkerPool(nil, nil, 1)
+	g.Expect(wp.matchesGeneration(cfg, Resource{Generation: "007"})).To(BeTrue())
+
+	var matched []string
+	wp = NewWorkerPool(nil, nil, 1, WithGenerationMatch(func(cfg *config.Config, target Resource) bool {
+		matched = append(matched, target.Generation)
+		return target.Generation == "latest"
+	}))
+	g.Expect(wp.matchesGeneration(cfg, Resource{Generation: "7"})).To(BeFalse())
+	g.Expect(wp.matchesGeneration(cfg, Resource{Generation: "latest"})).To(BeTrue())
+	g.Expect(matched).To(Equal([]string{"7", "latest"}))
+}
+
+func TestGenerationRangeMatch(t *testing.T) {
+	cases := []struct {
+		name       string
+		maxAhead   int64
+		generation int64
+		match      bool
+	}{
+		{"equal", 0, 7, true},
+		{"ahead with no range", 0, 8, false},
+		{"within range", 2, 9, true},
+		{"beyond range", 2, 10, false},
+		{"behind", 2, 6, false},
+		{"unbounded", -1, 100, true},
+		{"unbounded behind", -1, 6, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Meta: config.Meta{Generation: tt.generation}}
+			if got := GenerationRangeMatch(tt.maxAhead)(cfg, Resource{Generation: "7"}); got != tt.match {
+				t.Fatalf("GenerationRangeMatch(%d) for generation %d = %v, want %v", tt.maxAhead, tt.generation, got, tt.match)
+			}
+		})
+	}
+	if GenerationRangeMatch(-1)(&config.Config{}, Resource{Generation: "seven"}) {
+		t.Fatalf("GenerationRangeMatch matched an unparseable generation")
+	}
+}
+
+func TestWorkerPoolGenerationRangeMatch(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan int64, 2)
+	wp := NewWorkerPool(func(cfg *config.Config, status interface{}) (bool, error) {
+		written <- status.(*IstioGenerationProvider).ObservedGeneration
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 2}}
+	}, 1, WithGenerationMatch(GenerationRangeMatch(1)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{IstioStatus: &v1alpha1.IstioStatus{}}
+	}}
+
+	// status computed for the previous generation is still written, observing the current one
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	g.Eventually(written).Should(Receive(Equal(int64(2))))
+
+	// the generation of the config must not be older than the target
+	ahead := outcomeTarget("b")
+	ahead.Generation = "3"
+	wp.Push(ahead, ctl, nil)
+	g.Eventually(func() OutcomeType {
+		outcome, _ := wp.LastOutcome(ahead)
+		return outcome.Type
+	}).Should(Equal(OutcomeGenerationMismatch))
+	g.Expect(written).NotTo(Receive())
+}
+
+func TestWorkerPoolGenerationOf(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 2)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{
+			Name:        r.Name,
+			Generation:  1,
+			Annotations: map[string]string{"spec-hash": "abc"},
+		}}
+	}, 1, WithGenerationOf(func(cfg *config.Config) string {
+		return cfg.Annotations["spec-hash"]
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	// metadata.generation no longer gates the write
+	stale := outcomeTarget("stale")
+	wp.Push(stale, ctl, nil)
+	g.Eventually(func() OutcomeType {
+		outcome, _ := wp.LastOutcome(stale)
+		return outcome.Type
+	}).Should(Equal(OutcomeGenerationMismatch))
+	current := outcomeTarget("current")
+	current.Generation = "abc"
+	wp.Push(current, ctl, nil)
+	g.Eventually(written).Should(Receive(Equal("current")))
+	g.Expect(written).NotTo(Receive())
+}
+
+func TestWorkerPoolLateGenerationCheck(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan int64, 2)
+	var generation int64 = 1
+	wp := NewWorkerPool(func(cfg *config.Config, status interface{}) (bool, error) {
+		written <- status.(*IstioGenerationProvider).ObservedGeneration
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: atomic.LoadInt64(&generation)}}
+	}, 1, WithLateGenerationCheck())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	// the generation moves on while the controller is applied
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		atomic.AddInt64(&generation, 1)
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{}}
+	}}
+	outcome := func(r Resource) func() OutcomeType {
+		return func() OutcomeType {
+			o, _ := wp.LastOutcome(r)
+			return o.Type
+		}
+	}
+
+	// the config reaches the generation of the target during processing, so the status is written for it
+	caughtUp := outcomeTarget("caught-up")
+	caughtUp.Generation = "2"
+	wp.Push(caughtUp, ctl, nil)
+	g.Eventually(written).Should(Receive(Equal(int64(2))))
+	g.Eventually(outcome(caughtUp)).Should(Equal(OutcomeWritten))
+
+	// the config moves past the generation of the target during processing, so nothing is written
+	overtaken := outcomeTarget("overtaken")
+	overtaken.Generation = "2"
+	wp.Push(overtaken, ctl, nil)
+	g.Eventually(outcome(overtaken)).Should(Equal(OutcomeGenerationMismatch))
+	g.Expect(written).NotTo(Receive())
+}
+
+func TestWorkQueueDebounce(t *testing.T) {
+	g := NewGomegaWithT(t)
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	wq := WorkQueue{
+		cache:           make(map[lockResource]cacheEntry),
+		debounce:        10 * time.Second,
+		maxDebounceWait: time.Minute,
+		clock:           fakeClock,
+	}
+	c := &Controller{}
+	start := fakeClock.Now()
+
+	wq.Push(target, c, 1)
+	fakeClock.Step(5 * time.Second)
+	// each push resets the quiet period
+	wq.Push(target, c, 2)
+	fakeClock.Step(6 * time.Second)
+	popped, _, _ := wq.Pop(nil)
+	g.Expect(popped).To(Equal(Resource{}))
+	g.Expect(wq.NextEligible(nil)).To(Equal(start.Add(15 * time.Second)))
+	fakeClock.Step(4 * time.Second)
+	popped, progress, _ := wq.Pop(nil)
+	g.Expect(popped).To(Equal(target))
+	g.Expect(progress[c]).To(Equal(2))
+
+	// a target which is pushed constantly is still processed once maxWait has elapsed
+	wq.Delete(target)
+	start = fakeClock.Now()
+	for i := 0; i < 14; i++ {
+		wq.Push(target, c, i)
+		fakeClock.Step(4 * time.Second)
+		popped, _, _ := wq.Pop(nil)
+		g.Expect(popped).To(Equal(Resource{}))
+	}
+	g.Expect(wq.NextEligible(nil)).To(Equal(start.Add(time.Minute)))
+	fakeClock.Step(4 * time.Second)
+	wq.Push(target, c, 14)
+	popped, progress, _ = wq.Pop(nil)
+	g.Expect(popped).To(Equal(target))
+	g.Expect(progress[c]).To(Equal(14))
+}
+
+func TestWorkerPoolDebounce(t *testing.T) {
+	g := NewGomegaWithT(t)
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	written := make(chan interface{}, 10)
+	workers := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+		written <- status
+		return true, nil
+	}, func(Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Generation: 1}}
+	}, 1, WithDebounce(10*time.Second, time.Minute), func(wp *WorkerPool) {
+		wp.clock = fakeClock
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workers.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{Conditions: []*v1alpha1.IstioCondition{{Reason: strconv.Itoa(context.(int))}}}}
+	}}
+	workers.Push(target, c, 1)
+	fakeClock.Step(5 * time.Second)
+	workers.Push(target, c, 2)
+	fakeClock.Step(6 * time.Second)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	// the worker is parked waiting for the target to settle
+	g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+	fakeClock.Step(4 * time.Second)
+	var status interface{}
+	g.Eventually(written).Should(Receive(&status))
+	g.Expect(status.(*IstioGenerationProvider).Conditions[0].Reason).To(Equal("2"))
+}
+
+func TestWorkerPoolWorkerHooks(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var lock sync.Mutex
+	var started, stopped, maxCount, lastCount uint
+	release := make(chan struct{})
+	workers := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		<-release
+		return true, nil
+	}, func(Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Generation: 1}}
+	}, 3, WithWorkerHooks(func(count uint) {
+		lock.Lock()
+		defer lock.Unlock()
+		started++
+		lastCount = count
+		if count > maxCount {
+			maxCount = count
+		}
+	}, func(count uint) {
+		lock.Lock()
+		defer lock.Unlock()
+		stopped++
+		lastCount = count
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workers.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	for i := 0; i < 5; i++ {
+		workers.Push(Resource{
+			GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+			Namespace:            "r1",
+			Name:                 strconv.Itoa(i),
+			Generation:           "1",
+		}, c, nil)
+	}
+	close(release)
+	g.Eventually(func() uint {
+		lock.Lock()
+		defer lock.Unlock()
+		return started - stopped
+	}).Should(Equal(uint(0)))
+	lock.Lock()
+	defer lock.Unlock()
+	g.Expect(started).To(BeNumerically(">=", 1))
+	g.Expect(maxCount).To(Equal(uint(3)))
+	g.Expect(lastCount).To(Equal(uint(0)))
+}
+
+func getWriteCount(t *testing.T, mode, result string) float64 {
+	rows, err := view.RetrieveData(statusWrites.Name())
+	if err != nil {
+		t.Fatalf("failed to get value for %s: %v", statusWrites.Name(), err)
+	}
+	for _, row := range rows {
+		matched := 0
+		for _, tag := range row.Tags {
+			if (tag.Key.Name() == "result" && tag.Value == result) || (tag.Key.Name() == "mode" && tag.Value == mode) {
+				matched++
+			}
+		}
+		if matched == 2 {
+			return row.Data.(*view.SumData).Value
+		}
+	}
+	return 0
+}
+
+func TestWorkerPoolRecordsChangedWrites(t *testing.T) {
+	g := NewGomegaWithT(t)
+	changedBefore := getWriteCount(t, modeLive, writeChanged)
+	unchangedBefore := getWriteCount(t, modeLive, writeUnchanged)
+	written := make(chan struct{})
+	workers := NewWorkerPool(func(cfg *config.Config, status interface{}) (bool, error) {
+		// the store skips updates which would not modify the object
+		defer func() { written <- struct{}{} }()
+		return cfg.Name != "noop", nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workers.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	for _, name := range []string{"noop", "update", "noop"} {
+		workers.Push(Resource{
+			GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+			Namespace:            "r1",
+			Name:                 name,
+			Generation:           "1",
+		}, c, nil)
+		<-written
+	}
+	g.Eventually(func() float64 {
+		return getWriteCount(t, modeLive, writeUnchanged) - unchangedBefore
+	}).Should(Equal(2.0))
+	g.Eventually(func() float64 {
+		return getWriteCount(t, modeLive, writeChanged) - changedBefore
+	}).Should(Equal(1.0))
+}
+
+func TestWriteFuncFromVoid(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var got interface{}
+	write := WriteFuncFromVoid(func(_ *config.Config, status interface{}) {
+		got = status
+	})
+	changed, err := write(&config.Config{}, "status")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeTrue())
+	g.Expect(got).To(Equal("status"))
+}
+
+func TestWorkQueueSortedOrder(t *testing.T) {
+	g := NewGomegaWithT(t)
+	key := func(group, ns, name string) Resource {
+		return Resource{
+			GroupVersionResource: schema.GroupVersionResource{Group: group, Version: "v1", Resource: "r"},
+			Namespace:            ns,
+			Name:                 name,
+			Generation:           "1",
+		}
+	}
+	want := []Resource{
+		key("a", "ns1", "x"),
+		key("a", "ns1", "y"),
+		key("a", "ns2", "a"),
+		key("b", "ns1", "a"),
+		key("b", "ns2", "z"),
+	}
+	wq := WorkQueue{cache: make(map[lockResource]cacheEntry), order: SortedOrder}
+	c := &Controller{}
+	for _, i := range []int{3, 1, 4, 0, 2} {
+		wq.Push(want[i], c, nil)
+	}
+	// a push for a queued key does not move it
+	wq.Push(want[4], c, nil)
+	var got []Resource
+	for wq.Length() > 0 {
+		r, _, _ := wq.Pop(nil)
+		wq.Delete(r)
+		got = append(got, r)
+	}
+	g.Expect(got).To(Equal(want))
+
+	// excluded keys are skipped, returning the smallest remaining key
+	for _, r := range want {
+		wq.Push(r, c, nil)
+	}
+	r, _, _ := wq.Pop(map[lockResource]struct{}{convert(want[0]): {}, convert(want[1]): {}})
+	g.Expect(r).To(Equal(want[2]))
+}
+
+func TestWorkerPoolControllerHandles(t *testing.T) {
+	vs := schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1alpha3", Resource: "virtualservices"}
+	gw := schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1alpha3", Resource: "gateways"}
+	cases := []struct {
+		name    string
+		handles []schema.GroupVersionResource
+		target  schema.GroupVersionResource
+		queued  bool
+	}{
+		{"all", nil, vs, true},
+		{"handled", []schema.GroupVersionResource{gw, vs}, vs, true},
+		{"not handled", []schema.GroupVersionResource{gw}, vs, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			// without workers, accepted targets stay queued
+			wp := NewWorkerPool(nil, nil, 0)
+			wp.Push(Resource{GroupVersionResource: tt.target, Namespace: "ns", Name: "name", Generation: "1"},
+				&Controller{Name: "ctl", Handles: tt.handles}, nil)
+			g.Expect(wp.q.Length() == 1).To(Equal(tt.queued))
+		})
+	}
+}
+
+func TestWorkerPoolNilProgress(t *testing.T) {
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	// the controller assumes it is always given a context
+	fn := func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{ObservedGeneration: int64(context.(int))}}
+	}
+	newPool := func(written chan interface{}) *WorkerPool {
+		return NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+			written <- status
+			return true, nil
+		}, func(Resource) *config.Config {
+			return &config.Config{Meta: config.Meta{Generation: 1}, Status: &v1alpha1.IstioStatus{}}
+		}, 1)
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		g := NewGomegaWithT(t)
+		written := make(chan interface{}, 1)
+		wp := newPool(written)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		wp.Run(ctx)
+		wp.Push(target, &Controller{NilProgress: NilProgressSkip, fn: fn}, nil)
+		var status interface{}
+		g.Eventually(written).Should(Receive(&status))
+		// the status is written as retrieved, with the observed generation updated
+		g.Expect(status.(*IstioGenerationProvider).ObservedGeneration).To(Equal(int64(1)))
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		g := NewGomegaWithT(t)
+		wp := NewWorkerPool(nil, nil, 0)
+		wp.Push(target, &Controller{NilProgress: NilProgressReject, fn: fn}, nil)
+		g.Expect(wp.q.Length()).To(Equal(0))
+		wp.Push(target, &Controller{NilProgress: NilProgressReject, fn: fn}, 1)
+		g.Expect(wp.q.Length()).To(Equal(1))
+	})
+}
+
+func TestWorkerPoolProfilerLabels(t *testing.T) {
+	g := NewGomegaWithT(t)
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1alpha3", Resource: "gateways"},
+		Namespace:            "istio-system",
+		Name:                 "ingress",
+		Generation:           "1",
+	}
+	processing := make(chan struct{})
+	release := make(chan struct{})
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		return true, nil
+	}, func(Resource) *config.Config {
+		close(processing)
+		<-release
+		return nil
+	}, 1, WithProfilerLabels())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	wp.Push(target, &Controller{}, nil)
+	<-processing
+	var profile strings.Builder
+	g.Expect(pprof.Lookup("goroutine").WriteTo(&profile, 1)).To(Succeed())
+	close(release)
+	g.Expect(profile.String()).To(ContainSubstring(
+		`labels: {"gvr":"networking.istio.io/v1alpha3, Resource=gateways", "name":"ingress", "namespace":"istio-system"}`))
+}
+
+func TestWorkerPoolGenerationRefetch(t *testing.T) {
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{}}
+	}}
+
+	t.Run("bumped between push and pop", func(t *testing.T) {
+		g := NewGomegaWithT(t)
+		written := make(chan int64, 1)
+		wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+			written <- cfg.Generation
+			return true, nil
+		}, func(Resource) *config.Config {
+			return &config.Config{Meta: config.Meta{Generation: 2}}
+		}, 1, WithGenerationRefetch(3))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		wp.Run(ctx)
+		wp.Push(target, c, nil)
+		g.Eventually(written).Should(Receive(Equal(int64(2))))
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		g := NewGomegaWithT(t)
+		var gets int64
+		wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+			t.Fatalf("unexpected write for generation %d", cfg.Generation)
+			return true, nil
+		}, func(Resource) *config.Config {
+			// the config changes again every time it is retrieved
+			return &config.Config{Meta: config.Meta{Generation: atomic.AddInt64(&gets, 1) + 1}}
+		}, 1, WithGenerationRefetch(3))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		wp.Run(ctx)
+		wp.Push(target, c, nil)
+		g.Eventually(func() int { return wp.Stats().Queued + wp.Stats().InFlight }).Should(Equal(0))
+		g.Consistently(func() int64 { return atomic.LoadInt64(&gets) }, 50*time.Millisecond).Should(Equal(int64(4)))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		g := NewGomegaWithT(t)
+		var gets int64
+		wp := NewWorkerPool(nil, func(Resource) *config.Config {
+			atomic.AddInt64(&gets, 1)
+			return &config.Config{Meta: config.Meta{Generation: 2}}
+		}, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		wp.Run(ctx)
+		wp.Push(target, c, nil)
+		g.Eventually(func() int { return wp.Stats().Queued + wp.Stats().InFlight }).Should(Equal(0))
+		g.Consistently(func() int64 { return atomic.LoadInt64(&gets) }, 50*time.Millisecond).Should(Equal(int64(1)))
+	})
+}
+
+func TestWorkQueueProgressMapPooling(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0, WithProgressMapPooling())
+	c := &Controller{}
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	wp.q.Push(target, c, "progress")
+	_, progress, _ := wp.q.Pop(nil)
+	wp.q.Delete(target)
+	g.Expect(progress).To(Equal(map[*Controller]interface{}{c: "progress"}))
+	wp.q.releaseProgressMap(progress)
+	// released maps do not retain controllers or progress
+	g.Expect(progress).To(BeEmpty())
+
+	// requeued work is copied, so the popped map can be released
+	wp.q.Push(target, c, "progress")
+	_, progress, _ = wp.q.Pop(nil)
+	wp.q.Delete(target)
+	wp.q.requeue(target, progress, 0)
+	wp.q.releaseProgressMap(progress)
+	_, progress, _ = wp.q.Pop(nil)
+	g.Expect(progress).To(Equal(map[*Controller]interface{}{c: "progress"}))
+}
+
+func benchmarkWorkQueueChurn(b *testing.B, opts ...WorkerPoolOption) {
+	wp := NewWorkerPool(nil, nil, 0, opts...)
+	c := &Controller{}
+	targets := make([]Resource, 100)
+	for i := range targets {
+		targets[i] = Resource{
+			GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+			Namespace:            "r1",
+			Name:                 strconv.Itoa(i),
+			Generation:           "1",
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, target := range targets {
+			wp.q.Push(target, c, nil)
+		}
+		for range targets {
+			target, progress, _ := wp.q.Pop(nil)
+			wp.q.Delete(target)
+			wp.q.releaseProgressMap(progress)
+		}
+	}
+}
+
+func BenchmarkWorkQueueChurn(b *testing.B) {
+	b.Run("unpooled", func(b *testing.B) {
+		benchmarkWorkQueueChurn(b)
+	})
+	b.Run("pooled", func(b *testing.B) {
+		benchmarkWorkQueueChurn(b, WithProgressMapPooling())
+	})
+}
+
+func TestWorkerPoolPendingProgress(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0)
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	g.Expect(wp.PendingProgress(target)).To(BeNil())
+
+	analysis := &Controller{Name: "analysis"}
+	distribution := &Controller{Name: "distribution"}
+	wp.Push(target, analysis, "stale")
+	wp.Push(target, distribution, 3)
+	wp.Push(target, analysis, "latest")
+	pending := wp.PendingProgress(target)
+	g.Expect(pending).To(Equal(map[string]interface{}{"analysis": "latest", "distribution": 3}))
+
+	// the result is a copy
+	pending["analysis"] = "modified"
+	delete(pending, "distribution")
+	g.Expect(wp.PendingProgress(target)).To(Equal(map[string]interface{}{"analysis": "latest", "distribution": 3}))
+}
+
+func TestWorkerPoolDeleteNamespace(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0)
+	target := func(ns, name string) Resource {
+		return Resource{
+			GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+			Namespace:            ns,
+			Name:                 name,
+			Generation:           "1",
+		}
+	}
+	before := namespaceDeletedTaskCount(t)
+	for _, r := range []Resource{target("doomed", "a"), target("kept", "a"), target("doomed", "b"), target("kept", "b")} {
+		wp.Push(r, &Controller{}, nil)
+	}
+
+	g.Expect(wp.DeleteNamespace("doomed")).To(Equal(2))
+	g.Expect(wp.q.Length()).To(Equal(2))
+	g.Expect(wp.PendingProgress(target("doomed", "a"))).To(BeNil())
+	g.Expect(wp.PendingProgress(target("doomed", "b"))).To(BeNil())
+	g.Expect(wp.PendingProgress(target("kept", "a"))).NotTo(BeNil())
+	g.Expect(wp.PendingProgress(target("kept", "b"))).NotTo(BeNil())
+	outcome, _ := wp.LastOutcome(target("doomed", "a"))
+	g.Expect(outcome.Type).To(Equal(OutcomeDeleted))
+	g.Expect(namespaceDeletedTaskCount(t) - before).To(Equal(2.0))
+
+	g.Expect(wp.DeleteNamespace("empty")).To(Equal(0))
+	g.Expect(wp.q.Length()).To(Equal(2))
+}
+
+func namespaceDeletedTaskCount(t *testing.T) float64 {
+	rows, err := view.RetrieveData(namespaceDeletedTasks.Name())
+	if err != nil {
+		t.Fatalf("failed to get value for %s: %v", namespaceDeletedTasks.Name(), err)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return rows[0].Data.(*view.SumData).Value
+}
+
+func TestWorkQueuePushRefreshesTarget(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wq := WorkQueue{
+		tasks: make([]lockResource, 0),
+		cache: make(map[lockResource]cacheEntry),
+	}
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	c := &Controller{}
+	wq.Push(target, c, "a")
+	target.Generation = "3"
+	wq.Push(target, c, "b")
+	target.Generation = "2"
+	wq.Push(target, c, "c")
+
+	popped, progress, _ := wq.Pop(map[lockResource]struct{}{})
+	g.Expect(popped.Generation).To(Equal("2"))
+	g.Expect(progress[c]).To(Equal("c"))
+}
+
+func TestWorkerPoolProcessesLatestGeneration(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan interface{}, 1)
+	wp := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+		written <- status
+		return true, nil
+	}, func(Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Generation: 2}}
+	}, 0)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{}}
+	}}
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	// queue both pushes before any worker runs, the second must replace the generation of the first
+	wp.Push(target, c, nil)
+	target.Generation = "2"
+	wp.Push(target, c, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	wp.lock.Lock()
+	wp.maxWorkers = 1
+	wp.lock.Unlock()
+	wp.maybeAddWorker()
+	g.Eventually(written).Should(Receive())
+	outcome, _ := wp.LastOutcome(target)
+	g.Expect(outcome.Type).To(Equal(OutcomeWritten))
+}
+
+func TestWorkQueueMaxQueueLatency(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	wq := WorkQueue{
+		tasks:           make([]lockResource, 0),
+		cache:           make(map[lockResource]cacheEntry),
+		debounce:        time.Second,
+		maxQueueLatency: 3 * time.Second,
+		clock:           fakeClock,
+	}
+	target := func(name string) Resource {
+		return Resource{
+			GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+			Namespace:            "r1",
+			Name:                 name,
+			Generation:           "1",
+		}
+	}
+	c := &Controller{}
+	wq.Push(target("churn"), c, nil)
+	fakeClock.Step(time.Second)
+	// settled before churn reaches its deadline, and queued behind it
+	wq.Push(target("settled"), c, nil)
+	for i := 0; i < 5; i++ {
+		fakeClock.Step(500 * time.Millisecond)
+		wq.Push(target("churn"), c, nil)
+	}
+	// churn has been queued for 3.5s, settled has been quiet for 2.5s
+	popped, _, _ := wq.Pop(map[lockResource]struct{}{})
+	g.Expect(popped.Name).To(Equal("churn"))
+	wq.Delete(popped)
+	popped, _, _ = wq.Pop(map[lockResource]struct{}{})
+	g.Expect(popped.Name).To(Equal("settled"))
+}
+
+func TestWorkerPoolMaxQueueLatency(t *testing.T) {
+	g := NewGomegaWithT(t)
+	target := Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 "r1",
+		Generation:           "1",
+	}
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	written := make(chan interface{}, 10)
+	workers := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+		written <- status
+		return true, nil
+	}, func(Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Generation: 1}}
+	}, 1, WithDebounce(10*time.Second, 0), WithMaxQueueLatency(time.Minute), func(wp *WorkerPool) {
+		wp.clock = fakeClock
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workers.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{}}
+	}}
+	// pushed more often than the quiet period, so the target never settles
+	for elapsed := time.Duration(0); elapsed < time.Minute; elapsed += 5 * time.Second {
+		workers.Push(target, c, nil)
+		g.Expect(written).NotTo(Receive())
+		fakeClock.Step(5 * time.Second)
+	}
+	g.Eventually(written).Should(Receive())
+}
+
+func TestWorkerPoolHold(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, status interface{}) (bool, error) {
+		written <- cfg.Name + "/" + status.(*IstioGenerationProvider).Conditions[0].Reason
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{Conditions: []*v1alpha1.IstioCondition{{Reason: strconv.Itoa(context.(int))}}}}
+	}}
+	target := func(name string) Resource {
+		return Resource{
+			GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+			Namespace:            "r1",
+			Name:                 name,
+			Generation:           "1",
+		}
+	}
+
+	wp.Hold(target("held"))
+	wp.Push(target("held"), c, 1)
+	wp.Push(target("other"), c, 1)
+	g.Eventually(written).Should(Receive(Equal("other/1")))
+	wp.Push(target("held"), c, 2)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(wp.PendingProgress(target("held"))).To(HaveLen(1))
+
+	wp.Release(target("held"))
+	g.Eventually(written).Should(Receive(Equal("held/2")))
+	g.Expect(wp.PendingProgress(target("held"))).To(BeNil())
+}
+
+func TestWorkerPoolMarkSteadyState(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0)
+	startup, steady := pushCount(t, phaseStartup), pushCount(t, phaseSteady)
+
+	wp.Push(outcomeTarget("a"), &Controller{}, nil)
+	wp.Push(outcomeTarget("b"), &Controller{}, nil)
+	g.Expect(pushCount(t, phaseStartup) - startup).To(Equal(2.0))
+	g.Expect(pushCount(t, phaseSteady) - steady).To(Equal(0.0))
+
+	wp.MarkSteadyState()
+	wp.Push(outcomeTarget("a"), &Controller{}, nil)
+	g.Expect(pushCount(t, phaseStartup) - startup).To(Equal(2.0))
+	g.Expect(pushCount(t, phaseSteady) - steady).To(Equal(1.0))
+}
+
+func pushCount(t *testing.T, phase string) float64 {
+	rows, err := view.RetrieveData(statusPushes.Name())
+	if err != nil {
+		t.Fatalf("failed to get value for %s: %v", statusPushes.Name(), err)
+	}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key.Name() == "phase" && tag.Value == phase {
+				return row.Data.(*view.SumData).Value
+			}
+		}
+	}
+	return 0
+}
+
+func TestClusterScopedTargetsDoNotCollide(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0)
+	gvr := schema.GroupVersionResource{Group: "r1", Version: "r1", Resource: "things"}
+	clusterScoped := Resource{GroupVersionResource: gvr, Name: "shared", Generation: "1", ClusterScoped: true}
+	namespaced := Resource{GroupVersionResource: gvr, Name: "shared", Generation: "1"}
+	c := &Controller{Name: "c"}
+
+	wp.Push(clusterScoped, c, "cluster")
+	wp.Push(namespaced, c, "namespaced")
+	g.Expect(wp.q.Length()).To(Equal(2))
+	g.Expect(wp.PendingProgress(clusterScoped)).To(Equal(map[string]interface{}{"c": "cluster"}))
+	g.Expect(wp.PendingProgress(namespaced)).To(Equal(map[string]interface{}{"c": "namespaced"}))
+
+	// deleting the empty namespace leaves cluster-scoped targets alone
+	g.Expect(wp.DeleteNamespace("")).To(Equal(1))
+	g.Expect(wp.PendingProgress(namespaced)).To(BeNil())
+	g.Expect(wp.PendingProgress(clusterScoped)).NotTo(BeNil())
+}
+
+func TestResourceClusterScopedFromSchema(t *testing.T) {
+	g := NewGomegaWithT(t)
+	g.Expect(ResourceFromString("gateway.networking.k8s.io/v1alpha2/gatewayclasses//istio/1").ClusterScoped).To(BeTrue())
+	g.Expect(ResourceFromString("networking.istio.io/v1alpha3/virtualservices/default/reviews/1").ClusterScoped).To(BeFalse())
+}
+
+func TestNewResource(t *testing.T) {
+	g := NewGomegaWithT(t)
+	gvr := collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionResource()
+	cfg := &config.Config{Meta: config.Meta{
+		GroupVersionKind: collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionKind(),
+		Namespace:        "default",
+		Name:             "reviews",
+		Generation:       12,
+	}}
+	r := NewResource(cfg, gvr)
+	g.Expect(r.String()).To(Equal("networking.istio.io/v1alpha3/virtualservices/default/reviews/12"))
+	g.Expect(NumericGenerationMatch(cfg, r)).To(BeTrue())
+	g.Expect(ResourceFromModelConfig(*cfg)).To(Equal(r))
+
+	cfg.Generation = 13
+	g.Expect(NumericGenerationMatch(cfg, r)).To(BeFalse())
+	g.Expect(NewResource(&config.Config{}, gvr).Generation).To(Equal("0"))
+}
+
+func TestWorkerPoolSetWrite(t *testing.T) {
+	g := NewGomegaWithT(t)
+	oldWrites := make(chan string, 10)
+	newWrites := make(chan string, 10)
+	release := make(chan struct{})
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		<-release
+		oldWrites <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	wp.Push(outcomeTarget("in-flight"), c, nil)
+	g.Eventually(func() int { return wp.Stats().InFlight }).Should(Equal(1))
+	wp.Push(outcomeTarget("queued"), c, nil)
+	wp.SetWrite(func(cfg *config.Config, _ interface{}) (bool, error) {
+		newWrites <- cfg.Name
+		return true, nil
+	})
+	// the task already being processed completes with the writer it started with
+	close(release)
+	g.Eventually(oldWrites).Should(Receive(Equal("in-flight")))
+	g.Eventually(newWrites).Should(Receive(Equal("queued")))
+	g.Consistently(oldWrites, 100*time.Millisecond).ShouldNot(Receive())
+}
+
+func TestWorkQueuePopResult(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wq := WorkQueue{
+		tasks: make([]lockResource, 0),
+		cache: make(map[lockResource]cacheEntry),
+	}
+	a, b := outcomeTarget("a"), outcomeTarget("b")
+	c := &Controller{}
+
+	_, _, result := wq.Pop(nil)
+	g.Expect(result).To(Equal(PopEmpty))
+
+	wq.Push(a, c, nil)
+	_, _, result = wq.Pop(map[lockResource]struct{}{convert(a): {}})
+	g.Expect(result).To(Equal(PopAllExcluded))
+	wq.Hold(a)
+	_, _, result = wq.Pop(nil)
+	g.Expect(result).To(Equal(PopAllExcluded))
+	wq.Release(a)
+
+	popped, progress, result := wq.Pop(nil)
+	g.Expect(result).To(Equal(PopGot))
+	g.Expect(popped).To(Equal(a))
+	g.Expect(progress).To(HaveKey(c))
+	wq.Delete(a)
+
+	// tasks deleted while queued are discarded rather than popped
+	wq.Push(a, c, nil)
+	wq.Push(b, c, nil)
+	wq.Delete(a)
+	popped, _, result = wq.Pop(nil)
+	g.Expect(result).To(Equal(PopGot))
+	g.Expect(popped).To(Equal(b))
+	wq.Delete(b)
+	wq.Push(a, c, nil)
+	wq.Delete(a)
+	_, _, result = wq.Pop(nil)
+	g.Expect(result).To(Equal(PopEmpty))
+	g.Expect(wq.Length()).To(Equal(0))
+}
+
+func TestWorkerPoolWaitsForExcludedTarget(t *testing.T) {
+	g := NewGomegaWithT(t)
+	release := make(chan struct{})
+	writes := make(chan struct{}, 10)
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		<-release
+		writes <- struct{}{}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	target := outcomeTarget("a")
+	wp.Push(target, c, nil)
+	g.Eventually(func() int { return wp.Stats().InFlight }).Should(Equal(1))
+	// the second worker cannot process the target while it is in flight, and waits rather than spinning
+	wp.Push(target, c, nil)
+	g.Eventually(func() uint { return wp.Stats().Workers }).Should(Equal(uint(2)))
+	close(release)
+	g.Eventually(writes).Should(Receive())
+	g.Eventually(writes).Should(Receive())
+}
+
+func TestWorkerPoolMaxPendingPerController(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0, WithMaxPendingPerController(3))
+	flood := &Controller{Name: "flood"}
+	wellBehaved := &Controller{Name: "well-behaved"}
+	before := controllerLimitRejectionCount(t)
+
+	for i := 0; i < 10; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), flood, i)
+	}
+	g.Expect(wp.q.Length()).To(Equal(3))
+	g.Expect(controllerLimitRejectionCount(t) - before).To(Equal(7.0))
+	// pushes for targets the controller already has queued are merged rather than rejected
+	wp.Push(outcomeTarget("0"), flood, "latest")
+	g.Expect(wp.PendingProgress(outcomeTarget("0"))).To(Equal(map[string]interface{}{"flood": "latest"}))
+
+	for i := 0; i < 2; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), wellBehaved, i)
+	}
+	wp.Push(outcomeTarget("other"), wellBehaved, nil)
+	g.Expect(wp.q.Length()).To(Equal(4))
+	g.Expect(wp.PendingProgress(outcomeTarget("other"))).NotTo(BeNil())
+
+	// processing a target frees capacity
+	popped, _, _ := wp.q.Pop(nil)
+	g.Expect(popped.Name).To(Equal("0"))
+	wp.Push(outcomeTarget("9"), flood, nil)
+	g.Expect(wp.PendingProgress(outcomeTarget("9"))).NotTo(BeNil())
+	wp.Delete(outcomeTarget("9"))
+	wp.Push(outcomeTarget("8"), flood, nil)
+	g.Expect(wp.PendingProgress(outcomeTarget("8"))).NotTo(BeNil())
+	g.Expect(controllerLimitRejectionCount(t) - before).To(Equal(7.0))
+}
+
+func controllerLimitRejectionCount(t *testing.T) float64 {
+	rows, err := view.RetrieveData(controllerLimitRejections.Name())
+	if err != nil {
+		t.Fatalf("failed to get value for %s: %v", controllerLimitRejections.Name(), err)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return rows[0].Data.(*view.SumData).Value
+}
+
+func TestWorkerPoolProcessWithController(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan []string, 10)
+	wp := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+		var types []string
+		for _, c := range status.(*IstioGenerationProvider).Conditions {
+			types = append(types, c.Type+"="+c.Reason)
+		}
+		written <- types
+		return true, nil
+	}, func(r Resource) *config.Config {
+		if r.Name == "missing" {
+			return nil
+		}
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}, Status: &v1alpha1.IstioStatus{}}
+	}, 0, WithReplay(10))
+	var ran []string
+	controller := func(name string) *Controller {
+		return &Controller{Name: name, fn: func(status interface{}, context interface{}) GenerationProvider {
+			ran = append(ran, name)
+			s := status.(*IstioGenerationProvider)
+			reason, _ := context.(string)
+			s.Conditions = append(s.Conditions, &v1alpha1.IstioCondition{Type: name, Reason: reason})
+			return s
+		}}
+	}
+	a, b := controller("A"), controller("B")
+	target := outcomeTarget("t")
+	wp.Push(target, a, "queued-a")
+	wp.Push(target, b, "queued-b")
+
+	g.Expect(wp.ProcessWithController(target, b)).To(Succeed())
+	g.Expect(ran).To(Equal([]string{"B"}))
+	g.Expect(<-written).To(Equal([]string{"B=queued-b"}))
+	// queued progress is not disturbed
+	g.Expect(wp.PendingProgress(target)).To(Equal(map[string]interface{}{"A": "queued-a", "B": "queued-b"}))
+	// the write is recorded as the workers record theirs, so that it can be replayed
+	g.Expect(wp.ReplayLast(target)).To(Succeed())
+	g.Expect(<-written).To(Equal([]string{"B=queued-b"}))
+
+	// a controller without queued progress is applied with nil context
+	g.Expect(wp.ProcessWithController(outcomeTarget("other"), a)).To(Succeed())
+	g.Expect(<-written).To(Equal([]string{"A="}))
+
+	g.Expect(wp.ProcessWithController(outcomeTarget("missing"), a)).To(MatchError(ContainSubstring("not found")))
+	g.Expect(wp.Stats().InFlight).To(Equal(0))
+}
+
+func TestWorkerPoolShutdown(t *testing.T) {
+	for _, drain := range []bool{false, true} {
+		t.Run(strconv.FormatBool(drain), func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			var lock sync.Mutex
+			var writes int
+			stopped := false
+			release := make(chan struct{})
+			wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+				<-release
+				lock.Lock()
+				defer lock.Unlock()
+				if stopped {
+					t.Errorf("write after shutdown returned")
+				}
+				writes++
+				return true, nil
+			}, func(r Resource) *config.Config {
+				return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+			}, 1)
+			wp.Run(context.Background())
+			c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+				return &IstioGenerationProvider{}
+			}}
+			for i := 0; i < 5; i++ {
+				wp.Push(outcomeTarget(strconv.Itoa(i)), c, nil)
+			}
+			g.Eventually(func() int { return wp.Stats().InFlight }).Should(Equal(1))
+
+			shutdown := make(chan error)
+			go func() {
+				err := wp.Shutdown(context.Background(), drain)
+				lock.Lock()
+				stopped = true
+				lock.Unlock()
+				shutdown <- err
+			}()
+			// shutdown waits for the write in flight
+			g.Consistently(shutdown, 100*time.Millisecond).ShouldNot(Receive())
+			close(release)
+			g.Eventually(shutdown).Should(Receive(BeNil()))
+			lock.Lock()
+			defer lock.Unlock()
+			if drain {
+				g.Expect(writes).To(Equal(5))
+			} else {
+				g.Expect(writes).To(Equal(1))
+			}
+			g.Expect(wp.Stats().Workers).To(Equal(uint(0)))
+		})
+	}
+}
+
+func TestWorkerPoolShutdownTimeout(t *testing.T) {
+	g := NewGomegaWithT(t)
+	// without workers, the queue never drains
+	wp := NewWorkerPool(nil, nil, 0)
+	wp.Push(outcomeTarget("a"), &Controller{}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	g.Expect(wp.Shutdown(ctx, true)).To(Equal(context.DeadlineExceeded))
+}
+
+func TestWorkerPoolSetsObservedGenerationLast(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan *v1alpha1.IstioStatus, 1)
+	wp := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+		written <- status.(*IstioGenerationProvider).IstioStatus
+		return true, nil
+	}, func(Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Generation: 3}, Status: &v1alpha1.IstioStatus{ObservedGeneration: 2}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	// the controller replaces the status with a fresh one, with a zero observed generation
+	reset := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{
+			Conditions: []*v1alpha1.IstioCondition{{Type: "Reconciled"}},
+		}}
+	}}
+	target := outcomeTarget("a")
+	target.Generation = "3"
+	wp.Push(target, reset, nil)
+	var status *v1alpha1.IstioStatus
+	g.Eventually(written).Should(Receive(&status))
+	g.Expect(status.ObservedGeneration).To(Equal(int64(3)))
+	g.Expect(status.Conditions).To(HaveLen(1))
+}
+
+func TestWorkerPoolMaxConcurrentReads(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var current, peak, reads int32
+	release := make(chan struct{})
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		return true, nil
+	}, func(r Resource) *config.Config {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		atomic.AddInt32(&reads, 1)
+		<-release
+		atomic.AddInt32(&current, -1)
+		return &config.Config{Meta: config.Meta{Name: r.Name}}
+	}, 5, WithMaxConcurrentReads(2))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	for i := 0; i < 5; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), ctl, nil)
+	}
+	// all five workers are busy, but only two of them may read at once
+	g.Eventually(func() int { return wp.Stats().InFlight }).Should(Equal(5))
+	g.Consistently(func() int32 { return atomic.LoadInt32(&reads) }, 100*time.Millisecond).Should(Equal(int32(2)))
+	close(release)
+	g.Eventually(func() int32 { return atomic.LoadInt32(&reads) }).Should(Equal(int32(5)))
+	g.Expect(atomic.LoadInt32(&peak)).To(Equal(int32(2)))
+}
+
+func TestWorkerPoolDryRun(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dryRunBefore := getWriteCount(t, modeDryRun, writeChanged)
+	var written int32
+	captured := make(chan interface{}, 1)
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		atomic.AddInt32(&written, 1)
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithDryRun(func(_ *config.Config, status interface{}) {
+		captured <- status
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	wp.Push(outcomeTarget("a"), &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{
+			Conditions: []*v1alpha1.IstioCondition{{Type: "Reconciled"}},
+		}}
+	}}, nil)
+	var status interface{}
+	g.Eventually(captured).Should(Receive(&status))
+	computed := status.(*IstioGenerationProvider).IstioStatus
+	g.Expect(computed.ObservedGeneration).To(Equal(int64(1)))
+	g.Expect(computed.Conditions).To(HaveLen(1))
+	g.Eventually(func() float64 {
+		return getWriteCount(t, modeDryRun, writeChanged) - dryRunBefore
+	}).Should(Equal(1.0))
+	g.Eventually(func() OutcomeType {
+		outcome, _ := wp.LastOutcome(outcomeTarget("a"))
+		return outcome.Type
+	}).Should(Equal(OutcomeWritten))
+
+	// replacing the write function does not end the dry run
+	wp.SetWrite(func(*config.Config, interface{}) (bool, error) {
+		atomic.AddInt32(&written, 1)
+		return true, nil
+	})
+	wp.Push(outcomeTarget("b"), &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}, nil)
+	g.Eventually(captured).Should(Receive())
+	g.Expect(atomic.LoadInt32(&written)).To(Equal(int32(0)))
+}
+
+func getGenerationOnlyWriteCount(t *testing.T) float64 {
+	rows, err := view.RetrieveData(generationOnlyWrites.Name())
+	if err != nil {
+		t.Fatalf("failed to get value for %s: %v", generationOnlyWrites.Name(), err)
+	}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key.Name() == "mode" && tag.Value == modeLive {
+				return row.Data.(*view.SumData).Value
+			}
+		}
+	}
+	return 0
+}
+
+func TestWorkerPoolGenerationOnlyWrites(t *testing.T) {
+	g := NewGomegaWithT(t)
+	before := getGenerationOnlyWriteCount(t)
+	written := make(chan struct{})
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		defer func() { written <- struct{}{} }()
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 2}, Status: &v1alpha1.IstioStatus{
+			Conditions:         []*v1alpha1.IstioCondition{{Type: "Reconciled", Status: "True"}},
+			ObservedGeneration: 1,
+		}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	setReconciled := func(value string) *Controller {
+		return &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+			s := status.(*IstioGenerationProvider)
+			s.Conditions[0].Status = value
+			return s
+		}}
+	}
+	target := outcomeTarget("a")
+	target.Generation = "2"
+
+	// the controller leaves its condition as it was, so only the observed generation changes
+	wp.Push(target, setReconciled("True"), nil)
+	<-written
+	g.Eventually(func() float64 { return getGenerationOnlyWriteCount(t) - before }).Should(Equal(1.0))
+
+	wp.Push(target, setReconciled("False"), nil)
+	<-written
+	g.Consistently(func() float64 { return getGenerationOnlyWriteCount(t) - before }, 100*time.Millisecond).
+		Should(Equal(1.0))
+}
+
+func TestWorkerPoolScaleDownRace(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	// each push races with the only worker exiting after emptying the queue
+	for i := 0; i < 1000; i++ {
+		name := strconv.Itoa(i)
+		wp.Push(outcomeTarget(name), ctl, nil)
+		select {
+		case got := <-written:
+			g.Expect(got).To(Equal(name))
+		case <-time.After(10 * time.Second):
+			t.Fatalf("push %d was stranded", i)
+		}
+	}
+	g.Eventually(func() uint { return wp.Stats().Workers }).Should(BeZero())
+}
+
+func TestWorkerPoolProcessHooks(t *testing.T) {
+	g := NewGomegaWithT(t)
+	type call struct {
+		target Resource
+		err    error
+	}
+	before := make(chan Resource, 2)
+	after := make(chan call, 2)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		if cfg.Name == "bad" {
+			return false, errors.New("conflict")
+		}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithProcessHooks(func(target Resource) {
+		before <- target
+	}, func(target Resource, err error, duration time.Duration) {
+		g.Expect(duration).To(BeNumerically(">=", 0))
+		after <- call{target, err}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	wp.Push(outcomeTarget("good"), ctl, nil)
+	g.Eventually(before).Should(Receive(Equal(outcomeTarget("good"))))
+	g.Eventually(after).Should(Receive(Equal(call{target: outcomeTarget("good")})))
+
+	wp.Push(outcomeTarget("bad"), ctl, nil)
+	g.Eventually(before).Should(Receive(Equal(outcomeTarget("bad"))))
+	var c call
+	g.Eventually(after).Should(Receive(&c))
+	g.Expect(c.target).To(Equal(outcomeTarget("bad")))
+	g.Expect(c.err).To(MatchError("conflict"))
+}
+
+func TestWorkerPoolOnStartProcessing(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	events := make(chan string, 4)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithOnStartProcessing(func(target Resource, queuedFor time.Duration) {
+		events <- fmt.Sprintf("start %s after %v", target.Name, queuedFor)
+	}), WithProcessHooks(nil, func(target Resource, err error, duration time.Duration) {
+		events <- "done " + target.Name
+	}), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	wp.Hold(outcomeTarget("a"))
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	fakeClock.Step(3 * time.Second)
+	wp.Release(outcomeTarget("a"))
+	g.Eventually(events).Should(Receive(Equal("start a after 3s")))
+	g.Eventually(events).Should(Receive(Equal("done a")))
+}
+
+func TestWorkerPoolProgressReducer(t *testing.T) {
+	g := NewGomegaWithT(t)
+	type progress struct {
+		Reason  string
+		Verbose []byte
+	}
+	applied := make(chan interface{}, 2)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithProgressReducer(func(p interface{}) interface{} {
+		return progress{Reason: p.(progress).Reason}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{Name: "ctl", fn: func(status interface{}, context interface{}) GenerationProvider {
+		applied <- context
+		return &IstioGenerationProvider{}
+	}}
+
+	wp.Hold(outcomeTarget("a"))
+	wp.Push(outcomeTarget("a"), ctl, progress{Reason: "Accepted", Verbose: make([]byte, 1<<20)})
+	g.Expect(wp.PendingProgress(outcomeTarget("a"))).To(Equal(map[string]interface{}{"ctl": progress{Reason: "Accepted"}}))
+	wp.Release(outcomeTarget("a"))
+	g.Eventually(applied).Should(Receive(Equal(progress{Reason: "Accepted"})))
+
+	// nil progress is not reduced
+	wp.Push(outcomeTarget("b"), ctl, nil)
+	g.Eventually(applied).Should(Receive(BeNil()))
+}
+
+func TestWorkerPoolOrderedGenerations(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 5)
+	wp := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+		s := status.(*IstioGenerationProvider)
+		written <- strconv.FormatInt(s.ObservedGeneration, 10) + "/" + s.Conditions[0].Reason
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 4}}
+	}, 1, WithOrderedGenerations(3))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{
+			Conditions: []*v1alpha1.IstioCondition{{Type: "Reconciled", Reason: context.(string)}},
+		}}
+	}}
+	target := outcomeTarget("a")
+	wp.Hold(target)
+	for generation := 1; generation <= 4; generation++ {
+		target.Generation = strconv.Itoa(generation)
+		wp.Push(target, ctl, "progress"+target.Generation)
+	}
+	// pushing the same generation again merges with it
+	wp.Push(target, ctl, "latest")
+	wp.Release(target)
+
+	// the oldest generation was dropped, as only three are kept
+	for _, want := range []string{"2/progress2", "3/progress3", "4/latest"} {
+		var got string
+		g.Eventually(written).Should(Receive(&got))
+		g.Expect(got).To(Equal(want))
+	}
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(wp.Stats().Queued).To(Equal(0))
+
+	// a retained generation which cannot be parsed is checked against the config like the latest one, and dropped
+	wp.Hold(target)
+	for _, generation := range []string{"bogus", " 3 ", "4"} {
+		target.Generation = generation
+		wp.Push(target, ctl, "progress"+strings.TrimSpace(generation))
+	}
+	wp.Release(target)
+	for _, want := range []string{"3/progress3", "4/progress4"} {
+		var got string
+		g.Eventually(written).Should(Receive(&got))
+		g.Expect(got).To(Equal(want))
+	}
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+}
+
+func TestWorkerPoolCoalescing(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 5)
+	wp := NewWorkerPool(func(_ *config.Config, status interface{}) (bool, error) {
+		var reasons []string
+		for _, c := range status.(*IstioGenerationProvider).Conditions {
+			reasons = append(reasons, c.Reason)
+		}
+		written <- strings.Join(reasons, ",")
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}, Status: &v1alpha1.IstioStatus{}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	controller := func(name string, priority int, coalescing CoalescingPolicy) *Controller {
+		return &Controller{Name: name, Priority: priority, Coalescing: coalescing,
+			fn: func(status interface{}, context interface{}) GenerationProvider {
+				s := status.(*IstioGenerationProvider)
+				s.Conditions = append(s.Conditions, &v1alpha1.IstioCondition{Reason: name + "=" + context.(string)})
+				return s
+			}}
+	}
+	audit := controller("audit", 1, CoalesceNever)
+	latest := controller("latest", 2, CoalesceLatest)
+	target := outcomeTarget("a")
+	wp.Hold(target)
+	wp.Push(target, audit, "1")
+	wp.Push(target, latest, "1")
+	// the coalescing controller replaces its own progress
+	wp.Push(target, latest, "2")
+	// the non-coalescing controller closes the update holding its first push, carrying the other progress forward
+	wp.Push(target, audit, "2")
+	wp.Push(target, latest, "3")
+	wp.Push(target, audit, "3")
+	wp.Release(target)
+
+	for _, want := range []string{"audit=1,latest=2", "audit=2,latest=3", "audit=3,latest=3"} {
+		var got string
+		g.Eventually(written).Should(Receive(&got))
+		g.Expect(got).To(Equal(want))
+	}
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(wp.Stats().Queued).To(Equal(0))
+}
+
+func TestWorkerPoolSingleThreaded(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var current, peak int32
+	var lock sync.Mutex
+	var order []string
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		if n := atomic.AddInt32(&current, 1); n > atomic.LoadInt32(&peak) {
+			atomic.StoreInt32(&peak, n)
+		}
+		time.Sleep(time.Millisecond)
+		lock.Lock()
+		order = append(order, cfg.Name)
+		lock.Unlock()
+		atomic.AddInt32(&current, -1)
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 5, WithSingleThreaded(), WithShards(2))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	var want []string
+	for i := 0; i < 20; i++ {
+		want = append(want, strconv.Itoa(i))
+		wp.Push(outcomeTarget(strconv.Itoa(i)), ctl, nil)
+	}
+	// a target processed directly does not overlap with the worker either
+	g.Expect(wp.ProcessWithController(outcomeTarget("direct"), ctl)).To(Succeed())
+	g.Eventually(func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(order)
+	}).Should(Equal(21))
+	g.Expect(atomic.LoadInt32(&peak)).To(Equal(int32(1)))
+	lock.Lock()
+	defer lock.Unlock()
+	var queued []string
+	for _, name := range order {
+		if name != "direct" {
+			queued = append(queued, name)
+		}
+	}
+	g.Expect(queued).To(Equal(want))
+}
+
+func TestWorkerPoolRetriesWhenGetPanics(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	var gets int32
+	written := make(chan string, 1)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		if atomic.AddInt32(&gets, 1) == 1 {
+			panic("store is being swapped")
+		}
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithReadRetryDelay(time.Second), func(wp *WorkerPool) {
+		wp.clock = fakeClock
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	wp.Push(outcomeTarget("a"), &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}, nil)
+
+	// the target is requeued rather than dropped, and its slot is released
+	g.Eventually(func() OutcomeType {
+		outcome, _ := wp.LastOutcome(outcomeTarget("a"))
+		return outcome.Type
+	}).Should(Equal(OutcomeFailed))
+	g.Eventually(wp.InFlight).Should(BeEmpty())
+	g.Expect(wp.Stats().Queued).To(Equal(1))
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+
+	fakeClock.Step(time.Second)
+	g.Eventually(written).Should(Receive(Equal("a")))
+	g.Expect(atomic.LoadInt32(&gets)).To(Equal(int32(2)))
+}
+
+func getSpuriousWakeups(t *testing.T) float64 {
+	rows, err := view.RetrieveData(spuriousWakeups.Name())
+	if err != nil {
+		t.Fatalf("failed to get value for %s: %v", spuriousWakeups.Name(), err)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return rows[0].Data.(*view.SumData).Value
+}
+
+func TestWorkerPoolPushWakesOneWorker(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 1)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 8)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	// park every worker, with only held tasks queued
+	for i := 0; i < 8; i++ {
+		wp.Hold(outcomeTarget(strconv.Itoa(i)))
+		wp.Push(outcomeTarget(strconv.Itoa(i)), ctl, nil)
+	}
+	g.Eventually(func() uint { return wp.Stats().Workers }).Should(Equal(uint(8)))
+	g.Consistently(func() float64 { return getSpuriousWakeups(t) }, 100*time.Millisecond).Should(BeNumerically("==",
+		getSpuriousWakeups(t)))
+	before := getSpuriousWakeups(t)
+
+	for i := 0; i < 5; i++ {
+		wp.Push(outcomeTarget("free"), ctl, nil)
+		g.Eventually(written).Should(Receive(Equal("free")))
+	}
+	// each push wakes a single worker to process it, and finishing wakes at most one more, which finds nothing
+	g.Consistently(func() float64 { return getSpuriousWakeups(t) - before }, 100*time.Millisecond).
+		Should(BeNumerically("<=", 5))
+}
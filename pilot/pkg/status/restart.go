@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"errors"
+)
+
+// Restart runs a pool again after it stopped, as if it were new, with queued tasks kept: those left when it stopped,
+// and those pushed since, are processed once it has restarted.  The context passed to the previous Run must be done,
+// so that the periodic routines of the previous run have stopped; a pool stopped only by Shutdown cannot be restarted.
+// Restart waits for the workers of the previous run to exit.  It returns an error, leaving the pool as it was, if the
+// pool has never run, is still running or has been absorbed by another pool.
+func (wp *WorkerPool) Restart(ctx context.Context) error {
+	if err := wp.checkRestart(); err != nil {
+		return err
+	}
+	wp.reset()
+	wp.Run(ctx)
+	wp.startWorkers()
+	return nil
+}
+
+// checkRestart returns an error if the pool, or any of its shards, cannot be restarted.
+func (wp *WorkerPool) checkRestart() error {
+	for _, shard := range wp.shards {
+		if err := shard.checkRestart(); err != nil {
+			return err
+		}
+	}
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	if wp.absorbedBy != nil {
+		return errors.New("cannot restart a pool which has been absorbed by another")
+	}
+	if wp.stopped == nil {
+		return errors.New("cannot restart a pool which has never run")
+	}
+	select {
+	case <-wp.stopped:
+		return nil
+	default:
+		return errors.New("cannot restart a pool whose Run context is not done")
+	}
+}
+
+// reset clears the stopped state of the pool and its shards, once their workers have exited.
+func (wp *WorkerPool) reset() {
+	for _, shard := range wp.shards {
+		shard.reset()
+	}
+	wp.running.Wait()
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	wp.closing = false
+	wp.draining = false
+	wp.q.lock.Lock()
+	wp.q.staysEnded = false
+	wp.q.lock.Unlock()
+}
+
+// startWorkers starts workers for the tasks queued in the pool and its shards.
+func (wp *WorkerPool) startWorkers() {
+	for _, shard := range wp.shards {
+		shard.startWorkers()
+	}
+	wp.lock.Lock()
+	workers := wp.maxWorkers
+	wp.lock.Unlock()
+	for i := uint(0); i < workers; i++ {
+		wp.maybeAddWorker()
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolRestart(t *testing.T) {
+	for _, shards := range []uint{0, 2} {
+		g := NewGomegaWithT(t)
+		written := make(chan string, 10)
+		wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+			written <- cfg.Name
+			return true, nil
+		}, func(r Resource) *config.Config {
+			return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+		}, 2, WithShards(shards))
+		ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+			return &IstioGenerationProvider{}
+		}}
+		g.Expect(wp.Restart(context.Background())).To(MatchError("cannot restart a pool which has never run"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		wp.Run(ctx)
+		wp.Push(outcomeTarget("a"), ctl, nil)
+		g.Eventually(written).Should(Receive(Equal("a")))
+		// a pool stopped by Shutdown alone still has its first run's routines
+		g.Expect(wp.Shutdown(context.Background(), false)).To(Succeed())
+		g.Expect(wp.Restart(context.Background())).To(MatchError("cannot restart a pool whose Run context is not done"))
+		cancel()
+
+		// tasks pushed while the pool is stopped are processed once it restarts
+		wp.Push(outcomeTarget("b"), ctl, nil)
+		g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+		ctx, cancel = context.WithCancel(context.Background())
+		g.Eventually(func() error { return wp.Restart(ctx) }).Should(Succeed())
+		g.Eventually(written).Should(Receive(Equal("b")))
+		wp.Push(outcomeTarget("c"), ctl, nil)
+		g.Eventually(written).Should(Receive(Equal("c")))
+		g.Expect(wp.Restart(ctx)).To(MatchError("cannot restart a pool whose Run context is not done"))
+		cancel()
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"time"
+)
+
+// ProcessResult describes the processing of one task by a WorkerPool.
+type ProcessResult struct {
+	Target Resource
+	// Outcome is what processing resulted in: OutcomeWritten, OutcomeNoop or OutcomeFailed if status was written,
+	// otherwise the reason it was skipped.
+	Outcome OutcomeType
+	// Changed reports whether the write persisted a change.
+	Changed bool
+	// Err is the write error, for OutcomeFailed.
+	Err error
+	// Duration is how long processing took, including retrieving the config and writing status.
+	Duration time.Duration
+}
+
+// WithResults sends the result of each processed task to the channel returned by Results, which has capacity buffer.
+// Workers never block on the channel: if it is full the result is dropped and counted in the
+// pilot_status_results_dropped metric, so consumers which must not miss results should keep up or size the buffer
+// generously.
+func WithResults(buffer int) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.results = make(chan ProcessResult, buffer)
+	}
+}
+
+// Results returns the channel receiving the result of each processed task, or nil unless WithResults was set.  The
+// channel is never closed.
+func (wp *WorkerPool) Results() <-chan ProcessResult {
+	if wp.results == nil {
+		return nil
+	}
+	return wp.results
+}
+
+func (wp *WorkerPool) sendResult(target Resource, outcome OutcomeType, err error, start time.Time) {
+	wp.countProcessed(outcome)
+	if wp.results == nil {
+		return
+	}
+	result := ProcessResult{
+		Target:   target,
+		Outcome:  outcome,
+		Changed:  outcome == OutcomeWritten,
+		Err:      err,
+		Duration: wp.clock.Since(start),
+	}
+	select {
+	case wp.results <- result:
+	default:
+		resultsDropped.Increment()
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"go.opencensus.io/stats/view"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolResults(t *testing.T) {
+	g := NewGomegaWithT(t)
+	g.Expect(NewWorkerPool(nil, nil, 0).Results()).To(BeNil())
+
+	writeErr := errors.New("conflict")
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		if cfg.Name == "fail" {
+			return false, writeErr
+		}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		if r.Name == "missing" {
+			return nil
+		}
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithResults(10))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	wp.Push(outcomeTarget("ok"), c, nil)
+	var result ProcessResult
+	g.Eventually(wp.Results()).Should(Receive(&result))
+	g.Expect(result.Target).To(Equal(outcomeTarget("ok")))
+	g.Expect(result.Outcome).To(Equal(OutcomeWritten))
+	g.Expect(result.Changed).To(BeTrue())
+	g.Expect(result.Err).To(BeNil())
+
+	wp.Push(outcomeTarget("fail"), c, nil)
+	g.Eventually(wp.Results()).Should(Receive(&result))
+	g.Expect(result.Target).To(Equal(outcomeTarget("fail")))
+	g.Expect(result.Outcome).To(Equal(OutcomeFailed))
+	g.Expect(result.Changed).To(BeFalse())
+	g.Expect(result.Err).To(Equal(writeErr))
+
+	wp.Push(outcomeTarget("missing"), c, nil)
+	g.Eventually(wp.Results()).Should(Receive(&result))
+	g.Expect(result.Outcome).To(Equal(OutcomeNotFound))
+}
+
+func TestWorkerPoolResultsDropWhenFull(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0, WithResults(1))
+	before := resultsDroppedCount(t)
+	wp.sendResult(outcomeTarget("a"), OutcomeWritten, nil, wp.clock.Now())
+	wp.sendResult(outcomeTarget("b"), OutcomeWritten, nil, wp.clock.Now())
+	g.Eventually(func() float64 { return resultsDroppedCount(t) - before }).Should(Equal(1.0))
+	var result ProcessResult
+	g.Expect(wp.Results()).To(Receive(&result))
+	g.Expect(result.Target.Name).To(Equal("a"))
+	g.Expect(wp.Results()).NotTo(Receive())
+}
+
+func resultsDroppedCount(t *testing.T) float64 {
+	rows, err := view.RetrieveData(resultsDropped.Name())
+	if err != nil {
+		t.Fatalf("failed to get value for %s: %v", resultsDropped.Name(), err)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return rows[0].Data.(*view.SumData).Value
+}
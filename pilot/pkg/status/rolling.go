@@ -0,0 +1,63 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"time"
+)
+
+// rollingBuckets is how many buckets a rollingCounter divides its window into, so that a count stops being included up
+// to a tenth of the window early.
+const rollingBuckets = 10
+
+// rollingCounter maintains a rolling count over a window, in a fixed ring of buckets each covering a fraction of the
+// window.  The window is passed to each call, so that it can be set by an option after the counter is created.  It is
+// not safe for concurrent use.
+type rollingCounter struct {
+	counts [rollingBuckets]int64
+	// the bucket index, in units of the bucket width since the zero time, each count belongs to
+	epochs [rollingBuckets]int64
+}
+
+func rollingEpoch(now time.Time, window time.Duration) int64 {
+	width := int64(window) / rollingBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return now.UnixNano() / width
+}
+
+// add adds n to the count at now.
+func (r *rollingCounter) add(now time.Time, window time.Duration, n int64) {
+	epoch := rollingEpoch(now, window)
+	i := epoch % rollingBuckets
+	if r.epochs[i] != epoch {
+		r.epochs[i] = epoch
+		r.counts[i] = 0
+	}
+	r.counts[i] += n
+}
+
+// total returns the count over the window ending at now.
+func (r *rollingCounter) total(now time.Time, window time.Duration) int64 {
+	epoch := rollingEpoch(now, window)
+	var n int64
+	for i, e := range r.epochs {
+		if e > epoch-rollingBuckets {
+			n += r.counts[i]
+		}
+	}
+	return n
+}
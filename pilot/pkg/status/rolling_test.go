@@ -0,0 +1,59 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRollingCounter(t *testing.T) {
+	var r rollingCounter
+	window := 10 * time.Second
+	start := time.Unix(0, 0)
+	r.add(start, window, 1)
+	r.add(start.Add(500*time.Millisecond), window, 2)
+	r.add(start.Add(4*time.Second), window, 4)
+	cases := []struct {
+		at   time.Duration
+		want int64
+	}{
+		{5 * time.Second, 7},
+		{9*time.Second + 999*time.Millisecond, 7},
+		// the first bucket has left the window
+		{10 * time.Second, 4},
+		{14 * time.Second, 0},
+		// far later, buckets reused by later epochs are not counted
+		{time.Hour, 0},
+	}
+	for _, tt := range cases {
+		if got := r.total(start.Add(tt.at), window); got != tt.want {
+			t.Errorf("total at %v = %d, want %d", tt.at, got, tt.want)
+		}
+	}
+
+	// a bucket is reset when it is reused for a later epoch
+	r.add(start.Add(20*time.Second), window, 8)
+	if got := r.total(start.Add(20*time.Second), window); got != 8 {
+		t.Errorf("total after reuse = %d, want 8", got)
+	}
+
+	// a zero window still counts
+	var z rollingCounter
+	z.add(start, 0, 1)
+	if got := z.total(start, 0); got != 1 {
+		t.Errorf("total with zero window = %d, want 1", got)
+	}
+}
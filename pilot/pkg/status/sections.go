@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+// SectionedStatus is a status made of independent named sections, each updated by its own controller, so that
+// controllers contributing to one object cannot overwrite each other's part of its status.  A stored status
+// implementing SectionedStatus is passed to the controllers as is.
+type SectionedStatus interface {
+	GenerationProvider
+	// Section returns the current value of the named section, or nil if it is not set.
+	Section(name string) interface{}
+	// SetSection replaces the value of the named section.
+	SetSection(name string, value interface{})
+}
+
+// SectionUpdateFunc computes the new value of a status section from its current value, which is nil if the section
+// is not set, and the progress the controller has queued.
+type SectionUpdateFunc func(section interface{}, context interface{}) interface{}
+
+// Sections is a SectionedStatus holding each section by name.  It is the status built for a target which has no
+// stored status, and may be stored as the status of resources whose sections are independent objects.
+type Sections struct {
+	ObservedGeneration int64
+	Values             map[string]interface{}
+}
+
+func (s *Sections) Section(name string) interface{} {
+	return s.Values[name]
+}
+
+func (s *Sections) SetSection(name string, value interface{}) {
+	if s.Values == nil {
+		s.Values = make(map[string]interface{})
+	}
+	s.Values[name] = value
+}
+
+func (s *Sections) SetObservedGeneration(in int64) {
+	s.ObservedGeneration = in
+}
+
+func (s *Sections) Unwrap() interface{} {
+	return s
+}
+
+// CreateSectionController creates a controller, named after section, which updates only that section of the status of
+// its targets, leaving the other sections to the controllers which own them.  The status of its targets must be a
+// SectionedStatus, or unset, in which case a Sections is built.
+func (m *Manager) CreateSectionController(section string, fn SectionUpdateFunc) *Controller {
+	result := &Controller{
+		Name:    section,
+		fn:      sectionUpdate(section, fn),
+		workers: m.workers,
+	}
+	m.register(result)
+	return result
+}
+
+// sectionUpdate returns an UpdateFunc applying fn to the named section of a SectionedStatus.
+func sectionUpdate(section string, fn SectionUpdateFunc) UpdateFunc {
+	return func(status interface{}, context interface{}) GenerationProvider {
+		var sectioned SectionedStatus
+		switch s := status.(type) {
+		case SectionedStatus:
+			sectioned = s
+		case nil:
+			sectioned = &Sections{}
+		default:
+			scope.Errorf("cannot update section %q of status %T, which is not a SectionedStatus", section, status)
+			if p, ok := status.(GenerationProvider); ok {
+				return p
+			}
+			return nil
+		}
+		sectioned.SetSection(section, fn(sectioned.Section(section), context))
+		return sectioned
+	}
+}
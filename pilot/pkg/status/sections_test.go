@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolSections(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan *Sections, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, status interface{}) (bool, error) {
+		written <- status.(*Sections)
+		return true, nil
+	}, func(r Resource) *config.Config {
+		cfg := &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+		if r.Name == "stored" {
+			cfg.Status = &Sections{Values: map[string]interface{}{"routing": "stale", "legacy": "kept"}}
+		}
+		return cfg
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	update := func(current interface{}, context interface{}) interface{} {
+		return fmt.Sprintf("%v from %v", context, current)
+	}
+	newController := func(section string) *Controller {
+		return &Controller{Name: section, fn: sectionUpdate(section, update)}
+	}
+	routing := newController("routing")
+	security := newController("security")
+
+	for _, name := range []string{"stored", "new"} {
+		target := outcomeTarget(name)
+		// both controllers contribute to a single write
+		wp.Hold(target)
+		wp.Push(target, routing, "routes")
+		wp.Push(target, security, "policies")
+		wp.Release(target)
+	}
+	g.Eventually(written).Should(Receive(Equal(&Sections{
+		ObservedGeneration: 1,
+		Values: map[string]interface{}{
+			"routing":  "routes from stale",
+			"security": "policies from <nil>",
+			"legacy":   "kept",
+		},
+	})))
+	g.Eventually(written).Should(Receive(Equal(&Sections{
+		ObservedGeneration: 1,
+		Values: map[string]interface{}{
+			"routing":  "routes from <nil>",
+			"security": "policies from <nil>",
+		},
+	})))
+}
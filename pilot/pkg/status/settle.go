@@ -0,0 +1,46 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"time"
+)
+
+// WithInitialSettle delays the first processing of each target by delay from its first push, as resources often
+// receive a burst of updates just after they are created and writing status straight away only churns.  Unlike
+// WithDebounce, the delay does not restart with each push, and applies only to the first time a target is queued;
+// later pushes are processed as usual.  The pool remembers each target it has queued until it is deleted, so that a
+// deleted and recreated resource settles again.  Zero, the default, processes new targets straight away.
+func WithInitialSettle(delay time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.q.initialSettle = delay
+	}
+}
+
+// settle returns the time before which a target with key, being queued at now, may not be popped, which is the zero
+// time unless it has not been queued before.  The caller must hold wq.lock.
+func (wq *WorkQueue) settle(key lockResource, now time.Time) time.Time {
+	if wq.initialSettle <= 0 {
+		return time.Time{}
+	}
+	if _, seen := wq.seen[key]; seen {
+		return time.Time{}
+	}
+	if wq.seen == nil {
+		wq.seen = make(map[lockResource]struct{})
+	}
+	wq.seen[key] = struct{}{}
+	return now.Add(wq.initialSettle)
+}
@@ -0,0 +1,63 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolInitialSettle(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithInitialSettle(5*time.Second), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	// the first processing of a new target waits for the settle delay, which pushes do not restart
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	fakeClock.Step(3 * time.Second)
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	fakeClock.Step(2 * time.Second)
+	g.Eventually(written).Should(Receive(Equal("a")))
+
+	// later pushes are processed straight away
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	g.Eventually(written).Should(Receive(Equal("a")))
+
+	// a deleted target settles again when it is recreated
+	wp.Delete(outcomeTarget("a"))
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	fakeClock.Step(5 * time.Second)
+	g.Eventually(written).Should(Receive(Equal("a")))
+}
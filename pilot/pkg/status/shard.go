@@ -0,0 +1,103 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"istio.io/istio/pkg/config"
+)
+
+// WithShards splits the pool into n sub-pools, each with its own queue, lock and share of maxWorkers, to reduce lock
+// contention in very large meshes.  A target is always routed to the same shard by a hash of its key, so it is still
+// processed by at most one worker at a time.  maxWorkers is divided evenly between shards, rounding up so that each
+// shard has a worker if maxWorkers is non-zero.  The bounds set by WithOutcomeHistory, WithHotTargets and
+// WithKindCaps apply to each shard, as do the thresholds of WithQueueWatermarks, and worker hooks are invoked with the
+// worker count of the shard.
+func WithShards(n uint) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.shardCount = n
+	}
+}
+
+// newShards creates the sub-pools of a sharded pool, configured with the options of the pool.
+func (wp *WorkerPool) newShards(write WriteFunc, get func(Resource) *config.Config, opts []WorkerPoolOption) {
+	perShard := (wp.maxWorkers + wp.shardCount - 1) / wp.shardCount
+	shardOpts := append(append([]WorkerPoolOption{}, opts...), func(shard *WorkerPool) {
+		shard.shardCount = 0
+		// the pool logs a single summary covering every shard
+		shard.summaryInterval = 0
+	})
+	wp.shards = make([]*WorkerPool, wp.shardCount)
+	for i := range wp.shards {
+		shard := NewWorkerPool(write, get, perShard, shardOpts...)
+		// observers of the pool see the results and written status of every shard
+		shard.results = wp.results
+		shard.lastWritten = wp.lastWritten
+		shard.provenance = wp.provenance
+		shard.latencies = wp.latencies
+		shard.reads = wp.reads
+		shard.trace = wp.trace
+		shard.outcomes.trace = wp.trace
+		wp.shards[i] = shard
+	}
+}
+
+// shard returns the sub-pool responsible for target.
+func (wp *WorkerPool) shard(target Resource) *WorkerPool {
+	h := fnv.New32a()
+	for _, s := range []string{target.Cluster, target.Group, target.Version, target.Resource, target.Namespace, target.Name} {
+		_, _ = h.Write([]byte(s))
+		// separate the fields, so that different keys do not hash the same concatenation
+		_, _ = h.Write([]byte{0})
+	}
+	if target.ClusterScoped {
+		_, _ = h.Write([]byte{1})
+	}
+	return wp.shards[h.Sum32()%uint32(len(wp.shards))]
+}
+
+// shardedStats aggregates the stats of all shards.
+func (wp *WorkerPool) shardedStats() Stats {
+	var stats Stats
+	var busy, capacity time.Duration
+	top := 0
+	for _, shard := range wp.shards {
+		totals := shard.utilization.totals()
+		busy += totals.busy
+		capacity += totals.capacity
+		stats.WorkerUtilization = append(stats.WorkerUtilization, totals.perWorker()...)
+		s := shard.Stats()
+		stats.Queued += s.Queued
+		stats.InFlight += s.InFlight
+		stats.Workers += s.Workers
+		stats.HotTargets = append(stats.HotTargets, s.HotTargets...)
+		top = shard.attempts.top
+	}
+	sort.Slice(stats.HotTargets, func(i, j int) bool {
+		a, b := stats.HotTargets[i], stats.HotTargets[j]
+		if a.Attempts != b.Attempts {
+			return a.Attempts > b.Attempts
+		}
+		return convert(a.Target).less(convert(b.Target))
+	})
+	stats.Utilization = fraction(busy, capacity)
+	if len(stats.HotTargets) > top {
+		stats.HotTargets = stats.HotTargets[:top]
+	}
+	return stats
+}
@@ -0,0 +1,123 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestShardedWorkerPool(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var lock sync.Mutex
+	written := map[string]int{}
+	var concurrent, maxConcurrent int32
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		n := atomic.AddInt32(&concurrent, 1)
+		defer atomic.AddInt32(&concurrent, -1)
+		lock.Lock()
+		defer lock.Unlock()
+		written[cfg.Name]++
+		if n > maxConcurrent {
+			maxConcurrent = n
+		}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 5, WithShards(4))
+	g.Expect(wp.shards).To(HaveLen(4))
+	for _, shard := range wp.shards {
+		g.Expect(shard.maxWorkers).To(Equal(uint(2)))
+		g.Expect(shard.shards).To(BeNil())
+	}
+	// the same target always maps to the same shard
+	g.Expect(wp.shard(outcomeTarget("a"))).To(BeIdenticalTo(wp.shard(outcomeTarget("a"))))
+
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	// queue before running, so that every target is pending
+	for i := 0; i < 20; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), c, nil)
+	}
+	g.Expect(wp.Stats().Queued).To(Equal(20))
+	g.Expect(wp.PendingProgress(outcomeTarget("3"))).NotTo(BeNil())
+	outcome, _ := wp.LastOutcome(outcomeTarget("3"))
+	g.Expect(outcome.Type).To(Equal(OutcomeQueued))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	g.Eventually(func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(written)
+	}).Should(Equal(20))
+	for i := 0; i < 20; i++ {
+		name := strconv.Itoa(i)
+		g.Eventually(func() OutcomeType {
+			outcome, _ := wp.LastOutcome(outcomeTarget(name))
+			return outcome.Type
+		}).Should(Equal(OutcomeWritten))
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	for name, count := range written {
+		g.Expect(count).To(Equal(1), name)
+	}
+	g.Expect(wp.Stats().Queued).To(Equal(0))
+}
+
+func TestShardedWorkerPoolDeleteNamespace(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0, WithShards(3))
+	for i := 0; i < 10; i++ {
+		target := outcomeTarget(strconv.Itoa(i))
+		wp.Push(target, &Controller{}, nil)
+		target.Namespace = "other"
+		wp.Push(target, &Controller{}, nil)
+	}
+	g.Expect(wp.DeleteNamespace("other")).To(Equal(10))
+	g.Expect(wp.Stats().Queued).To(Equal(10))
+}
+
+func benchmarkPushContention(b *testing.B, opts ...WorkerPoolOption) {
+	// without workers, this measures only the cost of queueing
+	wp := NewWorkerPool(nil, nil, 0, append([]WorkerPoolOption{WithOutcomeHistory(0), WithHotTargets(0, 0)}, opts...)...)
+	c := &Controller{}
+	var next int64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			wp.Push(outcomeTarget(strconv.FormatInt(atomic.AddInt64(&next, 1)%1000, 10)), c, nil)
+		}
+	})
+}
+
+func BenchmarkPushContention(b *testing.B) {
+	b.Run("unsharded", func(b *testing.B) {
+		benchmarkPushContention(b)
+	})
+	b.Run("8 shards", func(b *testing.B) {
+		benchmarkPushContention(b, WithShards(8))
+	})
+}
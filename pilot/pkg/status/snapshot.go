@@ -0,0 +1,135 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// the bookkeeping of a pool captured by a Snapshot, by name
+var snapshotSets = [...]string{
+	"tasks", "cache", "held", "currentlyWorking", "working", "staged", "refetches", "requiredWaits", "dependencyWaits",
+}
+
+// Snapshot captures which targets the internal bookkeeping of a WorkerPool holds at an instant, as sorted key lists.
+// Snapshots are comparable with ==, so that a long-running test can check the pool returned to a baseline after an
+// operation, catching entries which leak slowly.  Use DiffSnapshots to see what changed.
+type Snapshot struct {
+	// for each of snapshotSets, the sorted keys it holds, one per line
+	sets [len(snapshotSets)]string
+}
+
+// Snapshot returns the current Snapshot of the pool, covering every shard.
+func (wp *WorkerPool) Snapshot() Snapshot {
+	var keys [len(snapshotSets)][]string
+	wp.snapshotKeys(&keys)
+	var s Snapshot
+	for i := range keys {
+		sort.Strings(keys[i])
+		s.sets[i] = strings.Join(keys[i], "\n")
+	}
+	return s
+}
+
+// snapshotKeys appends the keys held by each set of the pool and its shards to keys.
+func (wp *WorkerPool) snapshotKeys(keys *[len(snapshotSets)][]string) {
+	for _, shard := range wp.shards {
+		shard.snapshotKeys(keys)
+	}
+	add := func(i int, key lockResource) {
+		keys[i] = append(keys[i], snapshotKey(key))
+	}
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	wp.q.lock.Lock()
+	for _, key := range wp.q.tasks {
+		add(0, key)
+	}
+	for key := range wp.q.cache {
+		add(1, key)
+	}
+	for key := range wp.q.held {
+		add(2, key)
+	}
+	wp.q.lock.Unlock()
+	for key := range wp.currentlyWorking {
+		add(3, key)
+	}
+	for key := range wp.working {
+		add(4, key)
+	}
+	for key := range wp.staged {
+		add(5, key)
+	}
+	for key := range wp.refetches {
+		add(6, key)
+	}
+	for key := range wp.requiredWaits {
+		add(7, key)
+	}
+	for key := range wp.dependencyWaits {
+		add(8, key)
+	}
+}
+
+func snapshotKey(key lockResource) string {
+	ns := key.Namespace
+	if key.ClusterScoped {
+		ns = "(cluster)"
+	}
+	s := strings.Join([]string{key.Group, key.Version, key.Resource, ns, key.Name}, "/")
+	if key.Cluster != "" {
+		return key.Cluster + ":" + s
+	}
+	return s
+}
+
+// DiffSnapshots describes how after differs from before, one line per key added to or removed from a set, such as
+// "+tasks r1/r1//ns/name".  It returns nil if the snapshots are equal.
+func DiffSnapshots(before, after Snapshot) []string {
+	var diff []string
+	for i, name := range snapshotSets {
+		if before.sets[i] == after.sets[i] {
+			continue
+		}
+		was, is := snapshotLines(before.sets[i]), snapshotLines(after.sets[i])
+		for key, n := range is {
+			for ; n > was[key]; n-- {
+				diff = append(diff, fmt.Sprintf("+%s %s", name, key))
+			}
+		}
+		for key, n := range was {
+			for ; n > is[key]; n-- {
+				diff = append(diff, fmt.Sprintf("-%s %s", name, key))
+			}
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// snapshotLines counts the keys in a set of a Snapshot, which may repeat a key queued more than once.
+func snapshotLines(set string) map[string]int {
+	counts := make(map[string]int)
+	if set == "" {
+		return counts
+	}
+	for _, key := range strings.Split(set, "\n") {
+		counts[key]++
+	}
+	return counts
+}
@@ -0,0 +1,61 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolSnapshot(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	baseline := wp.Snapshot()
+
+	wp.Hold(outcomeTarget("a"))
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	g.Expect(DiffSnapshots(baseline, wp.Snapshot())).To(Equal([]string{
+		"+cache r1/r1//r1/a", "+held r1/r1//r1/a", "+tasks r1/r1//r1/a",
+	}))
+	wp.Release(outcomeTarget("a"))
+	g.Eventually(written).Should(Receive(Equal("a")))
+	g.Eventually(wp.Snapshot).Should(Equal(baseline))
+
+	// a target which is never released from the working set is detected
+	wp.lock.Lock()
+	wp.currentlyWorking[convert(outcomeTarget("leaked"))] = struct{}{}
+	wp.lock.Unlock()
+	after := wp.Snapshot()
+	g.Expect(after).NotTo(Equal(baseline))
+	g.Expect(DiffSnapshots(baseline, after)).To(Equal([]string{"+currentlyWorking r1/r1//r1/leaked"}))
+	g.Expect(DiffSnapshots(after, baseline)).To(Equal([]string{"-currentlyWorking r1/r1//r1/leaked"}))
+	g.Expect(DiffSnapshots(after, after)).To(BeNil())
+}
@@ -0,0 +1,192 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+const (
+	defaultAttemptWindow = 5 * time.Minute
+	defaultHotTargets    = 10
+	maxAttemptTargets    = 1000
+)
+
+// Stats is a snapshot of the state of a WorkerPool.
+type Stats struct {
+	// Queued is the number of targets waiting to be processed.
+	Queued int
+	// InFlight is the number of targets currently being processed.
+	InFlight int
+	// Workers is the number of running worker routines.
+	Workers uint
+	// Utilization is the fraction of the capacity of the pool over the last five minutes, the worker limit times the
+	// time elapsed, which workers spent processing targets.  Workers which were waiting for a target, or had exited for
+	// lack of one, count as idle.  Low utilization suggests maxWorkers is higher than needed, and utilization near one
+	// that it is a bottleneck.
+	Utilization float64
+	// WorkerUtilization is the fraction of the last five minutes each worker slot spent processing targets, covering at
+	// least the worker limit.  A starting worker takes the lowest free slot, so the higher slots of an over-provisioned
+	// pool stay near zero.  In a sharded pool, the slots of each shard are listed in turn.
+	WorkerUtilization []float64
+	// HotTargets are the targets processed most often over the attempt window, most processed first.
+	HotTargets []TargetAttempts
+}
+
+// TargetAttempts is the number of times a target was processed over the attempt window.
+type TargetAttempts struct {
+	Target   Resource
+	Attempts int
+}
+
+// WithHotTargets sets the rolling window over which processing attempts are counted per target, and the number of
+// most processed targets reported in Stats.  A target processed far more often than expected usually indicates a
+// controller caught in a push loop.
+func WithHotTargets(window time.Duration, n int) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.attempts.window = window
+		wp.attempts.top = n
+	}
+}
+
+// Stats returns a snapshot of the state of the pool.
+func (wp *WorkerPool) Stats() Stats {
+	if wp.shards != nil {
+		return wp.shardedStats()
+	}
+	wp.lock.Lock()
+	stats := Stats{
+		Queued:   wp.q.Length(),
+		InFlight: len(wp.currentlyWorking),
+		Workers:  wp.workerCount,
+	}
+	wp.lock.Unlock()
+	totals := wp.utilization.totals()
+	stats.Utilization = totals.utilization()
+	stats.WorkerUtilization = totals.perWorker()
+	stats.HotTargets = wp.attempts.hottest()
+	return stats
+}
+
+// InFlight returns the targets currently being processed, ordered by key.
+func (wp *WorkerPool) InFlight() []Resource {
+	var out []Resource
+	for _, shard := range wp.shards {
+		out = append(out, shard.InFlight()...)
+	}
+	wp.lock.Lock()
+	for _, w := range wp.working {
+		out = append(out, w.target)
+	}
+	wp.lock.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		return convert(out[i]).less(convert(out[j]))
+	})
+	return out
+}
+
+// targetAttempts counts the attempts for one target over the window.
+type targetAttempts struct {
+	target   Resource
+	attempts rollingCounter
+	last     time.Time
+}
+
+// attemptTracker maintains rolling per-target processing attempt counts, for a bounded number of targets.
+type attemptTracker struct {
+	window  time.Duration
+	top     int
+	clock   clock.PassiveClock
+	targets map[lockResource]*targetAttempts
+	lock    sync.Mutex
+}
+
+func newAttemptTracker() *attemptTracker {
+	return &attemptTracker{
+		window:  defaultAttemptWindow,
+		top:     defaultHotTargets,
+		clock:   clock.RealClock{},
+		targets: make(map[lockResource]*targetAttempts),
+	}
+}
+
+func (a *attemptTracker) record(target Resource) {
+	if a.top <= 0 {
+		return
+	}
+	key := convert(target)
+	now := a.clock.Now()
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	t, ok := a.targets[key]
+	if !ok {
+		a.evict(now)
+		t = &targetAttempts{}
+		a.targets[key] = t
+	}
+	t.target = target
+	t.last = now
+	t.attempts.add(now, a.window, 1)
+}
+
+// evict makes room for a new target if the limit has been reached, dropping targets which have not been processed
+// within the window and, if there are still too many targets, the least recently processed.  The caller must hold
+// a.lock.
+func (a *attemptTracker) evict(now time.Time) {
+	if len(a.targets) < maxAttemptTargets {
+		return
+	}
+	var oldestKey lockResource
+	var oldest *targetAttempts
+	for key, t := range a.targets {
+		if t.attempts.total(now, a.window) == 0 {
+			delete(a.targets, key)
+			continue
+		}
+		if oldest == nil || t.last.Before(oldest.last) {
+			oldestKey, oldest = key, t
+		}
+	}
+	if len(a.targets) >= maxAttemptTargets {
+		delete(a.targets, oldestKey)
+	}
+}
+
+// hottest returns the targets with the most attempts over the window, most attempts first.
+func (a *attemptTracker) hottest() []TargetAttempts {
+	now := a.clock.Now()
+	a.lock.Lock()
+	out := make([]TargetAttempts, 0, len(a.targets))
+	for _, t := range a.targets {
+		if n := int(t.attempts.total(now, a.window)); n > 0 {
+			out = append(out, TargetAttempts{Target: t.target, Attempts: n})
+		}
+	}
+	a.lock.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Attempts != out[j].Attempts {
+			return out[i].Attempts > out[j].Attempts
+		}
+		return convert(out[i].Target).less(convert(out[j].Target))
+	})
+	if len(out) > a.top {
+		out = out[:a.top]
+	}
+	return out
+}
@@ -0,0 +1,191 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func statsTarget(name string) Resource {
+	return Resource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "r1", Version: "r1"},
+		Namespace:            "r1",
+		Name:                 name,
+		Generation:           "1",
+	}
+}
+
+func TestAttemptTrackerHottest(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	a := newAttemptTracker()
+	a.clock = fakeClock
+	a.window = time.Minute
+	a.top = 2
+
+	for i := 0; i < 5; i++ {
+		a.record(statsTarget("cold"))
+	}
+	// hot keys are processed repeatedly later, while cold ages out of the window
+	fakeClock.Step(30 * time.Second)
+	for i := 0; i < 20; i++ {
+		a.record(statsTarget("loop"))
+		if i%2 == 0 {
+			a.record(statsTarget("warm"))
+		}
+	}
+	a.record(statsTarget("once"))
+	g.Expect(a.hottest()).To(Equal([]TargetAttempts{
+		{Target: statsTarget("loop"), Attempts: 20},
+		{Target: statsTarget("warm"), Attempts: 10},
+	}))
+
+	a.top = 10
+	g.Expect(a.hottest()).To(HaveLen(4))
+	fakeClock.Step(45 * time.Second)
+	g.Expect(a.hottest()).To(Equal([]TargetAttempts{
+		{Target: statsTarget("loop"), Attempts: 20},
+		{Target: statsTarget("warm"), Attempts: 10},
+		{Target: statsTarget("once"), Attempts: 1},
+	}))
+	fakeClock.Step(time.Minute)
+	g.Expect(a.hottest()).To(BeEmpty())
+}
+
+func TestAttemptTrackerBounded(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	a := newAttemptTracker()
+	a.clock = fakeClock
+	for i := 0; i < maxAttemptTargets+10; i++ {
+		a.record(statsTarget(strconv.Itoa(i)))
+		fakeClock.Step(time.Millisecond)
+	}
+	g.Expect(a.targets).To(HaveLen(maxAttemptTargets))
+	// the least recently processed targets are evicted first
+	g.Expect(a.targets).NotTo(HaveKey(convert(statsTarget("0"))))
+	g.Expect(a.targets).To(HaveKey(convert(statsTarget(strconv.Itoa(maxAttemptTargets + 9)))))
+}
+
+func TestWorkerPoolStats(t *testing.T) {
+	g := NewGomegaWithT(t)
+	wp := NewWorkerPool(nil, nil, 0)
+	wp.Push(statsTarget("a"), &Controller{}, nil)
+	wp.Push(statsTarget("b"), &Controller{}, nil)
+	wp.attempts.record(statsTarget("a"))
+	g.Expect(wp.Stats()).To(Equal(Stats{
+		Queued:     2,
+		HotTargets: []TargetAttempts{{Target: statsTarget("a"), Attempts: 1}},
+	}))
+}
+
+func TestWorkerPoolInFlight(t *testing.T) {
+	g := NewGomegaWithT(t)
+	release := make(chan struct{})
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		<-release
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 2}}
+	}, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	g.Expect(wp.InFlight()).To(BeEmpty())
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	a, b := statsTarget("a"), statsTarget("b")
+	a.Generation, b.Generation = "2", "2"
+	wp.Push(b, ctl, nil)
+	wp.Push(a, ctl, nil)
+	// the targets are reported with the generation they were pushed with
+	g.Eventually(wp.InFlight).Should(Equal([]Resource{a, b}))
+	close(release)
+	g.Eventually(wp.InFlight).Should(BeEmpty())
+}
+
+func TestWorkerPoolUtilization(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	written := make(chan string, 2)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		// each write takes three seconds
+		fakeClock.Step(3 * time.Second)
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 4, func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	g.Expect(wp.Stats().Utilization).To(BeZero())
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	wp.Push(statsTarget("a"), ctl, nil)
+	g.Eventually(written).Should(Receive(Equal("a")))
+	g.Eventually(func() uint { return wp.Stats().Workers }).Should(BeZero())
+	wp.Push(statsTarget("b"), ctl, nil)
+	g.Eventually(written).Should(Receive(Equal("b")))
+	g.Eventually(func() uint { return wp.Stats().Workers }).Should(BeZero())
+	// the pool sits idle with no worker running, which counts against its capacity
+	fakeClock.Step(6 * time.Second)
+
+	// one worker at a time was busy for six of the twelve seconds, out of four workers' capacity
+	stats := wp.Stats()
+	g.Expect(stats.Utilization).To(Equal(0.125))
+	g.Expect(stats.WorkerUtilization).To(Equal([]float64{0.5, 0, 0, 0}))
+}
+
+func TestUtilizationTrackerCapacity(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	u := newUtilizationTracker()
+	u.clock = fakeClock
+	u.setLimit(2)
+	fakeClock.Step(10 * time.Second)
+	u.setLimit(4)
+	slot := u.join()
+	fakeClock.Step(10 * time.Second)
+	u.record(slot, 15*time.Second)
+	u.leave(slot)
+
+	// two workers for ten seconds, then four for ten seconds
+	totals := u.totals()
+	g.Expect(totals.capacity).To(Equal(60 * time.Second))
+	g.Expect(totals.utilization()).To(Equal(0.25))
+	g.Expect(totals.perWorker()).To(Equal([]float64{0.75, 0, 0, 0}))
+	// a freed slot is taken again by the next worker
+	g.Expect(u.join()).To(Equal(0))
+
+	// the busy time leaves the window
+	fakeClock.Step(2 * utilizationWindow)
+	g.Expect(u.totals().utilization()).To(BeZero())
+	// while the capacity covers the whole window, less the expired part of the oldest bucket
+	g.Expect(u.totals().capacity).To(BeNumerically(">=", 4*(utilizationWindow-utilizationWindow/rollingBuckets)))
+	g.Expect(u.totals().capacity).To(BeNumerically("<=", 4*utilizationWindow))
+}
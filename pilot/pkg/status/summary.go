@@ -0,0 +1,116 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WithSummaryLog logs a one-line summary of the state of the pool at info level every interval, as a cheap way to
+// follow its health without a metrics stack.  Summaries are logged from Run until its context is cancelled.  Zero, the
+// default, disables the summary.
+func WithSummaryLog(interval time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.summaryInterval = interval
+	}
+}
+
+// poolSummary is the state of the pool reported in a summary log line.
+type poolSummary struct {
+	queued    int
+	inFlight  int
+	workers   uint
+	processed int
+	skipped   int
+	// age of the longest queued target
+	oldestPending time.Duration
+}
+
+func (s poolSummary) String() string {
+	return fmt.Sprintf("status workers: %d queued, %d in flight, %d workers, %d processed and %d skipped since last "+
+		"summary, oldest pending for %v", s.queued, s.inFlight, s.workers, s.processed, s.skipped, s.oldestPending)
+}
+
+// countProcessed counts a processed task for the summary.  Tasks which did not result in a write are counted as
+// skipped.
+func (wp *WorkerPool) countProcessed(outcome OutcomeType) {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	wp.processed++
+	switch outcome {
+	case OutcomeNotFound, OutcomeGenerationMismatch, OutcomeDeferred:
+		wp.skipped++
+	}
+}
+
+// takeCounts returns the tasks processed and skipped since it was last called.
+func (wp *WorkerPool) takeCounts() (processed, skipped int) {
+	for _, shard := range wp.shards {
+		p, s := shard.takeCounts()
+		processed += p
+		skipped += s
+	}
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	processed += wp.processed
+	skipped += wp.skipped
+	wp.processed, wp.skipped = 0, 0
+	return processed, skipped
+}
+
+// oldestPending returns when the longest queued target was first pushed, or the zero time if nothing is queued.
+func (wp *WorkerPool) oldestPending() time.Time {
+	if wp.shards == nil {
+		return wp.q.oldestPending()
+	}
+	var oldest time.Time
+	for _, shard := range wp.shards {
+		if o := shard.oldestPending(); !o.IsZero() && (oldest.IsZero() || o.Before(oldest)) {
+			oldest = o
+		}
+	}
+	return oldest
+}
+
+// summary reports the state of the pool, and resets the counts of processed and skipped tasks.
+func (wp *WorkerPool) summary() poolSummary {
+	stats := wp.Stats()
+	s := poolSummary{
+		queued:   stats.Queued,
+		inFlight: stats.InFlight,
+		workers:  stats.Workers,
+	}
+	s.processed, s.skipped = wp.takeCounts()
+	if oldest := wp.oldestPending(); !oldest.IsZero() {
+		s.oldestPending = wp.clock.Since(oldest)
+	}
+	return s
+}
+
+// logSummaries logs a summary every summaryInterval until ctx is done.
+func (wp *WorkerPool) logSummaries(ctx context.Context) {
+	for {
+		t := wp.clock.NewTimer(wp.summaryInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C():
+		}
+		scope.Info(wp.summary().String())
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestSummaryLogStopsOnCancel(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	wp := NewWorkerPool(nil, nil, 0, WithSummaryLog(time.Minute), func(wp *WorkerPool) {
+		wp.clock = fakeClock
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	wp.Run(ctx)
+	g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+	// the summary is logged, and the next one scheduled
+	fakeClock.Step(time.Minute)
+	g.Eventually(fakeClock.HasWaiters).Should(BeTrue())
+
+	cancel()
+	g.Eventually(fakeClock.HasWaiters).Should(BeFalse())
+}
+
+func TestSummary(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	wp := NewWorkerPool(nil, nil, 0, func(wp *WorkerPool) {
+		wp.clock = fakeClock
+	})
+	wp.Push(outcomeTarget("a"), &Controller{}, nil)
+	fakeClock.Step(time.Second)
+	wp.Push(outcomeTarget("b"), &Controller{}, nil)
+	fakeClock.Step(time.Second)
+	wp.countProcessed(OutcomeWritten)
+	wp.countProcessed(OutcomeNotFound)
+
+	g.Expect(wp.summary()).To(Equal(poolSummary{
+		queued:        2,
+		processed:     2,
+		skipped:       1,
+		oldestPending: 2 * time.Second,
+	}))
+	// counts restart with each summary
+	s := wp.summary()
+	g.Expect(s.processed).To(Equal(0))
+	g.Expect(s.skipped).To(Equal(0))
+}
@@ -0,0 +1,135 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+// stagedWrite is a status computed during a sync, to be written when the sync ends.
+type stagedWrite struct {
+	target Resource
+	cfg    *config.Config
+	stored *v1alpha1.IstioStatus
+	x      GenerationProvider
+	// the identities of the controllers which computed x
+	controllers []string
+	// the claim on target taken by EndSync while the staged status is written
+	claim uint64
+	// a copy of the progress x was computed from, and whether it was for an earlier generation, to requeue the target
+	// if the write is throttled
+	progress map[*Controller]interface{}
+	retained bool
+}
+
+// BeginSync starts holding status writes, for a controller about to sync many resources, until the matching EndSync.
+// While any sync is in progress, workers stage the status they compute rather than writing it, whether or not the
+// target was pushed by the syncing controller; a target processed again replaces its staged status.  Syncs may
+// overlap, in which case writes are held until all have ended.  Final statuses of deleted targets, and writes by
+// ProcessWithController and ReplayLast, are not held.  Staged statuses are lost if the pool stops before the sync ends.
+func (wp *WorkerPool) BeginSync() {
+	for _, shard := range wp.shards {
+		shard.BeginSync()
+	}
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	wp.syncs++
+}
+
+// EndSync ends a sync started by BeginSync.  When no sync remains in progress, it writes the staged statuses in the
+// order they were first staged, or in groups if WithGroupedWrites is set, before returning.  Workers do not process
+// the staged targets until they are written.  A target whose write is throttled is requeued with the progress its
+// staged status was computed from, as it would be by a worker.
+func (wp *WorkerPool) EndSync() {
+	for _, shard := range wp.shards {
+		shard.EndSync()
+	}
+	wp.lock.Lock()
+	if wp.syncs == 0 {
+		wp.lock.Unlock()
+		return
+	}
+	wp.syncs--
+	if wp.syncs > 0 {
+		wp.lock.Unlock()
+		return
+	}
+	// a worker still processing a staged target writes its newer status itself, and drops the staged one
+	for wp.workingOnStaged() {
+		wp.cond.Wait()
+	}
+	staged := make([]stagedWrite, 0, len(wp.stagedOrder))
+	for _, key := range wp.stagedOrder {
+		if s, ok := wp.staged[key]; ok {
+			s.claim = wp.claim(s.target, false)
+			staged = append(staged, s)
+		}
+	}
+	wp.staged, wp.stagedOrder = nil, nil
+	wp.lock.Unlock()
+	wp.writeStaged(staged)
+}
+
+// workingOnStaged reports whether a staged target is being processed.  The caller must hold wp.lock.
+func (wp *WorkerPool) workingOnStaged() bool {
+	for key := range wp.staged {
+		if _, working := wp.currentlyWorking[key]; working {
+			return true
+		}
+	}
+	return false
+}
+
+// dropStaged drops any status staged for target, which is superseded by a write.
+func (wp *WorkerPool) dropStaged(target Resource) {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	delete(wp.staged, convert(target))
+}
+
+// stage holds the status computed for target if a sync is in progress, reporting whether it did.  Otherwise any status
+// staged for target is dropped, as it is superseded by the status about to be written.
+func (wp *WorkerPool) stage(target Resource, cfg *config.Config, stored *v1alpha1.IstioStatus, x GenerationProvider,
+	controllers []string, perControllerWork map[*Controller]interface{}, retained bool) bool {
+	key := convert(target)
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	if wp.syncs == 0 {
+		delete(wp.staged, key)
+		return false
+	}
+	if wp.staged == nil {
+		wp.staged = make(map[lockResource]stagedWrite)
+	}
+	if _, ok := wp.staged[key]; !ok {
+		wp.stagedOrder = append(wp.stagedOrder, key)
+	}
+	// the worker releases perControllerWork once it has processed the target
+	progress := make(map[*Controller]interface{}, len(perControllerWork))
+	for c, p := range perControllerWork {
+		progress[c] = p
+	}
+	wp.staged[key] = stagedWrite{
+		target:      target,
+		cfg:         cfg,
+		stored:      stored,
+		x:           x,
+		controllers: controllers,
+		progress:    progress,
+		retained:    retained,
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolSync(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	staged := func(name string) func() OutcomeType {
+		return func() OutcomeType {
+			outcome, _ := wp.LastOutcome(outcomeTarget(name))
+			return outcome.Type
+		}
+	}
+
+	wp.BeginSync()
+	// overlapping syncs hold writes until both have ended
+	wp.BeginSync()
+	for i := 0; i < 3; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), ctl, nil)
+		g.Eventually(staged(strconv.Itoa(i))).Should(Equal(OutcomeStaged))
+	}
+	// processing a target again replaces its staged status, without changing its place
+	wp.Push(outcomeTarget("0"), ctl, nil)
+	g.Eventually(func() int { return wp.Stats().Queued }).Should(BeZero())
+	wp.EndSync()
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+
+	wp.EndSync()
+	for _, want := range []string{"0", "1", "2"} {
+		g.Expect(written).To(Receive(Equal(want)))
+	}
+	g.Expect(written).NotTo(Receive())
+	g.Expect(staged("0")()).To(Equal(OutcomeWritten))
+
+	// outside a sync, writes happen as usual
+	wp.Push(outcomeTarget("3"), ctl, nil)
+	g.Eventually(written).Should(Receive(Equal("3")))
+	// an unmatched EndSync does nothing
+	wp.EndSync()
+}
+
+func TestWorkerPoolSyncThrottled(t *testing.T) {
+	g := NewGomegaWithT(t)
+	errThrottled := errors.New("throttled")
+	var throttle int32 = 1
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		if cfg.Name == "a" && atomic.CompareAndSwapInt32(&throttle, 1, 0) {
+			return false, errThrottled
+		}
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithThrottling(func(err error) (time.Duration, bool) {
+		return 10 * time.Millisecond, err == errThrottled
+	}, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	wp.BeginSync()
+	for _, name := range []string{"a", "b"} {
+		wp.Push(outcomeTarget(name), ctl, nil)
+		name := name
+		g.Eventually(func() OutcomeType {
+			outcome, _ := wp.LastOutcome(outcomeTarget(name))
+			return outcome.Type
+		}).Should(Equal(OutcomeStaged))
+	}
+	// the throttled staged write is requeued with its progress, and written once the pause ends
+	wp.EndSync()
+	g.Expect(written).To(Receive(Equal("b")))
+	g.Eventually(written).Should(Receive(Equal("a")))
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+}
@@ -0,0 +1,77 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultThrottlePause is how long writes are paused after a throttled write which does not say when to retry.
+const defaultThrottlePause = time.Second
+
+// ThrottleFunc reports whether err, returned by a write, means the store is throttling writes, and if so how long to
+// wait before writing again.  A zero retryAfter pauses for the default of the pool.
+type ThrottleFunc func(err error) (retryAfter time.Duration, throttled bool)
+
+// APIServerThrottled recognizes the Too Many Requests errors of the Kubernetes API server, honoring Retry-After.
+func APIServerThrottled(err error) (time.Duration, bool) {
+	if !errors.IsTooManyRequests(err) {
+		return 0, false
+	}
+	seconds, _ := errors.SuggestsClientDelay(err)
+	return time.Duration(seconds) * time.Second, true
+}
+
+// WithThrottling pauses workers after a write fails with an error classified as throttled by throttled, so that a
+// store under pressure is not made worse by further writes.  Workers write nothing more until the Retry-After of the
+// error, or defaultPause if it has none, has elapsed; a defaultPause of zero pauses for one second.  Targets keep
+// being queued meanwhile.  The target whose write was throttled is requeued, to be written once the pause ends,
+// including when it was staged for EndSync.  Writes by EndSync, ProcessWithController and ReplayLast are not paused,
+// and a throttled write by ProcessWithController or ReplayLast is not retried, though it pauses the workers.  A
+// sharded pool pauses only the shard which saw the throttled write.
+func WithThrottling(throttled ThrottleFunc, defaultPause time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		if defaultPause <= 0 {
+			defaultPause = defaultThrottlePause
+		}
+		wp.throttled = throttled
+		wp.throttlePause = defaultPause
+	}
+}
+
+// pauseIfThrottled pauses the workers if err is classified as throttled, and reports whether it was.
+func (wp *WorkerPool) pauseIfThrottled(target Resource, err error) bool {
+	if err == nil || wp.throttled == nil {
+		return false
+	}
+	retryAfter, throttled := wp.throttled(err)
+	if !throttled {
+		return false
+	}
+	if retryAfter <= 0 {
+		retryAfter = wp.throttlePause
+	}
+	throttledWrites.Increment()
+	until := wp.clock.Now().Add(retryAfter)
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	if until.After(wp.pausedUntil) {
+		scope.Warnf("writing status for %s was throttled, pausing writes for %v: %v", target, retryAfter, err)
+		wp.pausedUntil = until
+	}
+	return true
+}
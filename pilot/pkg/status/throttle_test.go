@@ -0,0 +1,114 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestAPIServerThrottled(t *testing.T) {
+	g := NewGomegaWithT(t)
+	retryAfter, throttled := APIServerThrottled(apierrors.NewTooManyRequests("slow down", 5))
+	g.Expect(throttled).To(BeTrue())
+	g.Expect(retryAfter).To(Equal(5 * time.Second))
+	_, throttled = APIServerThrottled(errors.New("conflict"))
+	g.Expect(throttled).To(BeFalse())
+}
+
+func TestWorkerPoolThrottling(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	var throttle int32 = 1
+	attempts := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		attempts <- cfg.Name
+		if atomic.CompareAndSwapInt32(&throttle, 1, 0) {
+			return false, apierrors.NewTooManyRequests("slow down", 5)
+		}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithThrottling(APIServerThrottled, time.Second), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	g.Eventually(attempts).Should(Receive(Equal("a")))
+	// neither the throttled target nor a newly pushed one is written until the Retry-After has elapsed
+	wp.Push(outcomeTarget("b"), ctl, nil)
+	g.Consistently(attempts, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(wp.Stats().Queued).To(Equal(2))
+
+	fakeClock.Step(5 * time.Second)
+	var written []string
+	for i := 0; i < 2; i++ {
+		var name string
+		g.Eventually(attempts).Should(Receive(&name))
+		written = append(written, name)
+	}
+	g.Expect(written).To(ConsistOf("a", "b"))
+	outcome, _ := wp.LastOutcome(outcomeTarget("a"))
+	g.Expect(outcome.Type).To(Equal(OutcomeWritten))
+}
+
+func TestWorkerPoolThrottlingDefaultPause(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	var throttle int32 = 1
+	attempts := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		attempts <- cfg.Name
+		if atomic.CompareAndSwapInt32(&throttle, 1, 0) {
+			// no Retry-After
+			return false, apierrors.NewTooManyRequests("slow down", 0)
+		}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithThrottling(APIServerThrottled, 0), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	g.Eventually(attempts).Should(Receive(Equal("a")))
+	// a zero pause falls back to the default rather than retrying the throttled write at once
+	g.Consistently(attempts, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(wp.Stats().Queued).To(Equal(1))
+
+	fakeClock.Step(defaultThrottlePause)
+	g.Eventually(attempts).Should(Receive(Equal("a")))
+	g.Eventually(func() OutcomeType {
+		o, _ := wp.LastOutcome(outcomeTarget("a"))
+		return o.Type
+	}).Should(Equal(OutcomeWritten))
+}
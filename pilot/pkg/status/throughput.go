@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+const defaultThroughputWindow = time.Minute
+
+// WithThroughputWindow sets the rolling window over which Throughput is computed.  The default is one minute.
+func WithThroughputWindow(window time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.throughput.window = window
+	}
+}
+
+// Throughput returns the rate of successful status writes per second over the throughput window, or since the pool
+// was created if that is more recent.  Writes are counted in buckets of a tenth of the window, so a write stops being
+// counted up to a tenth of the window early.
+func (wp *WorkerPool) Throughput() float64 {
+	writes := wp.throughput.count()
+	for _, shard := range wp.shards {
+		writes += shard.throughput.count()
+	}
+	elapsed := wp.clock.Since(wp.throughput.created)
+	if elapsed > wp.throughput.window {
+		elapsed = wp.throughput.window
+	}
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(writes) / elapsed.Seconds()
+}
+
+// throughputTracker maintains a rolling count of writes over the window.
+type throughputTracker struct {
+	window  time.Duration
+	clock   clock.PassiveClock
+	created time.Time
+	lock    sync.Mutex
+	writes  rollingCounter
+}
+
+func newThroughputTracker() *throughputTracker {
+	return &throughputTracker{
+		window: defaultThroughputWindow,
+		clock:  clock.RealClock{},
+	}
+}
+
+func (t *throughputTracker) record() {
+	now := t.clock.Now()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.writes.add(now, t.window, 1)
+}
+
+func (t *throughputTracker) count() int {
+	now := t.clock.Now()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return int(t.writes.total(now, t.window))
+}
@@ -0,0 +1,64 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolThroughput(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	written := make(chan struct{}, 20)
+	wp := NewWorkerPool(func(*config.Config, interface{}) (bool, error) {
+		written <- struct{}{}
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2, WithThroughputWindow(10*time.Second), func(wp *WorkerPool) {
+		wp.clock = fakeClock
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	g.Expect(wp.Throughput()).To(BeZero())
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	for i := 0; i < 20; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), ctl, nil)
+	}
+	for i := 0; i < 20; i++ {
+		g.Eventually(written).Should(Receive())
+	}
+	g.Eventually(func() int { return wp.throughput.count() }).Should(Equal(20))
+
+	// until the pool is as old as the window, the rate is over its lifetime
+	fakeClock.Step(5 * time.Second)
+	g.Expect(wp.Throughput()).To(Equal(4.0))
+	fakeClock.Step(3 * time.Second)
+	g.Expect(wp.Throughput()).To(Equal(2.5))
+	// then over the window, which the writes eventually leave
+	fakeClock.Step(5 * time.Second)
+	g.Expect(wp.Throughput()).To(BeZero())
+}
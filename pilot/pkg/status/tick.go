@@ -0,0 +1,83 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"time"
+)
+
+// WithTickedProcessing processes the queue in batches on a fixed cadence, for predictable write timing, rather than
+// continuously.  Workers only claim targets after a tick, and each tick processes up to batch targets which are ready
+// to be processed at the time; a tick's unused allowance lapses once no target is ready.  Ticks come from Tick and, if
+// interval is non-zero, every interval from Run.  In a sharded pool each shard processes up to batch targets per tick.
+// A batch of zero, the default, processes continuously.
+func WithTickedProcessing(batch int, interval time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.tickBatch = batch
+		wp.tickInterval = interval
+	}
+}
+
+// Tick allows the workers of a pool in ticked processing mode to process up to the batch of targets ready now.  It
+// does nothing if the pool processes continuously.
+func (wp *WorkerPool) Tick() {
+	if next := wp.forwardTo(); next != nil {
+		next.Tick()
+		return
+	}
+	for _, shard := range wp.shards {
+		shard.Tick()
+	}
+	if wp.tickBatch <= 0 || wp.shards != nil {
+		return
+	}
+	wp.lock.Lock()
+	if wp.q.Length() > 0 {
+		// with nothing queued, the allowance would only let the next push skip the cadence
+		wp.tickBudget = wp.tickBatch
+		wp.idle.Broadcast()
+	}
+	wp.lock.Unlock()
+	for i := 0; i < wp.tickBatch; i++ {
+		wp.maybeAddWorker()
+	}
+}
+
+// tickEvery ticks the pool every tickInterval until ctx is done.
+func (wp *WorkerPool) tickEvery(ctx context.Context) {
+	for {
+		t := wp.clock.NewTimer(wp.tickInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C():
+		}
+		wp.Tick()
+	}
+}
+
+// awaitingTick reports whether workers must wait for a tick before claiming a target.  The caller must hold wp.lock.
+func (wp *WorkerPool) awaitingTick() bool {
+	return wp.tickBatch > 0 && wp.tickBudget <= 0
+}
+
+// spendTick counts n targets claimed against the allowance of the current tick.  The caller must hold wp.lock.
+func (wp *WorkerPool) spendTick(n int) {
+	if wp.tickBatch > 0 {
+		wp.tickBudget -= n
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolTickedProcessing(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 3, WithTickedProcessing(2, time.Second), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	expectWrites := func(n int) {
+		for i := 0; i < n; i++ {
+			g.Eventually(written).Should(Receive())
+		}
+		g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	}
+
+	for i := 0; i < 5; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), ctl, nil)
+	}
+	// nothing is processed between ticks
+	expectWrites(0)
+	wp.Tick()
+	expectWrites(2)
+	wp.Tick()
+	expectWrites(2)
+	// the internal ticker ticks too
+	fakeClock.Step(time.Second)
+	expectWrites(1)
+
+	// the allowance of a tick lapses once no target is ready
+	wp.Tick()
+	wp.Push(outcomeTarget("late"), ctl, nil)
+	expectWrites(0)
+	wp.Tick()
+	expectWrites(1)
+}
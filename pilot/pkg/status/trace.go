@@ -0,0 +1,144 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// OperationType is the kind of an Operation recorded by WithOperationTrace.
+type OperationType int
+
+const (
+	// OperationPush means the target was pushed.
+	OperationPush OperationType = iota
+	// OperationPop means a worker took the target from the queue.
+	OperationPop
+	// OperationWrite means status was written, or failed to be written, for the target.
+	OperationWrite
+	// OperationSkip means the target was processed without writing status.
+	OperationSkip
+	// OperationDelete means the target was deleted.
+	OperationDelete
+)
+
+func (o OperationType) String() string {
+	switch o {
+	case OperationPush:
+		return "push"
+	case OperationPop:
+		return "pop"
+	case OperationWrite:
+		return "write"
+	case OperationSkip:
+		return "skip"
+	case OperationDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// Operation is something which happened to a target, recorded by WithOperationTrace.
+type Operation struct {
+	Type   OperationType
+	Target Resource
+	Time   time.Time
+	// Detail is the outcome of the operation, if any, such as "deduped" for a push or "generation mismatch" for a skip.
+	Detail string
+}
+
+// operationFor returns the operation an outcome results from.
+func operationFor(outcome OutcomeType) OperationType {
+	switch outcome {
+	case OutcomeQueued, OutcomeDeduped:
+		return OperationPush
+	case OutcomeDeleted:
+		return OperationDelete
+	case OutcomeNotFound, OutcomeGenerationMismatch, OutcomeDeferred, OutcomeInvalid, OutcomeEmpty:
+		return OperationSkip
+	}
+	return OperationWrite
+}
+
+// WithOperationTrace keeps the last size operations on targets in a ring buffer, so that the sequence of events
+// leading to a problem can be retrieved with RecentOperations, or from DumpJSON, without external tracing.  Recording
+// does not take any lock.  By default no operations are kept.
+func WithOperationTrace(size int) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		if size <= 0 {
+			wp.trace = nil
+		} else {
+			wp.trace = newOperationTrace(size)
+		}
+		wp.outcomes.trace = wp.trace
+	}
+}
+
+// RecentOperations returns the operations kept by WithOperationTrace, oldest first.  Operations recorded concurrently
+// with the call may be missing.
+func (wp *WorkerPool) RecentOperations() []Operation {
+	return wp.trace.recent()
+}
+
+// tracedOperation is an operation in the ring, with its sequence number so that overwritten slots can be detected.
+type tracedOperation struct {
+	seq uint64
+	op  Operation
+}
+
+// operationTrace is a fixed size ring of operations.  Writers claim a slot by incrementing next, and store the
+// operation atomically, so recording never blocks.  A nil trace records nothing.
+type operationTrace struct {
+	// first, to be 64-bit aligned for atomic access
+	next  uint64
+	slots []atomic.Value
+}
+
+func newOperationTrace(size int) *operationTrace {
+	return &operationTrace{slots: make([]atomic.Value, size)}
+}
+
+func (t *operationTrace) record(typ OperationType, target Resource, at time.Time, detail string) {
+	if t == nil {
+		return
+	}
+	seq := atomic.AddUint64(&t.next, 1) - 1
+	t.slots[seq%uint64(len(t.slots))].Store(tracedOperation{
+		seq: seq,
+		op:  Operation{Type: typ, Target: target, Time: at, Detail: detail},
+	})
+}
+
+func (t *operationTrace) recent() []Operation {
+	if t == nil {
+		return nil
+	}
+	end := atomic.LoadUint64(&t.next)
+	var start uint64
+	if size := uint64(len(t.slots)); end > size {
+		start = end - size
+	}
+	ops := make([]Operation, 0, end-start)
+	for seq := start; seq < end; seq++ {
+		traced, ok := t.slots[seq%uint64(len(t.slots))].Load().(tracedOperation)
+		if !ok || traced.seq != seq {
+			// not yet stored, or already overwritten
+			continue
+		}
+		ops = append(ops, traced.op)
+	}
+	return ops
+}
@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolOperationTrace(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	written := make(chan string, 2)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithOperationTrace(4), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g.Expect(wp.RecentOperations()).To(BeEmpty())
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	// the oldest operation is overwritten once the ring is full
+	wp.Push(outcomeTarget("a"), ctl, nil)
+	wp.Push(outcomeTarget("b"), ctl, nil)
+	wp.Push(outcomeTarget("b"), ctl, nil)
+	wp.Run(ctx)
+	g.Eventually(written).Should(Receive(Equal("a")))
+	g.Eventually(written).Should(Receive(Equal("b")))
+	wp.Delete(outcomeTarget("b"))
+	summary := func(ops []Operation) []string {
+		var out []string
+		for _, op := range ops {
+			g.Expect(op.Time).To(Equal(fakeClock.Now()))
+			out = append(out, op.Type.String()+" "+op.Target.Name+" "+op.Detail)
+		}
+		return out
+	}
+	g.Eventually(func() []string { return summary(wp.RecentOperations()) }).Should(Equal([]string{
+		"write a written", "pop b ", "write b written", "delete b deleted",
+	}))
+
+	var dump QueueDump
+	var buf bytes.Buffer
+	g.Expect(wp.DumpJSON(&buf)).To(Succeed())
+	g.Expect(json.Unmarshal(buf.Bytes(), &dump)).To(Succeed())
+	g.Expect(dump.Operations).To(HaveLen(4))
+	g.Expect(dump.Operations[3].Operation).To(Equal("delete"))
+	g.Expect(dump.Operations[3].Name).To(Equal("b"))
+}
+
+func TestOperationTraceOrder(t *testing.T) {
+	g := NewGomegaWithT(t)
+	trace := newOperationTrace(3)
+	now := time.Now()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		trace.record(OperationPush, outcomeTarget(name), now, "")
+	}
+	var names []string
+	for _, op := range trace.recent() {
+		names = append(names, op.Target.Name)
+	}
+	g.Expect(names).To(Equal([]string{"c", "d", "e"}))
+	g.Expect((*operationTrace)(nil).recent()).To(BeNil())
+}
@@ -0,0 +1,203 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+// Transaction groups the status updates of several targets, so that status is written for all of them or none.  The
+// pool computes the status of each target pushed in the transaction as usual, but holds it rather than writing it.
+// When the transaction is committed, once the status of every target has been computed, it is all written; if the
+// status of any target cannot be computed, because the target was deleted, not found, of another generation, given
+// invalid status or left with no progress, every held status is discarded instead.  Only the computation is atomic:
+// a write which fails at commit does not undo the writes which succeeded.
+type Transaction struct {
+	pool *WorkerPool
+	lock sync.Mutex
+	// broadcast when a target's status is held or cannot be computed
+	cond *sync.Cond
+	// the pool, or shard, processing each target in the transaction, in the order they were first pushed
+	targets map[lockResource]*WorkerPool
+	order   []lockResource
+	held    map[lockResource]stagedWrite
+	// set once a target's status cannot be computed
+	err error
+	// set once the transaction has been committed
+	ended bool
+}
+
+// BeginTransaction starts a Transaction on the pool.
+func (wp *WorkerPool) BeginTransaction() *Transaction {
+	tx := &Transaction{
+		pool:    wp,
+		targets: make(map[lockResource]*WorkerPool),
+		held:    make(map[lockResource]stagedWrite),
+	}
+	tx.cond = sync.NewCond(&tx.lock)
+	return tx
+}
+
+// Push is WorkerPool.Push, adding target to the transaction.  A target may only be in one open transaction at a time.
+// It returns an error if target is in another open transaction, or the transaction has been committed.
+func (tx *Transaction) Push(target Resource, controller *Controller, progress interface{}) error {
+	key := convert(target)
+	owner := tx.pool
+	if owner.shards != nil {
+		owner = owner.shard(target)
+	}
+	tx.lock.Lock()
+	if tx.ended {
+		tx.lock.Unlock()
+		return errors.New("cannot push to a transaction which has been committed")
+	}
+	if _, ok := tx.targets[key]; !ok {
+		owner.lock.Lock()
+		if other, ok := owner.transactions[key]; ok && other != tx {
+			owner.lock.Unlock()
+			tx.lock.Unlock()
+			return fmt.Errorf("%s is in another open transaction", target)
+		}
+		if owner.transactions == nil {
+			owner.transactions = make(map[lockResource]*Transaction)
+		}
+		owner.transactions[key] = tx
+		owner.lock.Unlock()
+		tx.targets[key] = owner
+		tx.order = append(tx.order, key)
+	}
+	tx.lock.Unlock()
+	tx.pool.Push(target, controller, progress)
+	return nil
+}
+
+// Commit ends the transaction.  It waits until the status of every target in the transaction has been computed, then
+// writes it, returning the first write error.  If the status of any target cannot be computed, or ctx is done first,
+// the held statuses are discarded and the error is returned; targets still queued when ctx is done are then processed
+// as if they had been pushed outside the transaction.
+func (tx *Transaction) Commit(ctx context.Context) error {
+	tx.lock.Lock()
+	if tx.ended {
+		tx.lock.Unlock()
+		return errors.New("transaction has already been committed")
+	}
+	tx.ended = true
+	err := waitCond(ctx, tx.cond, func() bool {
+		return tx.err != nil || len(tx.held) >= len(tx.targets)
+	})
+	if tx.err != nil {
+		err = tx.err
+	}
+	held := make([]stagedWrite, 0, len(tx.held))
+	owners := make([]*WorkerPool, 0, len(tx.held))
+	for _, key := range tx.order {
+		if s, ok := tx.held[key]; ok {
+			held = append(held, s)
+			owners = append(owners, tx.targets[key])
+		}
+	}
+	tx.held = nil
+	tx.lock.Unlock()
+	tx.release()
+	if err != nil {
+		scope.Infof("rolling back status transaction of %d targets: %v", len(tx.order), err)
+		return fmt.Errorf("transaction rolled back: %v", err)
+	}
+	var firstErr error
+	for i, s := range held {
+		if err := owners[i].writeHeld(s); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// release removes the targets of the transaction from their pools, so that they are processed as usual.
+func (tx *Transaction) release() {
+	for key, owner := range tx.targets {
+		owner.lock.Lock()
+		if owner.transactions[key] == tx {
+			delete(owner.transactions, key)
+		}
+		owner.lock.Unlock()
+	}
+}
+
+// transactionOf returns the open transaction target is in, if any.
+func (wp *WorkerPool) transactionOf(target Resource) *Transaction {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	return wp.transactions[convert(target)]
+}
+
+// stageForTransaction holds the status computed for target if it is in an open transaction, reporting whether it did.
+// A target processed again replaces its held status.
+func (wp *WorkerPool) stageForTransaction(target Resource, cfg *config.Config, stored *v1alpha1.IstioStatus,
+	x GenerationProvider, controllers []string) bool {
+	tx := wp.transactionOf(target)
+	if tx == nil {
+		return false
+	}
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+	if tx.held == nil {
+		// the transaction was committed while the status was being computed
+		return false
+	}
+	tx.held[convert(target)] = stagedWrite{target: target, cfg: cfg, stored: stored, x: x, controllers: controllers}
+	tx.cond.Broadcast()
+	return true
+}
+
+// dropped records that the task for target was dropped without writing, for reason, failing its transaction if it is
+// in one.
+func (wp *WorkerPool) dropped(target Resource, reason OutcomeType) {
+	wp.waste.dropped(target)
+	tx := wp.transactionOf(target)
+	if tx == nil {
+		return
+	}
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+	if tx.err == nil {
+		tx.err = fmt.Errorf("status of %s could not be computed: %v", target, reason)
+	}
+	tx.cond.Broadcast()
+}
+
+// writeHeld writes the status held for a target in a committed transaction, once no worker is processing the target.
+func (wp *WorkerPool) writeHeld(s stagedWrite) error {
+	key := convert(s.target)
+	wp.lock.Lock()
+	for {
+		if _, working := wp.currentlyWorking[key]; !working {
+			break
+		}
+		wp.cond.Wait()
+	}
+	claim := wp.claim(s.target, false)
+	wp.lock.Unlock()
+	defer wp.finish(s.target, claim)
+	wp.exclusivity.begin(s.target)
+	defer wp.exclusivity.end(s.target)
+	_, err := wp.writeStatus(wp.writer(s.target), s.target, s.cfg, s.stored, s.x, s.controllers, wp.clock.Now(), false)
+	return err
+}
@@ -0,0 +1,91 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolTransaction(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		if r.Name == "missing" {
+			return nil
+		}
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	outcome := func(name string) func() OutcomeType {
+		return func() OutcomeType {
+			o, _ := wp.LastOutcome(outcomeTarget(name))
+			return o.Type
+		}
+	}
+
+	tx := wp.BeginTransaction()
+	g.Expect(tx.Push(outcomeTarget("a"), ctl, nil)).To(Succeed())
+	g.Expect(tx.Push(outcomeTarget("b"), ctl, nil)).To(Succeed())
+	g.Eventually(outcome("b")).Should(Equal(OutcomeStaged))
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	// a target may only be in one open transaction
+	g.Expect(wp.BeginTransaction().Push(outcomeTarget("a"), ctl, nil)).NotTo(Succeed())
+	g.Expect(tx.Commit(ctx)).To(Succeed())
+	g.Expect(written).To(Receive(Equal("a")))
+	g.Expect(written).To(Receive(Equal("b")))
+	g.Expect(outcome("a")()).To(Equal(OutcomeWritten))
+	g.Expect(tx.Push(outcomeTarget("c"), ctl, nil)).NotTo(Succeed())
+	g.Expect(tx.Commit(ctx)).NotTo(Succeed())
+
+	// a target whose status cannot be computed rolls back the whole transaction
+	tx = wp.BeginTransaction()
+	g.Expect(tx.Push(outcomeTarget("c"), ctl, nil)).To(Succeed())
+	g.Expect(tx.Push(outcomeTarget("missing"), ctl, nil)).To(Succeed())
+	g.Expect(tx.Commit(ctx)).NotTo(Succeed())
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(outcome("missing")()).To(Equal(OutcomeNotFound))
+
+	// once the transaction has ended, its targets are written as usual
+	wp.Push(outcomeTarget("c"), ctl, nil)
+	g.Eventually(written).Should(Receive(Equal("c")))
+
+	// a commit gives up when its context is done
+	tx = wp.BeginTransaction()
+	wp.Hold(outcomeTarget("d"))
+	g.Expect(tx.Push(outcomeTarget("d"), ctl, nil)).To(Succeed())
+	g.Expect(tx.Push(outcomeTarget("e"), ctl, nil)).To(Succeed())
+	g.Eventually(outcome("e")).Should(Equal(OutcomeStaged))
+	short, stop := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer stop()
+	g.Expect(tx.Commit(short)).NotTo(Succeed())
+	// the held status of e is discarded, while d, still queued, is processed as usual
+	wp.Release(outcomeTarget("d"))
+	g.Eventually(written).Should(Receive(Equal("d")))
+	g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+}
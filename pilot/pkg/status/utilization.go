@@ -0,0 +1,191 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+const utilizationWindow = 5 * time.Minute
+
+// utilizationTracker maintains rolling totals over the window of the time workers spent processing targets, overall and
+// per worker slot, and of the capacity of the pool: the worker limit integrated over wall time.  Each worker takes the
+// lowest free slot when it starts and frees it when it exits, so that busy slots stay at the bottom.
+type utilizationTracker struct {
+	clock clock.PassiveClock
+	lock  sync.Mutex
+	busy  rollingCounter
+	slots []rollingCounter
+	taken []bool
+	// wall time and capacity are accounted up to accounted, at the worker limit in force since then
+	elapsed   rollingCounter
+	capacity  rollingCounter
+	accounted time.Time
+	limit     uint
+}
+
+func newUtilizationTracker() *utilizationTracker {
+	return &utilizationTracker{clock: clock.RealClock{}}
+}
+
+// setLimit records that the worker limit changed to n.  The first call starts accounting for capacity.
+func (u *utilizationTracker) setLimit(n uint) {
+	now := u.clock.Now()
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.account(now)
+	u.limit = n
+}
+
+// account adds the wall time since the last call, and the capacity over it, to the rolling totals.  The caller must
+// hold u.lock.
+func (u *utilizationTracker) account(now time.Time) {
+	from := u.accounted
+	u.accounted = now
+	if from.IsZero() {
+		return
+	}
+	if earliest := now.Add(-utilizationWindow); from.Before(earliest) {
+		from = earliest
+	}
+	width := utilizationWindow / rollingBuckets
+	for from.Before(now) {
+		// split the time at bucket boundaries, so that each part leaves the window with its own bucket
+		to := from.Truncate(width).Add(width)
+		if to.After(now) {
+			to = now
+		}
+		d := int64(to.Sub(from))
+		u.elapsed.add(from, utilizationWindow, d)
+		u.capacity.add(from, utilizationWindow, d*int64(u.limit))
+		from = to
+	}
+}
+
+// join takes the lowest free worker slot for a starting worker.
+func (u *utilizationTracker) join() int {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	for i, taken := range u.taken {
+		if !taken {
+			u.taken[i] = true
+			return i
+		}
+	}
+	u.taken = append(u.taken, true)
+	u.slots = append(u.slots, rollingCounter{})
+	return len(u.taken) - 1
+}
+
+// leave frees the slot of an exiting worker.
+func (u *utilizationTracker) leave(slot int) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.taken[slot] = false
+}
+
+// record adds time the worker in slot spent processing, and updates the utilization gauges.
+func (u *utilizationTracker) record(slot int, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	now := u.clock.Now()
+	u.lock.Lock()
+	u.busy.add(now, utilizationWindow, int64(d))
+	u.slots[slot].add(now, utilizationWindow, int64(d))
+	totals := u.totalsLocked(now)
+	u.lock.Unlock()
+	workerBusySeconds.Record(d.Seconds())
+	workerUtilization.Record(totals.utilization())
+	workerSlotUtilization.With(workerTag.Value(strconv.Itoa(slot))).Record(fraction(totals.slots[slot], totals.elapsed))
+}
+
+// utilizationTotals are the rolling totals of a utilizationTracker.
+type utilizationTotals struct {
+	busy     time.Duration
+	capacity time.Duration
+	elapsed  time.Duration
+	// the time spent processing by each worker slot, covering at least the worker limit
+	slots []time.Duration
+}
+
+// totals returns the rolling totals over the window.
+func (u *utilizationTracker) totals() utilizationTotals {
+	now := u.clock.Now()
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.totalsLocked(now)
+}
+
+func (u *utilizationTracker) totalsLocked(now time.Time) utilizationTotals {
+	u.account(now)
+	t := utilizationTotals{
+		busy:     time.Duration(u.busy.total(now, utilizationWindow)),
+		capacity: time.Duration(u.capacity.total(now, utilizationWindow)),
+		elapsed:  time.Duration(u.elapsed.total(now, utilizationWindow)),
+	}
+	n := len(u.slots)
+	if int(u.limit) > n {
+		n = int(u.limit)
+	}
+	t.slots = make([]time.Duration, n)
+	for i := range u.slots {
+		t.slots[i] = time.Duration(u.slots[i].total(now, utilizationWindow))
+	}
+	return t
+}
+
+// utilization returns the fraction of the capacity of the pool spent processing.
+func (t utilizationTotals) utilization() float64 {
+	return fraction(t.busy, t.capacity)
+}
+
+// perWorker returns the fraction of the time each worker slot spent processing.
+func (t utilizationTotals) perWorker() []float64 {
+	var ratios []float64
+	for _, busy := range t.slots {
+		ratios = append(ratios, fraction(busy, t.elapsed))
+	}
+	return ratios
+}
+
+// fraction returns part over whole, capped at one as a write is counted as busy time only when it ends, or zero if
+// whole is.
+func fraction(part, whole time.Duration) float64 {
+	switch {
+	case whole <= 0:
+		return 0
+	case part >= whole:
+		return 1
+	}
+	return float64(part) / float64(whole)
+}
+
+// waitForWork waits, with wp.lock held, until signaled or, if at is set, until at, counting the time spent as idle.
+func (wp *WorkerPool) waitForWork(at time.Time) {
+	start := wp.clock.Now()
+	if at.IsZero() {
+		wp.idle.Wait()
+	} else {
+		wp.waitUntil(at)
+	}
+	if d := wp.clock.Since(start); d > 0 {
+		workerIdleSeconds.Record(d.Seconds())
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"time"
+)
+
+// WithValidateBeforeWrite checks the status computed for each target, after every controller has run, before it is
+// written.  validate is passed the status as the WriteFunc would be, before any Marshaler.  A status for which
+// validate returns an error is not written: the error is logged, counted, recorded as OutcomeInvalid and passed to
+// the error hook.  If retryDelay is non-zero the target's progress is requeued, to be processed again after
+// retryDelay, otherwise it is dropped.  By default status is not validated.
+func WithValidateBeforeWrite(validate func(target Resource, status interface{}) error,
+	retryDelay time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.validateBeforeWrite = validate
+		wp.invalidRetryDelay = retryDelay
+	}
+}
+
+// validate checks x, the status computed for target, if validation is enabled.
+func (wp *WorkerPool) validate(target Resource, x GenerationProvider) error {
+	if wp.validateBeforeWrite == nil {
+		return nil
+	}
+	return wp.validateBeforeWrite(target, x)
+}
+
+// rejectInvalid records that the status computed for target failed validation with err, and requeues its progress
+// if invalid targets are retried.
+func (wp *WorkerPool) rejectInvalid(target Resource, perControllerWork map[*Controller]interface{}, retained bool,
+	err error, start time.Time) {
+	retry := wp.invalidRetryDelay > 0
+	wp.logger(target).Warnf("not writing invalid status for %s: %v", target, err)
+	invalidStatus.Increment()
+	wp.outcomes.record(target, OutcomeInvalid, err)
+	wp.sendResult(target, OutcomeInvalid, err, start)
+	if retry {
+		wp.retry(target, perControllerWork, retained, wp.invalidRetryDelay)
+	} else {
+		wp.dropped(target, OutcomeInvalid)
+	}
+	wp.reportError(target, err, retry)
+}
@@ -0,0 +1,102 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"go.opencensus.io/stats/view"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func invalidStatusCount(t *testing.T) float64 {
+	rows, err := view.RetrieveData(invalidStatus.Name())
+	if err != nil {
+		t.Fatalf("failed to get value for %s: %v", invalidStatus.Name(), err)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return rows[0].Data.(*view.SumData).Value
+}
+
+func TestWorkerPoolValidateBeforeWrite(t *testing.T) {
+	errInvalid := errors.New("observed generation not set")
+	// the status of "bad" is always invalid, and that of "flaky" is invalid once
+	var flaky int32
+	validate := func(target Resource, status interface{}) error {
+		if status.(*IstioGenerationProvider).ObservedGeneration == 0 {
+			return errInvalid
+		}
+		if target.Name == "bad" || (target.Name == "flaky" && atomic.AddInt32(&flaky, 1) == 1) {
+			return errInvalid
+		}
+		return nil
+	}
+	newPool := func(retryDelay time.Duration) (*WorkerPool, chan string, chan string) {
+		written := make(chan string, 10)
+		failures := make(chan string, 10)
+		wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+			written <- cfg.Name
+			return true, nil
+		}, func(r Resource) *config.Config {
+			return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+		}, 1, WithValidateBeforeWrite(validate, retryDelay), WithOnError(func(target Resource, err error) {
+			failures <- target.Name
+		}, ReportEveryFailure))
+		return wp, written, failures
+	}
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{}}
+	}}
+
+	t.Run("dropped", func(t *testing.T) {
+		g := NewGomegaWithT(t)
+		wp, written, failures := newPool(0)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		wp.Run(ctx)
+		before := invalidStatusCount(t)
+		wp.Push(outcomeTarget("good"), ctl, nil)
+		g.Eventually(written).Should(Receive(Equal("good")))
+		wp.Push(outcomeTarget("bad"), ctl, nil)
+		g.Eventually(failures).Should(Receive(Equal("bad")))
+		g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+		outcome, ok := wp.LastOutcome(outcomeTarget("bad"))
+		g.Expect(ok).To(BeTrue())
+		g.Expect(outcome.Type).To(Equal(OutcomeInvalid))
+		g.Expect(outcome.Err).To(Equal(errInvalid))
+		g.Expect(invalidStatusCount(t) - before).To(Equal(1.0))
+		g.Expect(wp.PendingProgress(outcomeTarget("bad"))).To(BeNil())
+	})
+
+	t.Run("retried", func(t *testing.T) {
+		g := NewGomegaWithT(t)
+		wp, written, failures := newPool(10 * time.Millisecond)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		wp.Run(ctx)
+		wp.Push(outcomeTarget("flaky"), ctl, nil)
+		g.Eventually(failures).Should(Receive(Equal("flaky")))
+		g.Eventually(written).Should(Receive(Equal("flaky")))
+	})
+}
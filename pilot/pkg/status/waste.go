@@ -0,0 +1,105 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+const defaultWasteWindow = 5 * time.Minute
+
+// WithWastedPushWindow sets the rolling window over which WastedPushRatio is computed.  The default is five minutes.
+func WithWastedPushWindow(window time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.waste.window = window
+	}
+}
+
+// WastedPushRatio returns the fraction of pushes over the window which never resulted in a write, and the number of
+// pushes it is computed from.  A push is wasted when the task it contributed to is dropped without writing: the
+// target was deleted or not found, its generation did not match, or its status failed validation.  Pushes whose
+// write was attempted are not wasted, even if the write failed.  A high ratio means controllers are pushing
+// spuriously, or config is changing faster than status can keep up.
+func (wp *WorkerPool) WastedPushRatio() (ratio float64, pushes int) {
+	wasted, pushes := wp.waste.counts()
+	for _, shard := range wp.shards {
+		w, p := shard.waste.counts()
+		wasted += w
+		pushes += p
+	}
+	if pushes == 0 {
+		return 0, 0
+	}
+	return float64(wasted) / float64(pushes), pushes
+}
+
+// wasteTracker counts the pushes to each target until they are written or dropped, and maintains rolling counts of
+// wasted and total pushes over the window.
+type wasteTracker struct {
+	window time.Duration
+	clock  clock.PassiveClock
+	lock   sync.Mutex
+	// pushes to each target since its status was last written or its task dropped
+	pending map[lockResource]int
+	wasted  rollingCounter
+	pushes  rollingCounter
+}
+
+func newWasteTracker() *wasteTracker {
+	return &wasteTracker{
+		window:  defaultWasteWindow,
+		clock:   clock.RealClock{},
+		pending: make(map[lockResource]int),
+	}
+}
+
+// pushed counts a push to target.
+func (w *wasteTracker) pushed(target Resource) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.pushes.add(w.clock.Now(), w.window, 1)
+	w.pending[convert(target)]++
+}
+
+// written records that a write was attempted for target, using the pushes made to it.
+func (w *wasteTracker) written(target Resource) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	delete(w.pending, convert(target))
+}
+
+// dropped records that the task for target was dropped without writing, wasting the pushes made to it.
+func (w *wasteTracker) dropped(target Resource) {
+	key := convert(target)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	n, ok := w.pending[key]
+	if !ok {
+		return
+	}
+	delete(w.pending, key)
+	w.wasted.add(w.clock.Now(), w.window, int64(n))
+	wastedPushes.RecordInt(int64(n))
+}
+
+func (w *wasteTracker) counts() (wasted, pushes int) {
+	now := w.clock.Now()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return int(w.wasted.total(now, w.window)), int(w.pushes.total(now, w.window))
+}
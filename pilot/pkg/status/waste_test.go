@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"go.opencensus.io/stats/view"
+
+	"istio.io/istio/pkg/config"
+)
+
+func wastedPushCount(t *testing.T) float64 {
+	rows, err := view.RetrieveData(wastedPushes.Name())
+	if err != nil {
+		t.Fatalf("failed to get value for %s: %v", wastedPushes.Name(), err)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return rows[0].Data.(*view.SumData).Value
+}
+
+func TestWorkerPoolWastedPushRatio(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg.Name
+		return true, nil
+	}, func(r Resource) *config.Config {
+		switch r.Name {
+		case "gone":
+			return nil
+		case "stale":
+			return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 2}}
+		}
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	c := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+	before := wastedPushCount(t)
+	ratio, pushes := wp.WastedPushRatio()
+	g.Expect(ratio).To(Equal(0.0))
+	g.Expect(pushes).To(Equal(0))
+
+	wp.Hold(outcomeTarget("deleted"))
+	wp.Push(outcomeTarget("deleted"), c, nil)
+	wp.Delete(outcomeTarget("deleted"))
+	wp.Push(outcomeTarget("gone"), c, nil)
+	wp.Push(outcomeTarget("stale"), c, nil)
+	// both pushes are used by a single write
+	wp.Hold(outcomeTarget("ok"))
+	wp.Push(outcomeTarget("ok"), c, nil)
+	wp.Push(outcomeTarget("ok"), c, nil)
+	wp.Release(outcomeTarget("ok"))
+	g.Eventually(written).Should(Receive(Equal("ok")))
+	g.Eventually(func() int { return wp.Stats().Queued + wp.Stats().InFlight }).Should(Equal(0))
+
+	ratio, pushes = wp.WastedPushRatio()
+	g.Expect(pushes).To(Equal(5))
+	g.Expect(ratio).To(Equal(0.6))
+	g.Expect(wastedPushCount(t) - before).To(Equal(3.0))
+}
@@ -0,0 +1,53 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+// WithQueueWatermarks notifies of the queue length crossing thresholds, for driving external autoscaling or alerting
+// without polling.  onHigh is invoked when the number of queued targets reaches high, and onLow when it then falls to
+// low, each with the queue length.  The gap between high and low is the hysteresis margin: once onHigh has been
+// invoked it is not invoked again until onLow has been, so a queue hovering around either threshold does not flap.
+// low must be less than high.  The callbacks are invoked without holding the pool's lock, so they may call back into
+// the pool.  In a sharded pool the thresholds apply to each shard.
+func WithQueueWatermarks(high, low int, onHigh, onLow func(queued int)) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.highWatermark = high
+		wp.lowWatermark = low
+		wp.onHighWatermark = onHigh
+		wp.onLowWatermark = onLow
+	}
+}
+
+// checkWatermarks invokes the watermark callbacks if the queue length has crossed a threshold since it was last
+// checked.
+func (wp *WorkerPool) checkWatermarks() {
+	if wp.highWatermark <= 0 {
+		return
+	}
+	wp.lock.Lock()
+	queued := wp.q.Length()
+	var notify func(int)
+	switch {
+	case !wp.aboveWatermark && queued >= wp.highWatermark:
+		wp.aboveWatermark = true
+		notify = wp.onHighWatermark
+	case wp.aboveWatermark && queued <= wp.lowWatermark:
+		wp.aboveWatermark = false
+		notify = wp.onLowWatermark
+	}
+	wp.lock.Unlock()
+	if notify != nil {
+		notify(queued)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolQueueWatermarks(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var lock sync.Mutex
+	var events []string
+	record := func(event string) func(int) {
+		return func(queued int) {
+			lock.Lock()
+			defer lock.Unlock()
+			events = append(events, event+" "+strconv.Itoa(queued))
+		}
+	}
+	recorded := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string(nil), events...)
+	}
+	release := make(chan struct{})
+	wp := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		<-release
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1, WithQueueWatermarks(4, 1, record("high"), record("low")))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{}
+	}}
+
+	// the worker blocks on the first target, so the rest stay queued
+	wp.Hold(outcomeTarget("0"))
+	for i := 0; i < 6; i++ {
+		wp.Push(outcomeTarget(strconv.Itoa(i)), ctl, nil)
+	}
+	g.Eventually(recorded).Should(Equal([]string{"high 4"}))
+	// hovering around the high mark does not notify again
+	wp.Delete(outcomeTarget("5"))
+	wp.Delete(outcomeTarget("4"))
+	wp.Push(outcomeTarget("4"), ctl, nil)
+	g.Expect(recorded()).To(Equal([]string{"high 4"}))
+
+	close(release)
+	g.Eventually(func() int { return wp.Stats().Queued }).Should(Equal(1))
+	g.Expect(recorded()).To(Equal([]string{"high 4", "low 1"}))
+	wp.Release(outcomeTarget("0"))
+	g.Eventually(func() int { return wp.Stats().Queued }).Should(BeZero())
+	g.Expect(recorded()).To(Equal([]string{"high 4", "low 1"}))
+}
@@ -0,0 +1,119 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// maxRetries is the number of times a key which fails to resolve, or whose status fails to be written, is requeued
+// before it is dropped.
+const maxRetries = 5
+
+// ResolveFunc maps a key from a client-go workqueue to the target resource and the progress to enqueue for it.
+type ResolveFunc func(key interface{}) (target Resource, progress interface{}, err error)
+
+// outcomeAwaiter is implemented by worker queues, such as WorkerPool, which report the outcome of processing a target.
+type outcomeAwaiter interface {
+	AwaitProcessed(ctx context.Context, target Resource) error
+	LastOutcome(target Resource) (Outcome, bool)
+}
+
+// WorkqueueAdapter drives status updates for a Controller from a client-go workqueue.
+//
+// The two queues have different retry semantics.  A key which fails to resolve is requeued with the workqueue's rate
+// limiter.  A resolved key is pushed to the status workers and, if they report outcomes as WorkerPool does, held by
+// the workqueue until the target has been processed: it is forgotten if the write succeeds or the target is deleted,
+// and requeued with the rate limiter if the last outcome is OutcomeFailed, once the workers have given up any retries
+// of their own.  Either kind of failure is retried up to maxRetries times before the key is dropped.  Pushes for a
+// target still being processed are coalesced by the workers as usual.  Workers which do not report outcomes are
+// trusted with the write, and the key is forgotten as soon as it is pushed.
+type WorkqueueAdapter struct {
+	queue      workqueue.RateLimitingInterface
+	controller *Controller
+	resolve    ResolveFunc
+}
+
+func NewWorkqueueAdapter(queue workqueue.RateLimitingInterface, controller *Controller, resolve ResolveFunc) *WorkqueueAdapter {
+	return &WorkqueueAdapter{
+		queue:      queue,
+		controller: controller,
+		resolve:    resolve,
+	}
+}
+
+// Run consumes keys from the workqueue until it is shut down.
+func (a *WorkqueueAdapter) Run(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+		a.queue.ShutDown()
+	}()
+	for a.processNext(ctx) {
+	}
+}
+
+// processNext pushes the next key from the workqueue to the status workers, returning false once the workqueue is
+// shut down.  If the workers report outcomes, the key is marked done once its target has been processed, which
+// happens in the background so that other keys are not held up.
+func (a *WorkqueueAdapter) processNext(ctx context.Context) bool {
+	key, shutdown := a.queue.Get()
+	if shutdown {
+		return false
+	}
+	target, progress, err := a.resolve(key)
+	if err != nil {
+		a.retry(key, "resolve", err)
+		a.queue.Done(key)
+		return true
+	}
+	a.controller.EnqueueStatusUpdateResource(progress, target)
+	awaiter, ok := a.controller.workers.(outcomeAwaiter)
+	if !ok {
+		a.queue.Forget(key)
+		a.queue.Done(key)
+		return true
+	}
+	go func() {
+		defer a.queue.Done(key)
+		if err := awaiter.AwaitProcessed(ctx, target); err != nil {
+			// the target was deleted, or the adapter is stopping
+			a.queue.Forget(key)
+			return
+		}
+		if outcome, ok := awaiter.LastOutcome(target); ok && outcome.Type == OutcomeFailed {
+			a.retry(key, "write status for", outcome.Err)
+			return
+		}
+		a.queue.Forget(key)
+	}()
+	return true
+}
+
+// retry requeues key with the rate limiter after it failed to action, or forgets it once it has been retried
+// maxRetries times.
+func (a *WorkqueueAdapter) retry(key interface{}, action string, err error) {
+	if a.queue.NumRequeues(key) < maxRetries {
+		scope.Debugf("failed to %s %v, retrying: %v", action, key, err)
+		a.queue.AddRateLimited(key)
+		return
+	}
+	scope.Warnf("failed to %s %v, dropping: %v", action, key, err)
+	a.queue.Forget(key)
+}
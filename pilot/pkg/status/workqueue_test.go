@@ -0,0 +1,157 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/workqueue"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func newTestWorkqueue() workqueue.RateLimitingInterface {
+	return workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond))
+}
+
+func TestWorkqueueAdapterPushes(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan *config.Config, 1)
+	workers := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		written <- cfg
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workers.Run(ctx)
+	ctl := &Controller{
+		fn: func(status interface{}, context interface{}) GenerationProvider {
+			return &IstioGenerationProvider{}
+		},
+		workers: workers,
+	}
+	queue := newTestWorkqueue()
+	adapter := NewWorkqueueAdapter(queue, ctl, func(key interface{}) (Resource, interface{}, error) {
+		return Resource{
+			GroupVersionResource: schema.GroupVersionResource{Group: "g", Version: "v", Resource: "r"},
+			Namespace:            "ns",
+			Name:                 key.(string),
+			Generation:           "1",
+		}, nil, nil
+	})
+
+	queue.Add("foo")
+	g.Expect(adapter.processNext(context.Background())).To(BeTrue())
+	g.Expect((<-written).Name).To(Equal("foo"))
+	g.Expect(queue.NumRequeues("foo")).To(Equal(0))
+	g.Expect(queue.Len()).To(Equal(0))
+}
+
+func TestWorkqueueAdapterRetriesFailedWrites(t *testing.T) {
+	g := NewGomegaWithT(t)
+	written := make(chan *config.Config, 1)
+	failures := 1
+	workers := NewWorkerPool(func(cfg *config.Config, _ interface{}) (bool, error) {
+		if failures > 0 {
+			failures--
+			return false, errors.New("conflict")
+		}
+		written <- cfg
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workers.Run(ctx)
+	ctl := &Controller{
+		fn: func(status interface{}, context interface{}) GenerationProvider {
+			return &IstioGenerationProvider{}
+		},
+		workers: workers,
+	}
+	queue := newTestWorkqueue()
+	adapter := NewWorkqueueAdapter(queue, ctl, func(key interface{}) (Resource, interface{}, error) {
+		return Resource{
+			GroupVersionResource: schema.GroupVersionResource{Group: "g", Version: "v", Resource: "r"},
+			Namespace:            "ns",
+			Name:                 key.(string),
+			Generation:           "1",
+		}, nil, nil
+	})
+
+	// the failed write requeues the key with the rate limiter
+	queue.Add("foo")
+	g.Expect(adapter.processNext(ctx)).To(BeTrue())
+	g.Eventually(queue.Len).Should(Equal(1))
+	g.Expect(queue.NumRequeues("foo")).To(Equal(1))
+
+	// the retried write succeeds, and the key is forgotten
+	g.Expect(adapter.processNext(ctx)).To(BeTrue())
+	g.Expect((<-written).Name).To(Equal("foo"))
+	g.Eventually(func() int { return queue.NumRequeues("foo") }).Should(Equal(0))
+	g.Expect(queue.Len()).To(Equal(0))
+}
+
+func TestWorkqueueAdapterRetriesResolution(t *testing.T) {
+	g := NewGomegaWithT(t)
+	queue := newTestWorkqueue()
+	attempts := 0
+	adapter := NewWorkqueueAdapter(queue, &Controller{}, func(key interface{}) (Resource, interface{}, error) {
+		attempts++
+		return Resource{}, nil, errors.New("not found")
+	})
+
+	queue.Add("foo")
+	for i := 0; i < maxRetries; i++ {
+		g.Expect(adapter.processNext(context.Background())).To(BeTrue())
+		g.Expect(queue.NumRequeues("foo")).To(Equal(i + 1))
+	}
+	// the final attempt gives up and forgets the key
+	g.Expect(adapter.processNext(context.Background())).To(BeTrue())
+	g.Expect(attempts).To(Equal(maxRetries + 1))
+	g.Expect(queue.NumRequeues("foo")).To(Equal(0))
+	g.Expect(queue.Len()).To(Equal(0))
+
+	queue.ShutDown()
+	g.Expect(adapter.processNext(context.Background())).To(BeFalse())
+}
+
+func ExampleWorkqueueAdapter() {
+	stop := make(chan struct{})
+	defer close(stop)
+	mgr := NewManager(nil)
+	ctl := mgr.CreateIstioStatusController(func(status *v1alpha1.IstioStatus, context interface{}) *v1alpha1.IstioStatus {
+		return status
+	})
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	adapter := NewWorkqueueAdapter(queue, ctl, func(key interface{}) (Resource, interface{}, error) {
+		r := ResourceFromString(key.(string))
+		if r == nil {
+			return Resource{}, nil, errors.New("invalid key")
+		}
+		return *r, nil, nil
+	})
+	go adapter.Run(stop)
+	queue.Add("networking.istio.io/v1alpha3/virtualservices/default/reviews/1")
+}
@@ -0,0 +1,64 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"time"
+)
+
+// WithMinWriteInterval spaces the writes of each target at least interval apart, so that a resource whose status
+// changes very frequently cannot dominate writes to the API server.  Once status has been written for a target, it
+// is not processed again until interval has passed, while pushes keep accumulating its latest progress; the status
+// written then reflects the most recent pushes, so the final value is never dropped.  Writes which fail or change
+// nothing do not count, and a target deleted while queued has its final status written without waiting.  Zero, the
+// default, does not limit the write rate.
+func WithMinWriteInterval(interval time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.q.minWriteInterval = interval
+	}
+}
+
+// markWritten records that status was just written for target, so that it is not popped again before the minimum
+// write interval has passed.  Past entries are swept as the map doubles in size, to keep it bounded by the targets
+// written within the interval.
+func (wq *WorkQueue) markWritten(target Resource) {
+	if wq.minWriteInterval <= 0 {
+		return
+	}
+	wq.lock.Lock()
+	defer wq.lock.Unlock()
+	now := wq.now()
+	if wq.nextWrite == nil {
+		wq.nextWrite = make(map[lockResource]time.Time)
+	}
+	if len(wq.nextWrite) >= 2*wq.swept {
+		for key, at := range wq.nextWrite {
+			if !at.After(now) {
+				delete(wq.nextWrite, key)
+			}
+		}
+		wq.swept = len(wq.nextWrite)
+	}
+	wq.nextWrite[convert(target)] = now.Add(wq.minWriteInterval)
+}
+
+// writableAt returns the time at which entry may next be popped according to the minimum write interval, or the zero
+// time if it is not limited.  The caller must hold wq.lock.
+func (wq *WorkQueue) writableAt(entry cacheEntry) time.Time {
+	if entry.deleted {
+		return time.Time{}
+	}
+	return wq.nextWrite[convert(entry.cacheResource)]
+}
@@ -0,0 +1,64 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/istio/pkg/config"
+)
+
+func TestWorkerPoolMinWriteInterval(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	written := make(chan string, 10)
+	wp := NewWorkerPool(func(cfg *config.Config, status interface{}) (bool, error) {
+		written <- cfg.Name + "=" + status.(*IstioGenerationProvider).Conditions[0].Message
+		return true, nil
+	}, func(r Resource) *config.Config {
+		return &config.Config{Meta: config.Meta{Name: r.Name, Generation: 1}}
+	}, 2, WithMinWriteInterval(5*time.Second), func(wp *WorkerPool) { wp.clock = fakeClock })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wp.Run(ctx)
+	ctl := &Controller{fn: func(status interface{}, context interface{}) GenerationProvider {
+		return &IstioGenerationProvider{&v1alpha1.IstioStatus{
+			Conditions: []*v1alpha1.IstioCondition{{Message: context.(string)}},
+		}}
+	}}
+
+	for round := 0; round < 2; round++ {
+		wp.Push(outcomeTarget("a"), ctl, "1")
+		g.Eventually(written).Should(Receive(Equal("a=1")))
+		// pushes within the interval accumulate, and only the latest is written once it has passed
+		wp.Push(outcomeTarget("a"), ctl, "2")
+		wp.Push(outcomeTarget("a"), ctl, "3")
+		// other targets are not limited
+		wp.Push(outcomeTarget("b"), ctl, "1")
+		g.Eventually(written).Should(Receive(Equal("b=1")))
+		fakeClock.Step(4 * time.Second)
+		g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+		fakeClock.Step(time.Second)
+		g.Eventually(written).Should(Receive(Equal("a=3")))
+		g.Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+		fakeClock.Step(5 * time.Second)
+	}
+}